@@ -742,6 +742,9 @@ options:
   runtime:
     description:
       - Runtime to use for the container.
+      - The runtime must be known to the Docker daemon, for example C(runc) or a runtime
+        registered in the daemon's configuration like C(nvidia), C(kata-runtime) or C(runsc).
+        The module fails if the daemon does not report the requested runtime.
     type: str
   shm_size:
     description:
@@ -1462,6 +1465,9 @@ class TaskParameters(DockerBaseClass):
                 if network.get('links'):
                     network['links'] = self._parse_links(network['links'])
 
+        if self.runtime:
+            self._check_runtime()
+
         if self.mac_address:
             # Ensure the MAC address uses colons instead of hyphens for later comparison
             self.mac_address = self.mac_address.replace('-', ':')
@@ -1982,6 +1988,21 @@ class TaskParameters(DockerBaseClass):
             self.fail("Error getting network id for %s - %s" % (network_name, to_native(exc)))
         return network_id
 
+    def _check_runtime(self):
+        '''
+        Make sure that the Docker daemon knows the requested runtime.
+        '''
+        try:
+            runtimes = self.client.info().get('Runtimes')
+        except Exception as exc:
+            self.fail("Error retrieving the list of runtimes from the Docker daemon - %s" % to_native(exc))
+        if runtimes is None:
+            # The daemon does not report the runtimes it knows about, so let it decide on its own
+            return
+        if self.runtime not in runtimes:
+            self.fail('Parameter error: runtime "%s" is not known to the Docker daemon. Available runtimes: %s' % (
+                self.runtime, ', '.join(sorted(runtimes))))
+
     def _process_mounts(self):
         if self.mounts is None:
             return None, None
@@ -0,0 +1,124 @@
+// Copyright (c) Ansible Project
+// GNU General Public License v3.0+ (see COPYING or https://www.gnu.org/licenses/gpl-3.0.txt)
+
+// Command authz-plugin is a Docker authorization plugin which denies the
+// requests matching a configurable list of rules, and allows all others.
+// Rules are given as JSON list:
+//
+//	[{"method":"POST","path":"^/containers/create$","body":{"HostConfig.Privileged":true},"message":"privileged containers are not allowed"}]
+//
+// Each rule matches requests by method, by a regular expression for the path
+// (without API version prefix and query), and by the values of fields of the
+// JSON request body, given as dotted paths. All criteria are optional. The
+// message is returned to the client by the daemon. A rule with a count is
+// removed after denying that many requests.
+//
+// The rules are read from the file given with -rules. With -listen, they can
+// be replaced with PUT, read with GET and cleared with DELETE requests to
+// /_mock/rules. GET /_mock/decisions returns all authorization decisions made
+// so far (method, path, user, allowed and message), and DELETE
+// /_mock/decisions clears that list. Be careful with rules matching all
+// requests, since they also deny the requests needed to disable the plugin.
+//
+// Usage:
+//
+//	authz-plugin [-socket PATH] [-listen ADDRESS] [-rules FILE]
+//
+// The default socket is /run/docker/plugins/authz-plugin.sock, which is used
+// when running as managed plugin. config.json in this directory makes the
+// plugin listen on 127.0.0.1:8090 of the host. To install and activate the
+// plugin:
+//
+//	docker build -t authz-plugin-rootfs -f tests/images/authz-plugin/Dockerfile tests/images
+//	mkdir -p /tmp/authz-plugin/rootfs
+//	docker export "$(docker create authz-plugin-rootfs)" | tar -x -C /tmp/authz-plugin/rootfs
+//	mkdir -p /tmp/authz-plugin/rootfs/run/docker/plugins
+//	cp tests/images/authz-plugin/config.json /tmp/authz-plugin/
+//	docker plugin create authz-plugin /tmp/authz-plugin
+//	docker plugin enable authz-plugin
+//
+// and restart the daemon with --authorization-plugin=authz-plugin.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+
+	"github.com/ansible-collections/community.docker/tests/images/internal/fixture"
+)
+
+type authzRequest struct {
+	User           string `json:"User"`
+	RequestMethod  string `json:"RequestMethod"`
+	RequestURI     string `json:"RequestURI"`
+	RequestBody    []byte `json:"RequestBody"`
+	ResponseStatus int    `json:"ResponseStatusCode"`
+}
+
+type authzResponse struct {
+	Allow bool   `json:"Allow"`
+	Msg   string `json:"Msg,omitempty"`
+	Err   string `json:"Err,omitempty"`
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("authz-plugin: ")
+
+	socket := flag.String("socket", "/run/docker/plugins/authz-plugin.sock", "path of the plugin socket")
+	listen := flag.String("listen", "", "serve the control endpoints over HTTP on this address")
+	rulesFile := flag.String("rules", "", "JSON file with the deny rules")
+	flag.Parse()
+
+	rs := &rules{}
+	if *rulesFile != "" {
+		content, err := os.ReadFile(*rulesFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := rs.set(content); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	mux := fixture.NewPluginMux("authz")
+	mux.HandleFunc("/AuthZPlugin.AuthZReq", func(w http.ResponseWriter, r *http.Request) {
+		var req authzRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			fixture.ServePluginJSON(w, authzResponse{Err: "invalid request: " + err.Error()})
+			return
+		}
+		path := req.RequestURI
+		if u, err := url.ParseRequestURI(req.RequestURI); err == nil {
+			path = u.Path
+		}
+		path = fixture.StripAPIVersion(path)
+		// Bodies which are not JSON, like build contexts, only match rules
+		// without body criteria.
+		var body interface{}
+		json.Unmarshal(req.RequestBody, &body)
+
+		if denied := rs.check(req.User, req.RequestMethod, path, body); denied != nil {
+			log.Printf("%s %s: denied: %s", req.RequestMethod, path, denied.Message)
+			fixture.ServePluginJSON(w, authzResponse{Allow: false, Msg: denied.Message})
+			return
+		}
+		fixture.ServePluginJSON(w, authzResponse{Allow: true})
+	})
+	mux.HandleFunc("/AuthZPlugin.AuthZRes", func(w http.ResponseWriter, r *http.Request) {
+		fixture.ServePluginJSON(w, authzResponse{Allow: true})
+	})
+
+	if *listen != "" {
+		control := http.NewServeMux()
+		control.Handle("/_mock/rules", fixture.Control{Get: rs.list, Set: rs.set, Clear: rs.clear})
+		control.Handle("/_mock/decisions", fixture.Control{Get: rs.listDecisions, Clear: rs.clearDecisions})
+		fixture.Serve(*listen, control)
+	}
+
+	fixture.ServePlugin(*socket, mux)
+}
@@ -0,0 +1,138 @@
+// Copyright (c) Ansible Project
+// GNU General Public License v3.0+ (see COPYING or https://www.gnu.org/licenses/gpl-3.0.txt)
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/ansible-collections/community.docker/tests/images/internal/fixture"
+)
+
+// rule denies requests matching its method, path and body fields. Body maps
+// dotted paths of fields in the JSON request body, like HostConfig.Privileged,
+// to the values they must have.
+type rule struct {
+	fixture.RequestRule
+	Body    map[string]interface{} `json:"body,omitempty"`
+	Message string                 `json:"message,omitempty"`
+}
+
+type decision struct {
+	Method  string `json:"method"`
+	Path    string `json:"path"`
+	User    string `json:"user"`
+	Allowed bool   `json:"allowed"`
+	Message string `json:"message,omitempty"`
+}
+
+// rules holds the deny rules and records all decisions.
+type rules struct {
+	mu        sync.Mutex
+	rules     []*rule
+	decisions []decision
+}
+
+func (ru *rule) compile() error {
+	if err := ru.CompilePath(); err != nil {
+		return err
+	}
+	if ru.Message == "" {
+		ru.Message = "request denied by authz-plugin"
+	}
+	return nil
+}
+
+// matchesBody tells whether the decoded JSON request body has the fields
+// required by the rule.
+func (ru *rule) matchesBody(body interface{}) bool {
+	for field, expected := range ru.Body {
+		value, ok := lookupField(body, field)
+		if !ok || !reflect.DeepEqual(value, expected) {
+			return false
+		}
+	}
+	return true
+}
+
+// lookupField returns the value at the dotted path in a decoded JSON value.
+func lookupField(value interface{}, field string) (interface{}, bool) {
+	for _, key := range strings.Split(field, ".") {
+		object, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if value, ok = object[key]; !ok {
+			return nil, false
+		}
+	}
+	return value, true
+}
+
+func (rs *rules) set(content []byte) error {
+	var list []*rule
+	if err := json.Unmarshal(content, &list); err != nil {
+		return fmt.Errorf("cannot parse rules: %v", err)
+	}
+	for _, ru := range list {
+		if err := ru.compile(); err != nil {
+			return err
+		}
+	}
+	rs.mu.Lock()
+	rs.rules = list
+	rs.mu.Unlock()
+	return nil
+}
+
+// check returns the first rule denying the request, counting it as used, and
+// records the decision.
+func (rs *rules) check(user, method, path string, body interface{}) *rule {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	denied := fixture.Match(&rs.rules, method, path, func(ru *rule) bool {
+		return ru.matchesBody(body)
+	})
+	d := decision{Method: method, Path: path, User: user, Allowed: denied == nil}
+	if denied != nil {
+		d.Message = denied.Message
+	}
+	rs.decisions = append(rs.decisions, d)
+	return denied
+}
+
+// list returns a snapshot of the rules for the /_mock/rules endpoint.
+func (rs *rules) list(*http.Request) interface{} {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	list := make([]rule, len(rs.rules))
+	for i, ru := range rs.rules {
+		list[i] = *ru
+	}
+	return list
+}
+
+func (rs *rules) clear() {
+	rs.mu.Lock()
+	rs.rules = nil
+	rs.mu.Unlock()
+}
+
+// listDecisions returns a snapshot of the decisions for the /_mock/decisions
+// endpoint.
+func (rs *rules) listDecisions(*http.Request) interface{} {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	return append([]decision{}, rs.decisions...)
+}
+
+func (rs *rules) clearDecisions() {
+	rs.mu.Lock()
+	rs.decisions = nil
+	rs.mu.Unlock()
+}
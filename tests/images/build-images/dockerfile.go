@@ -0,0 +1,65 @@
+// Copyright (c) Ansible Project
+// GNU General Public License v3.0+ (see COPYING or https://www.gnu.org/licenses/gpl-3.0.txt)
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+var (
+	// standardBuildRegexp matches the build step shared by the Dockerfiles,
+	// which can be replaced by cross-compiling on the host.
+	standardBuildRegexp = regexp.MustCompile(`^RUN CGO_ENABLED=0 go build -trimpath -ldflags '-s -w' -o /out/([a-z0-9-]+) \./([a-z0-9-]+)$`)
+	copyFromBuildRegexp = regexp.MustCompile(`^COPY --from=build /out/([a-z0-9-]+) (\S+)$`)
+)
+
+// finalStage returns the final stage of the image's Dockerfile, rewritten to
+// copy the cross-compiled binary from the TARGETARCH subdirectory of the
+// build context instead of the build stage. ok is false if the build stage
+// does more than compiling the image's program, in which case the original
+// Dockerfile has to be used.
+func finalStage(path, name string) (content string, ok bool, err error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return "", false, err
+	}
+	lines := strings.Split(strings.TrimRight(string(raw), "\n"), "\n")
+	last := -1
+	standard := false
+	for i, line := range lines {
+		if strings.HasPrefix(strings.ToUpper(line), "FROM ") {
+			last = i
+		}
+		if m := standardBuildRegexp.FindStringSubmatch(line); m != nil && m[1] == name && m[2] == name {
+			standard = true
+		}
+	}
+	if last < 0 {
+		return "", false, fmt.Errorf("%s: no FROM instruction", path)
+	}
+	if !standard {
+		return "", false, nil
+	}
+
+	out := []string{
+		fmt.Sprintf("# Generated by build-images from %s", path),
+		lines[last],
+		"ARG TARGETARCH",
+	}
+	for _, line := range lines[last+1:] {
+		if m := copyFromBuildRegexp.FindStringSubmatch(line); m != nil {
+			if m[1] != name {
+				return "", false, fmt.Errorf("%s: unexpected binary %s", path, m[1])
+			}
+			line = fmt.Sprintf("COPY ${TARGETARCH}/%s %s", name, m[2])
+		} else if strings.Contains(line, "--from=") {
+			return "", false, nil
+		}
+		out = append(out, line)
+	}
+	return strings.Join(out, "\n") + "\n", true, nil
+}
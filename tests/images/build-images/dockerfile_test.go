@@ -0,0 +1,122 @@
+// Copyright (c) Ansible Project
+// GNU General Public License v3.0+ (see COPYING or https://www.gnu.org/licenses/gpl-3.0.txt)
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+const buildStage = `# Copyright (c) Ansible Project
+# GNU General Public License v3.0+ (see COPYING or https://www.gnu.org/licenses/gpl-3.0.txt)
+
+FROM golang:1.22-alpine AS build
+WORKDIR /src
+COPY . .
+`
+
+func TestFinalStage(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "Dockerfile")
+
+	for _, tc := range []struct {
+		name       string
+		image      string
+		dockerfile string
+		content    string
+		ok         bool
+		err        string
+	}{
+		{"standard", "wait-for", buildStage + `RUN CGO_ENABLED=0 go build -trimpath -ldflags '-s -w' -o /out/wait-for ./wait-for
+
+FROM scratch
+COPY --from=build /out/wait-for /wait-for
+ENTRYPOINT ["/wait-for"]
+`, `# Generated by build-images from ` + path + `
+FROM scratch
+ARG TARGETARCH
+COPY ${TARGETARCH}/wait-for /wait-for
+ENTRYPOINT ["/wait-for"]
+`, true, ""},
+		{"copied twice", "exec-recorder", buildStage + `RUN CGO_ENABLED=0 go build -trimpath -ldflags '-s -w' -o /out/exec-recorder ./exec-recorder
+
+FROM scratch
+COPY --from=build /out/exec-recorder /exec-recorder
+# The same program records invocations when called as record-exec
+COPY --from=build /out/exec-recorder /record-exec
+ENTRYPOINT ["/exec-recorder"]
+`, `# Generated by build-images from ` + path + `
+FROM scratch
+ARG TARGETARCH
+COPY ${TARGETARCH}/exec-recorder /exec-recorder
+# The same program records invocations when called as record-exec
+COPY ${TARGETARCH}/exec-recorder /record-exec
+ENTRYPOINT ["/exec-recorder"]
+`, true, ""},
+		{"lowercase from", "wait-for", buildStage + `RUN CGO_ENABLED=0 go build -trimpath -ldflags '-s -w' -o /out/wait-for ./wait-for
+from alpine:3.19
+COPY --from=build /out/wait-for /usr/local/bin/wait-for`, `# Generated by build-images from ` + path + `
+from alpine:3.19
+ARG TARGETARCH
+COPY ${TARGETARCH}/wait-for /usr/local/bin/wait-for
+`, true, ""},
+		// The build step sets variables through the linker flags.
+		{"build arguments", "build-info", buildStage + `# The build ID changes whenever this step is not taken from the build cache
+RUN CGO_ENABLED=0 go build -trimpath -ldflags "-s -w \
+        -X main.version=${VERSION} \
+        -X main.buildID=$(cat /proc/sys/kernel/random/uuid)" \
+    -o /out/build-info ./build-info
+
+FROM scratch
+COPY --from=build /out/build-info /build-info
+ENTRYPOINT ["/build-info"]
+`, "", false, ""},
+		// The build step prepares more than the binary.
+		{"continued build step", "registry-mock", buildStage + `RUN CGO_ENABLED=0 go build -trimpath -ldflags '-s -w' -o /out/registry-mock ./registry-mock \
+    && mkdir -m 1777 /out/tmp
+
+FROM scratch
+COPY --from=build /out/registry-mock /registry-mock
+COPY --from=build /out/tmp /tmp
+ENTRYPOINT ["/registry-mock"]
+`, "", false, ""},
+		{"copy from other stage", "wait-for", buildStage + `RUN CGO_ENABLED=0 go build -trimpath -ldflags '-s -w' -o /out/wait-for ./wait-for
+
+FROM alpine:3.19 AS certs
+RUN apk add --no-cache ca-certificates
+
+FROM scratch
+COPY --from=certs /etc/ssl/certs /etc/ssl/certs
+COPY --from=build /out/wait-for /wait-for
+`, "", false, ""},
+		{"other program", "wait-for", buildStage + `RUN CGO_ENABLED=0 go build -trimpath -ldflags '-s -w' -o /out/wait-for ./file-server
+
+FROM scratch
+COPY --from=build /out/wait-for /wait-for
+`, "", false, ""},
+		{"single stage", "wait-for", "FROM alpine:3.19\nCOPY wait-for /wait-for\n", "", false, ""},
+		{"binary mismatch", "wait-for", buildStage + `RUN CGO_ENABLED=0 go build -trimpath -ldflags '-s -w' -o /out/wait-for ./wait-for
+
+FROM scratch
+COPY --from=build /out/file-server /file-server
+`, "", false, path + ": unexpected binary file-server"},
+		{"no FROM", "wait-for", "# empty\n", "", false, path + ": no FROM instruction"},
+	} {
+		if err := os.WriteFile(path, []byte(tc.dockerfile), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		content, ok, err := finalStage(path, tc.image)
+		if content != tc.content || ok != tc.ok {
+			t.Errorf("%s: got %#v, %v, expected %#v, %v", tc.name, content, ok, tc.content, tc.ok)
+		}
+		if (err == nil && tc.err != "") || (err != nil && err.Error() != tc.err) {
+			t.Errorf("%s: got error %v, expected %q", tc.name, err, tc.err)
+		}
+	}
+
+	if _, _, err := finalStage(filepath.Join(dir, "missing"), "wait-for"); !os.IsNotExist(err) {
+		t.Errorf("missing Dockerfile: got error %v, expected it not to exist", err)
+	}
+}
@@ -0,0 +1,231 @@
+// Copyright (c) Ansible Project
+// GNU General Public License v3.0+ (see COPYING or https://www.gnu.org/licenses/gpl-3.0.txt)
+
+// Command build-images builds the test images for one or more architectures
+// and tags them consistently as PREFIX/NAME:TAG. It is not an image; run it
+// on the controller from this directory:
+//
+//	go run ./build-images -arch amd64,arm64 -prefix localhost:5000/test -push
+//
+// The programs are cross-compiled on the host with the local Go toolchain,
+// so no emulation is needed. For every image, the final stage of its
+// Dockerfile is built with the binary for the respective architecture. The
+// few images whose Dockerfile does more than compiling the program in the
+// build stage, like build-info, are built from their original Dockerfile
+// with --platform; for foreign architectures, this needs emulation.
+//
+// Without -buildx, every architecture is built separately with docker build
+// and tagged PREFIX/NAME:TAG-ARCH; with a single architecture, the image is
+// additionally tagged PREFIX/NAME:TAG. With -push, the images are pushed and
+// combined into the manifest list PREFIX/NAME:TAG with docker manifest. With
+// -buildx, all architectures are built at once with docker buildx build;
+// the result is pushed with -push and loaded into the local image store
+// otherwise, which requires the containerd image store for more than one
+// architecture.
+//
+// Pushing to a registry fixture like registry-mock works with PREFIX
+// starting with its address, for example localhost:5000. -insecure allows
+// docker manifest to push to a registry without TLS.
+//
+// Usage:
+//
+//	build-images [-arch LIST] [-prefix PREFIX] [-tag TAG] [-push] [-insecure] [-buildx] [-docker PATH] [-dry-run] [NAME...]
+//
+// The defaults are -arch amd64,arm64, -prefix ansible-test and -tag latest.
+// Without NAME, all images (the subdirectories with a Dockerfile) are built.
+// With -dry-run, the docker commands are printed instead of being run; the
+// programs are still compiled.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+type builder struct {
+	archs    []string
+	prefix   string
+	tag      string
+	push     bool
+	insecure bool
+	buildx   bool
+	docker   string
+	dryRun   bool
+	work     string
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("build-images: ")
+
+	archs := flag.String("arch", "amd64,arm64", "comma-separated list of architectures")
+	b := &builder{}
+	flag.StringVar(&b.prefix, "prefix", "ansible-test", "repository prefix of the image names")
+	flag.StringVar(&b.tag, "tag", "latest", "tag of the images")
+	flag.BoolVar(&b.push, "push", false, "push the images")
+	flag.BoolVar(&b.insecure, "insecure", false, "allow docker manifest to push without TLS")
+	flag.BoolVar(&b.buildx, "buildx", false, "build with docker buildx")
+	flag.StringVar(&b.docker, "docker", "docker", "docker CLI to use")
+	flag.BoolVar(&b.dryRun, "dry-run", false, "print the docker commands instead of running them")
+	flag.Parse()
+
+	for _, arch := range strings.Split(*archs, ",") {
+		if arch = strings.TrimSpace(arch); arch != "" {
+			b.archs = append(b.archs, arch)
+		}
+	}
+	if len(b.archs) == 0 {
+		fmt.Fprintln(flag.CommandLine.Output(), "-arch must not be empty")
+		os.Exit(2)
+	}
+
+	names := flag.Args()
+	if len(names) == 0 {
+		var err error
+		if names, err = findImages(); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	var err error
+	if b.work, err = os.MkdirTemp("", "build-images-"); err != nil {
+		log.Fatal(err)
+	}
+	err = b.buildAll(names)
+	os.RemoveAll(b.work)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func (b *builder) buildAll(names []string) error {
+	if err := b.compile(names); err != nil {
+		return err
+	}
+	for _, name := range names {
+		if err := b.build(name); err != nil {
+			return fmt.Errorf("%s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+// findImages returns the names of all subdirectories containing a Dockerfile.
+func findImages() ([]string, error) {
+	matches, err := filepath.Glob("*/Dockerfile")
+	if err != nil {
+		return nil, err
+	}
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("no images found; run this from tests/images")
+	}
+	var names []string
+	for _, match := range matches {
+		names = append(names, filepath.Dir(match))
+	}
+	return names, nil
+}
+
+// compile cross-compiles the programs of all images into WORK/ARCH.
+func (b *builder) compile(names []string) error {
+	packages := make([]string, len(names))
+	for i, name := range names {
+		packages[i] = "./" + name
+	}
+	for _, arch := range b.archs {
+		out := filepath.Join(b.work, arch) + string(filepath.Separator)
+		args := append([]string{"build", "-trimpath", "-ldflags", "-s -w", "-o", out}, packages...)
+		cmd := exec.Command("go", args...)
+		cmd.Env = append(os.Environ(), "CGO_ENABLED=0", "GOOS=linux", "GOARCH="+arch)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		log.Printf("compiling for %s", arch)
+		if err := cmd.Run(); err != nil {
+			return fmt.Errorf("compiling for %s: %v", arch, err)
+		}
+	}
+	return nil
+}
+
+// build builds, tags and optionally pushes the image name.
+func (b *builder) build(name string) error {
+	stage, ok, err := finalStage(filepath.Join(name, "Dockerfile"), name)
+	if err != nil {
+		return err
+	}
+	// The build context is either WORK, containing the binaries of all
+	// architectures, or this directory with the original Dockerfile.
+	context, dockerfile := ".", filepath.Join(name, "Dockerfile")
+	if ok {
+		context, dockerfile = b.work, filepath.Join(b.work, name+".Dockerfile")
+		if err := os.WriteFile(dockerfile, []byte(stage), 0o644); err != nil {
+			return err
+		}
+	}
+	image := fmt.Sprintf("%s/%s:%s", b.prefix, name, b.tag)
+
+	if b.buildx {
+		platforms := make([]string, len(b.archs))
+		for i, arch := range b.archs {
+			platforms[i] = "linux/" + arch
+		}
+		output := "--load"
+		if b.push {
+			output = "--push"
+		}
+		return b.run("buildx", "build", "--platform", strings.Join(platforms, ","), "-t", image, "-f", dockerfile, output, context)
+	}
+
+	var archImages []string
+	for _, arch := range b.archs {
+		archImage := image + "-" + arch
+		archImages = append(archImages, archImage)
+		args := []string{"build", "--platform", "linux/" + arch, "--build-arg", "TARGETARCH=" + arch, "-t", archImage}
+		if len(b.archs) == 1 {
+			args = append(args, "-t", image)
+		}
+		if err := b.run(append(args, "-f", dockerfile, context)...); err != nil {
+			return err
+		}
+		if b.push {
+			if err := b.run("push", archImage); err != nil {
+				return err
+			}
+		}
+	}
+	if !b.push {
+		return nil
+	}
+	if err := b.run(append([]string{"manifest", "create", "--amend", image}, archImages...)...); err != nil {
+		return err
+	}
+	args := []string{"manifest", "push", "--purge"}
+	if b.insecure {
+		args = append(args, "--insecure")
+	}
+	return b.run(append(args, image)...)
+}
+
+// run runs the docker CLI with args, or prints the command with -dry-run.
+func (b *builder) run(args ...string) error {
+	if b.dryRun {
+		quoted := make([]string, len(args))
+		for i, arg := range args {
+			quoted[i] = arg
+			if arg == "" || strings.ContainsAny(arg, " \t\"'$") {
+				quoted[i] = fmt.Sprintf("%q", arg)
+			}
+		}
+		fmt.Println(b.docker, strings.Join(quoted, " "))
+		return nil
+	}
+	cmd := exec.Command(b.docker, args...)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
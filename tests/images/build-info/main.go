@@ -0,0 +1,70 @@
+// Copyright (c) Ansible Project
+// GNU General Public License v3.0+ (see COPYING or https://www.gnu.org/licenses/gpl-3.0.txt)
+
+// Command build-info prints values which were injected into the program when
+// it was built as a single line of JSON on stdout:
+//
+//	{"version":"1.2.3","value":"hello","build_id":"0b5e2f4c-...","build_time":"2021-05-01T12:00:00Z","go_version":"go1.22.5","os":"linux","arch":"amd64"}
+//
+// The Dockerfile passes its build arguments VERSION and VALUE to the linker
+// with -X, and also sets them as the image labels
+// org.ansible.community.docker.test.version and
+// org.ansible.community.docker.test.value. build_id is generated anew every
+// time the program is compiled, so it only stays the same when the image is
+// built from the build cache.
+//
+// Usage:
+//
+//	build-info [-keep-running]
+//
+// With -keep-running, the program stays alive after printing until it
+// receives SIGTERM or SIGINT.
+package main
+
+import (
+	"flag"
+	"log"
+	"runtime"
+
+	"github.com/ansible-collections/community.docker/tests/images/internal/fixture"
+)
+
+// These are set with -ldflags -X when building the image.
+var (
+	version   = "unknown"
+	value     = ""
+	buildID   = ""
+	buildTime = ""
+)
+
+type buildInfo struct {
+	Version   string `json:"version"`
+	Value     string `json:"value"`
+	BuildID   string `json:"build_id"`
+	BuildTime string `json:"build_time"`
+	GoVersion string `json:"go_version"`
+	OS        string `json:"os"`
+	Arch      string `json:"arch"`
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("build-info: ")
+
+	keepRunning := flag.Bool("keep-running", false, "keep running after printing until SIGTERM or SIGINT")
+	flag.Parse()
+
+	fixture.WriteJSON(buildInfo{
+		Version:   version,
+		Value:     value,
+		BuildID:   buildID,
+		BuildTime: buildTime,
+		GoVersion: runtime.Version(),
+		OS:        runtime.GOOS,
+		Arch:      runtime.GOARCH,
+	})
+
+	if *keepRunning {
+		fixture.WaitForSignal()
+	}
+}
@@ -0,0 +1,154 @@
+// Copyright (c) Ansible Project
+// GNU General Public License v3.0+ (see COPYING or https://www.gnu.org/licenses/gpl-3.0.txt)
+
+// Command capabilities-reporter prints the Linux capability sets of its own
+// process, as decoded from /proc/self/status, as a single line of JSON on
+// stdout:
+//
+//	{"effective":["CAP_CHOWN",...],"permitted":[...],"inheritable":[...],"bounding":[...],"ambient":[...]}
+//
+// Capabilities without a known name are reported as CAP_<bit number>.
+//
+// Usage:
+//
+//	capabilities-reporter [-keep-running]
+//
+// With -keep-running, the program stays alive after printing until it
+// receives SIGTERM or SIGINT.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/ansible-collections/community.docker/tests/images/internal/fixture"
+)
+
+// capabilityNames maps capability bit numbers to their names, see
+// include/uapi/linux/capability.h in the kernel sources.
+var capabilityNames = []string{
+	"CAP_CHOWN",
+	"CAP_DAC_OVERRIDE",
+	"CAP_DAC_READ_SEARCH",
+	"CAP_FOWNER",
+	"CAP_FSETID",
+	"CAP_KILL",
+	"CAP_SETGID",
+	"CAP_SETUID",
+	"CAP_SETPCAP",
+	"CAP_LINUX_IMMUTABLE",
+	"CAP_NET_BIND_SERVICE",
+	"CAP_NET_BROADCAST",
+	"CAP_NET_ADMIN",
+	"CAP_NET_RAW",
+	"CAP_IPC_LOCK",
+	"CAP_IPC_OWNER",
+	"CAP_SYS_MODULE",
+	"CAP_SYS_RAWIO",
+	"CAP_SYS_CHROOT",
+	"CAP_SYS_PTRACE",
+	"CAP_SYS_PACCT",
+	"CAP_SYS_ADMIN",
+	"CAP_SYS_BOOT",
+	"CAP_SYS_NICE",
+	"CAP_SYS_RESOURCE",
+	"CAP_SYS_TIME",
+	"CAP_SYS_TTY_CONFIG",
+	"CAP_MKNOD",
+	"CAP_LEASE",
+	"CAP_AUDIT_WRITE",
+	"CAP_AUDIT_CONTROL",
+	"CAP_SETFCAP",
+	"CAP_MAC_OVERRIDE",
+	"CAP_MAC_ADMIN",
+	"CAP_SYSLOG",
+	"CAP_WAKE_ALARM",
+	"CAP_BLOCK_SUSPEND",
+	"CAP_AUDIT_READ",
+	"CAP_PERFMON",
+	"CAP_BPF",
+	"CAP_CHECKPOINT_RESTORE",
+}
+
+// statusFields maps the keys of the result to the fields of /proc/self/status.
+var statusFields = map[string]string{
+	"CapEff": "effective",
+	"CapPrm": "permitted",
+	"CapInh": "inheritable",
+	"CapBnd": "bounding",
+	"CapAmb": "ambient",
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("capabilities-reporter: ")
+
+	keepRunning := flag.Bool("keep-running", false, "keep running after printing until SIGTERM or SIGINT")
+	flag.Parse()
+
+	result, err := readCapabilities("/proc/self/status")
+	if err != nil {
+		log.Fatal(err)
+	}
+	fixture.WriteJSON(result)
+
+	if *keepRunning {
+		fixture.WaitForSignal()
+	}
+}
+
+func readCapabilities(path string) (map[string][]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	result := make(map[string][]string)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+		name, ok := statusFields[key]
+		if !ok {
+			continue
+		}
+		mask, err := strconv.ParseUint(strings.TrimSpace(value), 16, 64)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse %s in %s: %v", key, path, err)
+		}
+		result[name] = decodeCapabilities(mask)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("cannot read %s: %v", path, err)
+	}
+	for _, name := range statusFields {
+		if _, ok := result[name]; !ok {
+			// Older kernels do not report all sets (for example CapAmb)
+			result[name] = []string{}
+		}
+	}
+	return result, nil
+}
+
+func decodeCapabilities(mask uint64) []string {
+	names := []string{}
+	for bit := 0; bit < 64; bit++ {
+		if mask&(1<<bit) == 0 {
+			continue
+		}
+		if bit < len(capabilityNames) {
+			names = append(names, capabilityNames[bit])
+		} else {
+			names = append(names, fmt.Sprintf("CAP_%d", bit))
+		}
+	}
+	return names
+}
@@ -0,0 +1,274 @@
+// Copyright (c) Ansible Project
+// GNU General Public License v3.0+ (see COPYING or https://www.gnu.org/licenses/gpl-3.0.txt)
+
+// Command cgroup-reporter prints the resource limits of its own cgroup, as
+// found in /sys/fs/cgroup, as a single line of JSON on stdout:
+//
+//	{"version":2,"memory":268435456,"memory_reservation":-1,"memory_swap":536870912,"cpus":1.5,"cpu_period":100000,"cpu_quota":150000,"cpu_shares":null,"cpu_weight":100,"cpuset_cpus":"0-1","cpuset_mems":"0","pids_limit":100,"blkio_weight":500,"io_limits":[{"device":"8:0","read_bps":1048576,"write_bps":-1,"read_iops":-1,"write_iops":-1}],"files":{"memory.max":"268435456",...}}
+//
+// Both cgroup v1 and cgroup v2 are supported. The limits are named after the
+// corresponding options of docker_container, and normalized to their
+// semantics: memory_swap is the limit of memory and swap together, and cpus
+// is the quota divided by the period. Unlimited values are reported as -1,
+// and limits which are not available as null. cpu_shares is only available
+// for cgroup v1, cpu_weight only for cgroup v2. The contents of all files
+// read, relative to /sys/fs/cgroup, are included as files for debugging.
+//
+// Usage:
+//
+//	cgroup-reporter [-keep-running]
+//
+// With -keep-running, the program stays alive after printing until it
+// receives SIGTERM or SIGINT.
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/ansible-collections/community.docker/tests/images/internal/fixture"
+)
+
+const root = "/sys/fs/cgroup"
+
+// unlimitedThreshold is the value from which on limits are considered
+// unlimited. cgroup v1 reports unlimited memory as the largest multiple of
+// the page size.
+const unlimitedThreshold = 1 << 62
+
+type ioLimit struct {
+	Device    string `json:"device"`
+	ReadBPS   int64  `json:"read_bps"`
+	WriteBPS  int64  `json:"write_bps"`
+	ReadIOPS  int64  `json:"read_iops"`
+	WriteIOPS int64  `json:"write_iops"`
+}
+
+type report struct {
+	Version           int               `json:"version"`
+	Memory            *int64            `json:"memory"`
+	MemoryReservation *int64            `json:"memory_reservation"`
+	MemorySwap        *int64            `json:"memory_swap"`
+	CPUs              *float64          `json:"cpus"`
+	CPUPeriod         *int64            `json:"cpu_period"`
+	CPUQuota          *int64            `json:"cpu_quota"`
+	CPUShares         *int64            `json:"cpu_shares"`
+	CPUWeight         *int64            `json:"cpu_weight"`
+	CpusetCPUs        *string           `json:"cpuset_cpus"`
+	CpusetMems        *string           `json:"cpuset_mems"`
+	PidsLimit         *int64            `json:"pids_limit"`
+	BlkioWeight       *int64            `json:"blkio_weight"`
+	IOLimits          []ioLimit         `json:"io_limits"`
+	Files             map[string]string `json:"files"`
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("cgroup-reporter: ")
+
+	keepRunning := flag.Bool("keep-running", false, "keep running after printing until SIGTERM or SIGINT")
+	flag.Parse()
+
+	r := &report{IOLimits: []ioLimit{}, Files: make(map[string]string)}
+	if _, err := os.Stat(filepath.Join(root, "cgroup.controllers")); err == nil {
+		r.readV2()
+	} else {
+		r.readV1()
+	}
+	fixture.WriteJSON(r)
+
+	if *keepRunning {
+		fixture.WaitForSignal()
+	}
+}
+
+// read returns the trimmed content of a file relative to root, and records
+// it.
+func (r *report) read(name string) (string, bool) {
+	content, err := os.ReadFile(filepath.Join(root, name))
+	if err != nil {
+		return "", false
+	}
+	value := strings.TrimSpace(string(content))
+	r.Files[name] = value
+	return value, true
+}
+
+// readString returns the content of the first of the files which exists.
+func (r *report) readString(names ...string) *string {
+	for _, name := range names {
+		if value, ok := r.read(name); ok {
+			return &value
+		}
+	}
+	return nil
+}
+
+// readLimit returns the limit in the first of the files which exists.
+func (r *report) readLimit(names ...string) *int64 {
+	if value := r.readString(names...); value != nil {
+		return parseLimit(*value)
+	}
+	return nil
+}
+
+// readWeight returns the weight in the first of the files which exists. The
+// files can contain a default weight and weights per device; only the default
+// weight is returned.
+func (r *report) readWeight(names ...string) *int64 {
+	value := r.readString(names...)
+	if value == nil {
+		return nil
+	}
+	for _, line := range strings.Split(*value, "\n") {
+		fields := strings.Fields(line)
+		switch {
+		case len(fields) == 1:
+			return parseLimit(fields[0])
+		case len(fields) == 2 && fields[0] == "default":
+			return parseLimit(fields[1])
+		}
+	}
+	return nil
+}
+
+func parseLimit(s string) *int64 {
+	if s == "max" {
+		return int64Ptr(-1)
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return nil
+	}
+	if n >= unlimitedThreshold {
+		n = -1
+	}
+	return &n
+}
+
+func (r *report) readV2() {
+	r.Version = 2
+	r.Memory = r.readLimit("memory.max")
+	r.MemoryReservation = r.readLimit("memory.low")
+	if r.MemoryReservation != nil && *r.MemoryReservation == 0 {
+		r.MemoryReservation = int64Ptr(-1)
+	}
+	if swap := r.readLimit("memory.swap.max"); swap != nil && r.Memory != nil {
+		if *swap == -1 || *r.Memory == -1 {
+			r.MemorySwap = int64Ptr(-1)
+		} else {
+			r.MemorySwap = int64Ptr(*r.Memory + *swap)
+		}
+	}
+	if value := r.readString("cpu.max"); value != nil {
+		if fields := strings.Fields(*value); len(fields) == 2 {
+			r.CPUQuota = parseLimit(fields[0])
+			r.CPUPeriod = parseLimit(fields[1])
+		}
+	}
+	r.CPUWeight = r.readLimit("cpu.weight")
+	r.CpusetCPUs = r.readString("cpuset.cpus.effective", "cpuset.cpus")
+	r.CpusetMems = r.readString("cpuset.mems.effective", "cpuset.mems")
+	r.PidsLimit = r.readLimit("pids.max")
+	r.BlkioWeight = r.readWeight("io.bfq.weight", "io.weight")
+	if value := r.readString("io.max"); value != nil {
+		for _, line := range strings.Split(*value, "\n") {
+			fields := strings.Fields(line)
+			if len(fields) == 0 {
+				continue
+			}
+			limit := ioLimit{Device: fields[0], ReadBPS: -1, WriteBPS: -1, ReadIOPS: -1, WriteIOPS: -1}
+			for _, field := range fields[1:] {
+				key, value, _ := strings.Cut(field, "=")
+				n := parseLimit(value)
+				if n == nil {
+					continue
+				}
+				switch key {
+				case "rbps":
+					limit.ReadBPS = *n
+				case "wbps":
+					limit.WriteBPS = *n
+				case "riops":
+					limit.ReadIOPS = *n
+				case "wiops":
+					limit.WriteIOPS = *n
+				}
+			}
+			r.IOLimits = append(r.IOLimits, limit)
+		}
+	}
+	r.computeCPUs()
+}
+
+func (r *report) readV1() {
+	r.Version = 1
+	r.Memory = r.readLimit("memory/memory.limit_in_bytes")
+	r.MemoryReservation = r.readLimit("memory/memory.soft_limit_in_bytes")
+	r.MemorySwap = r.readLimit("memory/memory.memsw.limit_in_bytes")
+	r.CPUQuota = r.readLimit("cpu/cpu.cfs_quota_us", "cpu,cpuacct/cpu.cfs_quota_us")
+	r.CPUPeriod = r.readLimit("cpu/cpu.cfs_period_us", "cpu,cpuacct/cpu.cfs_period_us")
+	r.CPUShares = r.readLimit("cpu/cpu.shares", "cpu,cpuacct/cpu.shares")
+	r.CpusetCPUs = r.readString("cpuset/cpuset.effective_cpus", "cpuset/cpuset.cpus")
+	r.CpusetMems = r.readString("cpuset/cpuset.effective_mems", "cpuset/cpuset.mems")
+	r.PidsLimit = r.readLimit("pids/pids.max")
+	r.BlkioWeight = r.readWeight("blkio/blkio.bfq.weight", "blkio/blkio.weight")
+
+	limits := make(map[string]*ioLimit)
+	var devices []string
+	for _, file := range []string{"read_bps_device", "write_bps_device", "read_iops_device", "write_iops_device"} {
+		value := r.readString("blkio/blkio.throttle." + file)
+		if value == nil {
+			continue
+		}
+		for _, line := range strings.Split(*value, "\n") {
+			fields := strings.Fields(line)
+			if len(fields) != 2 {
+				continue
+			}
+			n := parseLimit(fields[1])
+			if n == nil {
+				continue
+			}
+			limit := limits[fields[0]]
+			if limit == nil {
+				limit = &ioLimit{Device: fields[0], ReadBPS: -1, WriteBPS: -1, ReadIOPS: -1, WriteIOPS: -1}
+				limits[fields[0]] = limit
+				devices = append(devices, fields[0])
+			}
+			switch file {
+			case "read_bps_device":
+				limit.ReadBPS = *n
+			case "write_bps_device":
+				limit.WriteBPS = *n
+			case "read_iops_device":
+				limit.ReadIOPS = *n
+			case "write_iops_device":
+				limit.WriteIOPS = *n
+			}
+		}
+	}
+	for _, device := range devices {
+		r.IOLimits = append(r.IOLimits, *limits[device])
+	}
+	r.computeCPUs()
+}
+
+func (r *report) computeCPUs() {
+	if r.CPUQuota == nil || r.CPUPeriod == nil || *r.CPUPeriod <= 0 {
+		return
+	}
+	cpus := float64(-1)
+	if *r.CPUQuota >= 0 {
+		cpus = float64(*r.CPUQuota) / float64(*r.CPUPeriod)
+	}
+	r.CPUs = &cpus
+}
+
+func int64Ptr(n int64) *int64 {
+	return &n
+}
@@ -0,0 +1,123 @@
+// Copyright (c) Ansible Project
+// GNU General Public License v3.0+ (see COPYING or https://www.gnu.org/licenses/gpl-3.0.txt)
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ansible-collections/community.docker/tests/images/internal/fixture"
+)
+
+type result struct {
+	Target          string   `json:"target"`
+	OK              bool     `json:"ok"`
+	DurationSeconds float64  `json:"duration_seconds"`
+	Status          int      `json:"status,omitempty"`
+	Addresses       []string `json:"addresses,omitempty"`
+	Error           string   `json:"error,omitempty"`
+	Errno           string   `json:"errno,omitempty"`
+	Timeout         bool     `json:"timeout,omitempty"`
+}
+
+type report struct {
+	Targets []result `json:"targets"`
+}
+
+// checker tries a target once, and fills in the target specific fields of
+// res. It must return before ctx is done.
+type checker func(ctx context.Context, res *result) error
+
+// tryTargets tries all targets concurrently, each with the given timeout.
+func tryTargets(targets []string, timeout time.Duration) (report, error) {
+	checkers := make([]checker, len(targets))
+	for i, target := range targets {
+		var err error
+		if checkers[i], err = newChecker(target); err != nil {
+			return report{}, err
+		}
+	}
+
+	r := report{Targets: make([]result, len(targets))}
+	var wg sync.WaitGroup
+	for i, target := range targets {
+		r.Targets[i].Target = target
+		wg.Add(1)
+		go func(res *result, check checker) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+			start := time.Now()
+			err := check(ctx, res)
+			res.DurationSeconds = math.Round(time.Since(start).Seconds()*1000) / 1000
+			if err == nil {
+				res.OK = true
+				return
+			}
+			res.Error = err.Error()
+			res.Errno = fixture.ErrnoName(err)
+			var netErr net.Error
+			res.Timeout = errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout())
+		}(&r.Targets[i], checkers[i])
+	}
+	wg.Wait()
+	return r, nil
+}
+
+func newChecker(target string) (checker, error) {
+	u, err := url.Parse(target)
+	if err != nil {
+		return nil, fmt.Errorf("invalid target %q: %v", target, err)
+	}
+	switch u.Scheme {
+	case "tcp":
+		return func(ctx context.Context, res *result) error {
+			return fixture.CheckTCP(ctx, u.Host)
+		}, nil
+	case "http", "https":
+		client := fixture.NewHTTPClient(true)
+		return func(ctx context.Context, res *result) (err error) {
+			res.Status, err = fixture.CheckHTTP(ctx, client, target)
+			return err
+		}, nil
+	case "dns":
+		name := strings.TrimPrefix(u.Path, "/")
+		if u.Host != "" && name == "" {
+			// dns://NAME
+			name = u.Host
+		} else if u.Host == "" || name == "" {
+			return nil, fmt.Errorf("invalid target %q: expected dns://NAME or dns://SERVER/NAME", target)
+		}
+		resolver := net.DefaultResolver
+		if name != u.Host {
+			server := u.Host
+			if u.Port() == "" {
+				server = net.JoinHostPort(u.Hostname(), "53")
+			}
+			resolver = &net.Resolver{
+				PreferGo: true,
+				Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+					var dialer net.Dialer
+					return dialer.DialContext(ctx, network, server)
+				},
+			}
+		}
+		return func(ctx context.Context, res *result) error {
+			addresses, err := resolver.LookupHost(ctx, name)
+			if err != nil {
+				return err
+			}
+			res.Addresses = addresses
+			return nil
+		}, nil
+	}
+	return nil, fmt.Errorf("invalid target %q: unsupported scheme", target)
+}
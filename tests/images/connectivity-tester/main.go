@@ -0,0 +1,115 @@
+// Copyright (c) Ansible Project
+// GNU General Public License v3.0+ (see COPYING or https://www.gnu.org/licenses/gpl-3.0.txt)
+
+// Command connectivity-tester tries to reach a list of targets from inside
+// its container and reports the results as a single line of JSON on stdout:
+//
+//	{"targets":[{"target":"tcp://db:5432","ok":true,"duration_seconds":0.001},{"target":"https://example.com/","ok":false,"duration_seconds":0.002,"error":"dial tcp 93.184.215.14:443: connect: network is unreachable","errno":"ENETUNREACH"},{"target":"dns://example.com","ok":false,"duration_seconds":5,"error":"i/o timeout","timeout":true}]}
+//
+// This verifies network isolation functionally, for example of internal
+// networks or network_mode none, instead of relying on the container's
+// configuration. The targets are tried once each, concurrently. The
+// following targets are supported:
+//
+//	tcp://HOST:PORT     a TCP connection can be established
+//	http://..., https://...
+//	                    a GET request gets a response; its status is
+//	                    reported as status, but does not matter
+//	dns://NAME          NAME can be resolved; the addresses are reported as
+//	                    addresses
+//	dns://SERVER/NAME   NAME can be resolved by the DNS server SERVER (an
+//	                    address with an optional port, 53 by default)
+//
+// Failures are reported with the error message, the name of the system
+// error (like ENETUNREACH or ECONNREFUSED) as errno if there is one, and
+// timeout set to true if the target did not answer in time. Certificates of
+// HTTPS servers are not verified.
+//
+// Usage:
+//
+//	connectivity-tester [-timeout DURATION] [-interval DURATION] [-listen ADDRESS] [TARGET...]
+//
+// The default timeout for every target is 5s. With -interval, the targets
+// are tried again after every interval, and every report is printed as a
+// separate line, until the program receives SIGTERM or SIGINT. With -listen
+// (for example -listen :8080), the targets are additionally tried on every
+// HTTP GET request to ADDRESS, and the report is the response; target query
+// parameters replace the targets given on the command line, for example
+// /?target=tcp://db:5432&target=dns://db. Targets are required unless
+// -listen is given. The program always exits with status 0 if all targets
+// are valid, since failing to connect can be the expected result.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/ansible-collections/community.docker/tests/images/internal/fixture"
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("connectivity-tester: ")
+
+	timeout := flag.Duration("timeout", 5*time.Second, "time to wait for every target")
+	interval := flag.Duration("interval", 0, "try the targets again after this interval")
+	listen := flag.String("listen", "", "try the targets on every HTTP request to this address")
+	flag.Parse()
+
+	if flag.NArg() == 0 && *listen == "" {
+		fmt.Fprintln(flag.CommandLine.Output(), "usage: connectivity-tester [-timeout DURATION] [-interval DURATION] [-listen ADDRESS] [TARGET...]")
+		os.Exit(2)
+	}
+	targets := flag.Args()
+	for _, target := range targets {
+		if _, err := newChecker(target); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if *listen != "" {
+		fixture.Serve(*listen, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != http.MethodGet {
+				http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+				return
+			}
+			requested := targets
+			if query := r.URL.Query()["target"]; len(query) > 0 {
+				requested = query
+			}
+			rep, err := tryTargets(requested, *timeout)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			fixture.ServeJSON(w, rep)
+		}))
+	}
+
+	if len(targets) > 0 {
+		printReport(targets, *timeout)
+		if *interval > 0 {
+			go func() {
+				for {
+					time.Sleep(*interval)
+					printReport(targets, *timeout)
+				}
+			}()
+		}
+	}
+	if *listen != "" || (len(targets) > 0 && *interval > 0) {
+		fixture.WaitForSignal()
+	}
+}
+
+func printReport(targets []string, timeout time.Duration) {
+	r, err := tryTargets(targets, timeout)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fixture.WriteJSON(r)
+}
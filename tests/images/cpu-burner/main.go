@@ -0,0 +1,110 @@
+// Copyright (c) Ansible Project
+// GNU General Public License v3.0+ (see COPYING or https://www.gnu.org/licenses/gpl-3.0.txt)
+
+// Command cpu-burner generates a target CPU load, measured in CPUs, for a
+// given duration. A load of 1.5 keeps one CPU fully busy and a second one
+// busy half of the time. GOMAXPROCS is set to the number of CPUs needed, and
+// every worker goroutine is locked to its own OS thread.
+//
+// When the duration has passed, or SIGTERM or SIGINT is received, the
+// consumed CPU time is printed as a single line of JSON on stdout:
+//
+//	{"load":1.5,"workers":2,"elapsed_seconds":60.001,"cpu_seconds":59.874}
+//
+// If the container's CPU usage is restricted (for example with cpus or
+// cpu_quota), cpu_seconds divided by elapsed_seconds will be lower than load.
+//
+// Usage:
+//
+//	cpu-burner [-load CPUS] [-duration DURATION]
+//
+// The default load is 1. A duration of 0, the default, burns CPU until a
+// signal is received.
+package main
+
+import (
+	"flag"
+	"log"
+	"math"
+	"runtime"
+	"syscall"
+	"time"
+
+	"github.com/ansible-collections/community.docker/tests/images/internal/fixture"
+)
+
+// period is the time slice in which a partially loaded worker alternates
+// between burning CPU and sleeping.
+const period = 100 * time.Millisecond
+
+type result struct {
+	Load           float64 `json:"load"`
+	Workers        int     `json:"workers"`
+	ElapsedSeconds float64 `json:"elapsed_seconds"`
+	CPUSeconds     float64 `json:"cpu_seconds"`
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("cpu-burner: ")
+
+	load := flag.Float64("load", 1, "number of CPUs to keep busy (may be fractional)")
+	duration := flag.Duration("duration", 0, "how long to generate load (0 means until SIGTERM or SIGINT)")
+	flag.Parse()
+
+	if *load <= 0 {
+		log.Fatalf("invalid load %v", *load)
+	}
+	if *duration < 0 {
+		log.Fatalf("invalid duration %s", *duration)
+	}
+
+	workers := int(math.Ceil(*load))
+	runtime.GOMAXPROCS(workers)
+
+	start := time.Now()
+	for i := 0; i < workers; i++ {
+		share := math.Min(*load-float64(i), 1)
+		go burn(share)
+	}
+
+	signals := fixture.TerminationSignals()
+	var timeout <-chan time.Time
+	if *duration > 0 {
+		timeout = time.After(*duration)
+	}
+	select {
+	case <-signals:
+	case <-timeout:
+	}
+
+	var usage syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &usage); err != nil {
+		log.Fatalf("cannot get resource usage: %v", err)
+	}
+	fixture.WriteJSON(result{
+		Load:           *load,
+		Workers:        workers,
+		ElapsedSeconds: time.Since(start).Seconds(),
+		CPUSeconds:     timevalSeconds(usage.Utime) + timevalSeconds(usage.Stime),
+	})
+}
+
+// burn keeps the current OS thread busy for the given share (between 0 and
+// 1) of every period.
+func burn(share float64) {
+	runtime.LockOSThread()
+	busy := time.Duration(share * float64(period))
+	for {
+		deadline := time.Now().Add(busy)
+		for time.Now().Before(deadline) {
+		}
+		if busy < period {
+			time.Sleep(period - busy)
+		}
+	}
+}
+
+func timevalSeconds(tv syscall.Timeval) float64 {
+	return float64(tv.Sec) + float64(tv.Usec)/1e6
+}
@@ -0,0 +1,126 @@
+// Copyright (c) Ansible Project
+// GNU General Public License v3.0+ (see COPYING or https://www.gnu.org/licenses/gpl-3.0.txt)
+
+// Command crasher exits with a failure after a given uptime, so that restart
+// policies and restart counts can be tested. On startup, it decides whether
+// this run crashes and reports the run as a JSON line:
+//
+//	{"run":3,"started":"2021-05-01T12:00:00.123Z","hostname":"6f0c1a2b3c4d","seed":42,"crash":true,"exit_code":1,"uptime_seconds":2}
+//
+// A run that crashes exits with CODE after the uptime. A run that does not
+// crash keeps running until SIGTERM or SIGINT is received, and then exits
+// with status 0.
+//
+// Usage:
+//
+//	crasher [-exit-code CODE] [-uptime DURATION] [-probability P] [-seed N] [-crashes N] [-state FILE]
+//
+// The defaults are -exit-code 1, -uptime 1s and -probability 1, which
+// means that every run crashes. With a probability below 1, every run draws
+// a random number to decide; with -seed, the number drawn by each run is
+// derived from the seed and the run number, so that the sequence of crashes
+// is reproducible. With -crashes N, only the first N runs can crash.
+//
+// Runs are counted with the state file, to which every run appends its JSON
+// line; put it on a volume to count runs across container restarts, or
+// across containers. Without -state, every run is run 1.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"math/rand"
+	"os"
+	"time"
+
+	"github.com/ansible-collections/community.docker/tests/images/internal/fixture"
+)
+
+type run struct {
+	Run           int       `json:"run"`
+	Started       time.Time `json:"started"`
+	Hostname      string    `json:"hostname"`
+	Seed          int64     `json:"seed"`
+	Crash         bool      `json:"crash"`
+	ExitCode      int       `json:"exit_code"`
+	UptimeSeconds float64   `json:"uptime_seconds"`
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("crasher: ")
+
+	exitCode := flag.Int("exit-code", 1, "exit code of crashing runs")
+	uptime := flag.Duration("uptime", time.Second, "time after which a crashing run exits")
+	probability := flag.Float64("probability", 1, "probability that a run crashes")
+	seed := flag.Int64("seed", 0, "seed of the random numbers (0 means random)")
+	crashes := flag.Int("crashes", 0, "number of runs which can crash (0 means all)")
+	state := flag.String("state", "", "file counting the runs")
+	flag.Parse()
+
+	if *probability < 0 || *probability > 1 || *uptime < 0 || *crashes < 0 {
+		fmt.Fprintln(flag.CommandLine.Output(), "-probability must be between 0 and 1, -uptime and -crashes must not be negative")
+		os.Exit(2)
+	}
+
+	r := run{Run: 1, Started: time.Now().UTC(), Seed: *seed, UptimeSeconds: uptime.Seconds()}
+	r.Hostname, _ = os.Hostname()
+	if *state != "" {
+		runs, err := countRuns(*state)
+		if err != nil {
+			log.Fatal(err)
+		}
+		r.Run = runs + 1
+	}
+	if r.Seed == 0 {
+		r.Seed = time.Now().UnixNano()
+	}
+	roll := rand.New(rand.NewSource(r.Seed + int64(r.Run))).Float64()
+	r.Crash = roll < *probability && (*crashes == 0 || r.Run <= *crashes)
+	if r.Crash {
+		r.ExitCode = *exitCode
+	}
+
+	if *state != "" {
+		if err := appendRun(*state, r); err != nil {
+			log.Fatal(err)
+		}
+	}
+	fixture.WriteJSON(r)
+
+	if r.Crash {
+		time.Sleep(*uptime)
+		os.Exit(r.ExitCode)
+	}
+	fixture.WaitForSignal()
+}
+
+// countRuns returns the number of runs recorded in the state file.
+func countRuns(path string) (int, error) {
+	content, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, nil
+	}
+	return bytes.Count(content, []byte{'\n'}), err
+}
+
+func appendRun(path string, r run) error {
+	content, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(content, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,144 @@
+// Copyright (c) Ansible Project
+// GNU General Public License v3.0+ (see COPYING or https://www.gnu.org/licenses/gpl-3.0.txt)
+
+// Command credential-helper-mock implements the docker credential helper
+// protocol with a plain file instead of an OS keychain, so that docker_login
+// can be tested with credsStore and credHelpers. It is not an image; build it
+// as a binary named docker-credential-test, put its directory in PATH, and
+// configure the helper test in the Docker config file:
+//
+//	go build -o /tmp/credential-helper-mock/docker-credential-test ./credential-helper-mock
+//
+// The following actions are supported, as the first argument:
+//
+//	store    reads {"ServerURL":"...","Username":"...","Secret":"..."} from stdin and stores the credentials
+//	get      reads a server URL from stdin and prints the stored credentials in the same form
+//	erase    reads a server URL from stdin and removes its credentials
+//	list     prints a JSON object mapping the server URLs to the user names
+//	version  prints the version
+//
+// As with the real helpers, errors are printed on stdout and make the
+// program exit with 1. Unknown server URLs are reported as "credentials not
+// found in native keychain", which clients recognize.
+//
+// Since every action runs as a separate process, the credentials are kept in
+// a store file, given by the DOCKER_CREDENTIAL_TEST_FILE environment variable
+// (docker-credential-test.json in the temporary directory by default). The
+// store file also records every invocation under "invocations", with its
+// action and server URL, but never the secret:
+//
+//	{
+//	  "credentials": {"https://index.docker.io/v1/": {"Username": "user", "Secret": "secret"}},
+//	  "invocations": [{"action": "store", "server_url": "https://index.docker.io/v1/"}]
+//	}
+//
+// The DOCKER_CREDENTIAL_TEST_FAIL environment variable can hold a comma
+// separated list of actions which fail with "mock failure" without doing
+// anything else, to test the handling of broken helpers.
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+const version = "docker-credential-test (credential-helper-mock) v0.0.0"
+
+var (
+	errNotFound  = errors.New("credentials not found in native keychain")
+	errNoURL     = errors.New("no credentials server URL")
+	errNoUser    = errors.New("no credentials username")
+	errMockFails = errors.New("mock failure")
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout))
+}
+
+func run(args []string, stdin io.Reader, stdout io.Writer) int {
+	if len(args) != 1 {
+		fmt.Fprintln(stdout, "Usage: docker-credential-test <store|get|erase|list|version>")
+		return 1
+	}
+	action := args[0]
+	if action == "version" {
+		fmt.Fprintln(stdout, version)
+		return 0
+	}
+	if err := perform(action, stdin, stdout); err != nil {
+		fmt.Fprintln(stdout, err)
+		return 1
+	}
+	return 0
+}
+
+func perform(action string, stdin io.Reader, stdout io.Writer) error {
+	st, err := loadStore()
+	if err != nil {
+		return err
+	}
+
+	inv := invocation{Action: action}
+	var creds credentials
+	switch action {
+	case "store":
+		if err := json.NewDecoder(stdin).Decode(&creds); err != nil {
+			return err
+		}
+		inv.ServerURL = creds.ServerURL
+	case "get", "erase":
+		line, err := bufio.NewReader(stdin).ReadString('\n')
+		if err != nil && err != io.EOF {
+			return err
+		}
+		inv.ServerURL = strings.TrimSpace(line)
+	case "list":
+	default:
+		return fmt.Errorf("Unknown credential action `%s`", action)
+	}
+	st.Invocations = append(st.Invocations, inv)
+	if err := st.save(); err != nil {
+		return err
+	}
+
+	for _, failing := range strings.Split(os.Getenv("DOCKER_CREDENTIAL_TEST_FAIL"), ",") {
+		if strings.TrimSpace(failing) == action {
+			return errMockFails
+		}
+	}
+	if action != "list" && inv.ServerURL == "" {
+		return errNoURL
+	}
+
+	switch action {
+	case "store":
+		if creds.Username == "" {
+			return errNoUser
+		}
+		st.Credentials[creds.ServerURL] = secret{Username: creds.Username, Secret: creds.Secret}
+		return st.save()
+	case "get":
+		s, ok := st.Credentials[inv.ServerURL]
+		if !ok {
+			return errNotFound
+		}
+		return json.NewEncoder(stdout).Encode(credentials{ServerURL: inv.ServerURL, Username: s.Username, Secret: s.Secret})
+	case "erase":
+		if _, ok := st.Credentials[inv.ServerURL]; !ok {
+			return errNotFound
+		}
+		delete(st.Credentials, inv.ServerURL)
+		return st.save()
+	default:
+		users := make(map[string]string)
+		for url, s := range st.Credentials {
+			users[url] = s.Username
+		}
+		return json.NewEncoder(stdout).Encode(users)
+	}
+}
@@ -0,0 +1,163 @@
+// Copyright (c) Ansible Project
+// GNU General Public License v3.0+ (see COPYING or https://www.gnu.org/licenses/gpl-3.0.txt)
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+type step struct {
+	args   []string
+	stdin  string
+	rc     int
+	stdout string
+}
+
+// runSteps runs the steps in order against the same store file.
+func runSteps(t *testing.T, steps []step) {
+	for _, s := range steps {
+		var stdout bytes.Buffer
+		rc := run(s.args, strings.NewReader(s.stdin), &stdout)
+		if rc != s.rc {
+			t.Errorf("%q with %q: got rc %d, expected %d (stdout %q)", s.args, s.stdin, rc, s.rc, stdout.String())
+		}
+		if stdout.String() != s.stdout {
+			t.Errorf("%q with %q: got stdout %q, expected %q", s.args, s.stdin, stdout.String(), s.stdout)
+		}
+	}
+}
+
+func TestRun(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.json")
+	t.Setenv("DOCKER_CREDENTIAL_TEST_FILE", path)
+	t.Setenv("DOCKER_CREDENTIAL_TEST_FAIL", "")
+
+	runSteps(t, []step{
+		{[]string{"version"}, "", 0, version + "\n"},
+		{[]string{}, "", 1, "Usage: docker-credential-test <store|get|erase|list|version>\n"},
+		{[]string{"get", "extra"}, "", 1, "Usage: docker-credential-test <store|get|erase|list|version>\n"},
+		{[]string{"list"}, "", 0, "{}\n"},
+		{[]string{"store"}, `{"ServerURL":"https://index.docker.io/v1/","Username":"user","Secret":"secret"}`, 0, ""},
+		{[]string{"store"}, `{"ServerURL":"registry.example.com","Username":"other","Secret":"pass"}` + "\n", 0, ""},
+		{[]string{"get"}, "https://index.docker.io/v1/\n", 0,
+			`{"ServerURL":"https://index.docker.io/v1/","Username":"user","Secret":"secret"}` + "\n"},
+		// Clients do not necessarily terminate the URL with a newline.
+		{[]string{"get"}, "registry.example.com", 0,
+			`{"ServerURL":"registry.example.com","Username":"other","Secret":"pass"}` + "\n"},
+		{[]string{"list"}, "", 0, `{"https://index.docker.io/v1/":"user","registry.example.com":"other"}` + "\n"},
+		// Storing credentials again replaces them.
+		{[]string{"store"}, `{"ServerURL":"registry.example.com","Username":"new","Secret":"newpass"}`, 0, ""},
+		{[]string{"get"}, "registry.example.com\n", 0,
+			`{"ServerURL":"registry.example.com","Username":"new","Secret":"newpass"}` + "\n"},
+		{[]string{"erase"}, "registry.example.com", 0, ""},
+		{[]string{"get"}, "registry.example.com\n", 1, "credentials not found in native keychain\n"},
+		{[]string{"erase"}, "registry.example.com\n", 1, "credentials not found in native keychain\n"},
+		{[]string{"get"}, "unknown.example.com\n", 1, "credentials not found in native keychain\n"},
+		{[]string{"list"}, "", 0, `{"https://index.docker.io/v1/":"user"}` + "\n"},
+		{[]string{"get"}, "\n", 1, "no credentials server URL\n"},
+		{[]string{"erase"}, "", 1, "no credentials server URL\n"},
+		{[]string{"store"}, `{"ServerURL":"","Username":"user","Secret":"secret"}`, 1, "no credentials server URL\n"},
+		{[]string{"store"}, `{"ServerURL":"registry.example.com","Secret":"secret"}`, 1, "no credentials username\n"},
+		{[]string{"store"}, "{", 1, "unexpected EOF\n"},
+		{[]string{"login"}, "", 1, "Unknown credential action `login`\n"},
+	})
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var st store
+	if err := json.Unmarshal(content, &st); err != nil {
+		t.Fatal(err)
+	}
+	// Invocations with invalid arguments or input are not recorded.
+	expected := []invocation{
+		{"list", ""},
+		{"store", "https://index.docker.io/v1/"},
+		{"store", "registry.example.com"},
+		{"get", "https://index.docker.io/v1/"},
+		{"get", "registry.example.com"},
+		{"list", ""},
+		{"store", "registry.example.com"},
+		{"get", "registry.example.com"},
+		{"erase", "registry.example.com"},
+		{"get", "registry.example.com"},
+		{"erase", "registry.example.com"},
+		{"get", "unknown.example.com"},
+		{"list", ""},
+		{"get", ""},
+		{"erase", ""},
+		{"store", ""},
+		{"store", "registry.example.com"},
+	}
+	if len(st.Invocations) != len(expected) {
+		t.Fatalf("got invocations %v, expected %v", st.Invocations, expected)
+	}
+	for i := range expected {
+		if st.Invocations[i] != expected[i] {
+			t.Errorf("invocation %d: got %#v, expected %#v", i, st.Invocations[i], expected[i])
+		}
+	}
+	_, invocations, _ := strings.Cut(string(content), `"invocations"`)
+	if strings.Contains(invocations, "secret") || strings.Contains(invocations, "pass") {
+		t.Errorf("store file records a secret in the invocations:\n%s", content)
+	}
+}
+
+func TestRunFail(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "store.json")
+	t.Setenv("DOCKER_CREDENTIAL_TEST_FILE", path)
+	t.Setenv("DOCKER_CREDENTIAL_TEST_FAIL", "get, erase")
+
+	runSteps(t, []step{
+		{[]string{"store"}, `{"ServerURL":"registry.example.com","Username":"user","Secret":"secret"}`, 0, ""},
+		{[]string{"get"}, "registry.example.com\n", 1, "mock failure\n"},
+		{[]string{"erase"}, "registry.example.com\n", 1, "mock failure\n"},
+		// Failing actions do not change the credentials.
+		{[]string{"list"}, "", 0, `{"registry.example.com":"user"}` + "\n"},
+		{[]string{"version"}, "", 0, version + "\n"},
+	})
+
+	t.Setenv("DOCKER_CREDENTIAL_TEST_FAIL", "list")
+	runSteps(t, []step{
+		{[]string{"list"}, "", 1, "mock failure\n"},
+		{[]string{"get"}, "registry.example.com", 0,
+			`{"ServerURL":"registry.example.com","Username":"user","Secret":"secret"}` + "\n"},
+	})
+}
+
+func TestRunStoreFile(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("TMPDIR", dir)
+	t.Setenv("DOCKER_CREDENTIAL_TEST_FILE", "")
+	t.Setenv("DOCKER_CREDENTIAL_TEST_FAIL", "")
+
+	// Without DOCKER_CREDENTIAL_TEST_FILE, the store file is kept in the
+	// temporary directory.
+	runSteps(t, []step{
+		{[]string{"store"}, `{"ServerURL":"registry.example.com","Username":"user","Secret":"secret"}`, 0, ""},
+		{[]string{"list"}, "", 0, `{"registry.example.com":"user"}` + "\n"},
+	})
+	info, err := os.Stat(filepath.Join(dir, "docker-credential-test.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0o600 {
+		t.Errorf("store file has mode %v, expected -rw-------", info.Mode())
+	}
+
+	invalid := filepath.Join(dir, "invalid.json")
+	if err := os.WriteFile(invalid, []byte("{"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("DOCKER_CREDENTIAL_TEST_FILE", invalid)
+	runSteps(t, []step{
+		{[]string{"list"}, "", 1, "cannot parse " + invalid + ": unexpected end of JSON input\n"},
+	})
+}
@@ -0,0 +1,66 @@
+// Copyright (c) Ansible Project
+// GNU General Public License v3.0+ (see COPYING or https://www.gnu.org/licenses/gpl-3.0.txt)
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// credentials is the form in which credentials are exchanged with clients.
+type credentials struct {
+	ServerURL string
+	Username  string
+	Secret    string
+}
+
+type secret struct {
+	Username string
+	Secret   string
+}
+
+type invocation struct {
+	Action    string `json:"action"`
+	ServerURL string `json:"server_url,omitempty"`
+}
+
+type store struct {
+	Credentials map[string]secret `json:"credentials"`
+	Invocations []invocation      `json:"invocations"`
+
+	path string
+}
+
+func loadStore() (*store, error) {
+	path := os.Getenv("DOCKER_CREDENTIAL_TEST_FILE")
+	if path == "" {
+		path = filepath.Join(os.TempDir(), "docker-credential-test.json")
+	}
+	st := &store{path: path}
+	content, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	if err == nil {
+		if err := json.Unmarshal(content, st); err != nil {
+			return nil, fmt.Errorf("cannot parse %s: %v", path, err)
+		}
+	}
+	if st.Credentials == nil {
+		st.Credentials = make(map[string]secret)
+	}
+	return st, nil
+}
+
+func (st *store) save() error {
+	content, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(st.path, append(content, '\n'), 0o600)
+}
@@ -0,0 +1,140 @@
+// Copyright (c) Ansible Project
+// GNU General Public License v3.0+ (see COPYING or https://www.gnu.org/licenses/gpl-3.0.txt)
+
+// Command device-tester reports which device nodes exist in its container,
+// and whether they can be opened, as a single line of JSON on stdout:
+//
+//	{"devices":[{"path":"/dev/fuse","exists":true,"type":"char","major":10,"minor":229,"mode":"0666","uid":0,"gid":0,"readable":true,"writable":false,"write_error":"operation not permitted"}]}
+//
+// The arguments name the device nodes to test. Each is opened for reading
+// and for writing, without blocking, and closed right away; the errors are
+// reported if that fails. Opening is what the devices cgroup controller
+// restricts, so this reveals the effect of device_cgroup_rules even for nodes
+// which exist.
+//
+// Without arguments, all device nodes below /dev are reported, but not
+// opened, since opening some devices has side effects.
+//
+// Usage:
+//
+//	device-tester [-keep-running] [DEVICE...]
+//
+// With -keep-running, the program stays alive after printing until it
+// receives SIGTERM or SIGINT.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"github.com/ansible-collections/community.docker/tests/images/internal/fixture"
+)
+
+type device struct {
+	Path       string `json:"path"`
+	Exists     bool   `json:"exists"`
+	Type       string `json:"type,omitempty"`
+	Major      uint32 `json:"major"`
+	Minor      uint32 `json:"minor"`
+	Mode       string `json:"mode,omitempty"`
+	UID        uint32 `json:"uid"`
+	GID        uint32 `json:"gid"`
+	Readable   *bool  `json:"readable,omitempty"`
+	ReadError  string `json:"read_error,omitempty"`
+	Writable   *bool  `json:"writable,omitempty"`
+	WriteError string `json:"write_error,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+type report struct {
+	Devices []device `json:"devices"`
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("device-tester: ")
+
+	keepRunning := flag.Bool("keep-running", false, "keep running after printing until SIGTERM or SIGINT")
+	flag.Parse()
+
+	r := report{Devices: []device{}}
+	if flag.NArg() == 0 {
+		err := filepath.WalkDir("/dev", func(path string, entry fs.DirEntry, err error) error {
+			if err != nil {
+				// Unreadable directories are skipped.
+				return nil
+			}
+			if entry.Type()&fs.ModeDevice != 0 {
+				r.Devices = append(r.Devices, statDevice(path))
+			}
+			return nil
+		})
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+	for _, path := range flag.Args() {
+		d := statDevice(path)
+		if d.Exists {
+			d.Readable, d.ReadError = tryOpen(path, os.O_RDONLY)
+			d.Writable, d.WriteError = tryOpen(path, os.O_WRONLY)
+		}
+		r.Devices = append(r.Devices, d)
+	}
+	fixture.WriteJSON(r)
+
+	if *keepRunning {
+		fixture.WaitForSignal()
+	}
+}
+
+func statDevice(path string) device {
+	d := device{Path: path}
+	var stat syscall.Stat_t
+	if err := syscall.Stat(path, &stat); err != nil {
+		if err != syscall.ENOENT {
+			d.Error = err.Error()
+		}
+		return d
+	}
+	d.Exists = true
+	switch stat.Mode & syscall.S_IFMT {
+	case syscall.S_IFCHR:
+		d.Type = "char"
+	case syscall.S_IFBLK:
+		d.Type = "block"
+	default:
+		d.Type = "other"
+	}
+	d.Major = major(stat.Rdev)
+	d.Minor = minor(stat.Rdev)
+	d.Mode = fmt.Sprintf("%04o", stat.Mode&0o7777)
+	d.UID = stat.Uid
+	d.GID = stat.Gid
+	return d
+}
+
+// major and minor decode device numbers like the C library's macros.
+func major(dev uint64) uint32 {
+	return uint32((dev>>8)&0xfff) | uint32((dev>>32)&^0xfff)
+}
+
+func minor(dev uint64) uint32 {
+	return uint32(dev&0xff) | uint32((dev>>12)&^0xff)
+}
+
+func tryOpen(path string, flag int) (*bool, string) {
+	ok := false
+	fd, err := syscall.Open(path, flag|syscall.O_NONBLOCK|syscall.O_CLOEXEC|syscall.O_NOCTTY, 0)
+	if err != nil {
+		return &ok, err.Error()
+	}
+	syscall.Close(fd)
+	ok = true
+	return &ok, ""
+}
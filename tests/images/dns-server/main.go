@@ -0,0 +1,208 @@
+// Copyright (c) Ansible Project
+// GNU General Public License v3.0+ (see COPYING or https://www.gnu.org/licenses/gpl-3.0.txt)
+
+// Command dns-server is a tiny authoritative DNS server answering queries
+// over UDP and TCP from a static zone file, for example:
+//
+//	$ORIGIN example.com.
+//	$TTL 60
+//	@      IN A     10.1.0.1
+//	web       A     10.1.0.2
+//	www       CNAME web
+//	info      TXT   "served by dns-server"
+//
+// The zone file uses a subset of the master file format: one record per
+// line, the $ORIGIN and $TTL directives, and the types A, AAAA, CNAME, PTR
+// and TXT. The server is authoritative for all names; names without records
+// are answered with NXDOMAIN. CNAME records are followed within the zone.
+// Recursion is not available.
+//
+// Every query is logged to stderr and recorded. GET /queries returns the
+// recorded queries as JSON list, and DELETE /queries clears it:
+//
+//	[{"time":"2021-05-01T12:00:00.123Z","client":"172.18.0.3:41234","transport":"udp","name":"www.example.com.","type":"A","rcode":"NOERROR","answers":[{"name":"www.example.com.","ttl":60,"type":"CNAME","value":"web.example.com."},{"name":"web.example.com.","ttl":60,"type":"A","value":"10.1.0.2"}]}]
+//
+// Usage:
+//
+//	dns-server -zone FILE [-listen ADDRESS] [-http ADDRESS]
+//
+// The defaults are -listen :53, which is used for UDP and TCP, and -http
+// :8080. The program runs until it receives SIGTERM or SIGINT.
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"flag"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ansible-collections/community.docker/tests/images/internal/fixture"
+)
+
+// Maximum sizes of responses. Over UDP, EDNS is not supported.
+const (
+	maxUDPSize = 512
+	maxTCPSize = 65535
+)
+
+type loggedQuery struct {
+	Time      time.Time `json:"time"`
+	Client    string    `json:"client"`
+	Transport string    `json:"transport"`
+	Name      string    `json:"name"`
+	Type      string    `json:"type"`
+	RCode     string    `json:"rcode"`
+	Answers   []*record `json:"answers"`
+}
+
+type server struct {
+	zone zone
+
+	mu      sync.Mutex
+	queries []loggedQuery
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("dns-server: ")
+
+	zoneFile := flag.String("zone", "", "zone file to serve")
+	listen := flag.String("listen", ":53", "UDP and TCP address to answer queries on")
+	httpAddress := flag.String("http", ":8080", "address to serve the query log on")
+	flag.Parse()
+
+	if *zoneFile == "" {
+		log.Fatal("-zone must be given")
+	}
+	z, err := readZone(*zoneFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	s := &server{zone: z}
+
+	conn, err := net.ListenPacket("udp", *listen)
+	if err != nil {
+		log.Fatal(err)
+	}
+	go s.serveUDP(conn)
+	listener, err := net.Listen("tcp", *listen)
+	if err != nil {
+		log.Fatal(err)
+	}
+	go s.serveTCP(listener)
+
+	mux := http.NewServeMux()
+	mux.Handle("/queries", fixture.Control{Get: s.listQueries, Clear: s.clearQueries})
+	fixture.Serve(*httpAddress, mux)
+	fixture.WaitForSignal()
+}
+
+func (s *server) serveUDP(conn net.PacketConn) {
+	buf := make([]byte, 65536)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			log.Fatal(err)
+		}
+		resp := s.answer(buf[:n], addr.String(), "udp")
+		if resp != nil {
+			conn.WriteTo(resp, addr)
+		}
+	}
+}
+
+func (s *server) serveTCP(listener net.Listener) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			log.Fatal(err)
+		}
+		go func() {
+			defer conn.Close()
+			r := bufio.NewReader(conn)
+			for {
+				// Messages are preceded by their length.
+				var length uint16
+				if err := binary.Read(r, binary.BigEndian, &length); err != nil {
+					return
+				}
+				msg := make([]byte, length)
+				if _, err := io.ReadFull(r, msg); err != nil {
+					return
+				}
+				resp := s.answer(msg, conn.RemoteAddr().String(), "tcp")
+				if resp == nil {
+					return
+				}
+				if _, err := conn.Write(binary.BigEndian.AppendUint16(nil, uint16(len(resp)))); err != nil {
+					return
+				}
+				if _, err := conn.Write(resp); err != nil {
+					return
+				}
+			}
+		}()
+	}
+}
+
+// answer returns the response to a query, or nil if it is not a query.
+func (s *server) answer(msg []byte, client, transport string) []byte {
+	id, flags, q, err := parseQuery(msg)
+	if err == errTruncated || (err == nil && flags&0x8000 != 0) {
+		return nil
+	}
+	if err != nil {
+		log.Printf("%s %s: %v", transport, client, err)
+		return buildResponse(id, flags, nil, nil, rcodeFormatError, maxUDPSize)
+	}
+	answers, rcode := []*record{}, rcodeNotImplemented
+	if flags&0x7800 == 0 {
+		answers, rcode = s.zone.resolve(q)
+	}
+	maxSize := maxTCPSize
+	if transport == "udp" {
+		maxSize = maxUDPSize
+	}
+	resp := buildResponse(id, flags, q, answers, rcode, maxSize)
+
+	typeName, ok := typeNames[q.qtype]
+	if !ok {
+		typeName = "TYPE" + strconv.Itoa(int(q.qtype))
+	}
+	if answers == nil {
+		answers = []*record{}
+	}
+	entry := loggedQuery{
+		Time:      time.Now().UTC(),
+		Client:    client,
+		Transport: transport,
+		Name:      q.name,
+		Type:      typeName,
+		RCode:     rcodeNames[rcode],
+		Answers:   answers,
+	}
+	log.Printf("%s %s: %s %s: %s, %d answers", transport, client, entry.Name, entry.Type, entry.RCode, len(answers))
+	s.mu.Lock()
+	s.queries = append(s.queries, entry)
+	s.mu.Unlock()
+	return resp
+}
+
+// listQueries returns a snapshot of the query log for the /queries endpoint.
+func (s *server) listQueries(*http.Request) interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]loggedQuery{}, s.queries...)
+}
+
+func (s *server) clearQueries() {
+	s.mu.Lock()
+	s.queries = nil
+	s.mu.Unlock()
+}
@@ -0,0 +1,173 @@
+// Copyright (c) Ansible Project
+// GNU General Public License v3.0+ (see COPYING or https://www.gnu.org/licenses/gpl-3.0.txt)
+
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Response codes.
+const (
+	rcodeSuccess        = 0
+	rcodeFormatError    = 1
+	rcodeNameError      = 3
+	rcodeNotImplemented = 4
+)
+
+var rcodeNames = map[int]string{
+	rcodeSuccess:        "NOERROR",
+	rcodeFormatError:    "FORMERR",
+	rcodeNameError:      "NXDOMAIN",
+	rcodeNotImplemented: "NOTIMP",
+}
+
+const classIN = 1
+
+// maxCNAMEChain limits the number of CNAME records followed.
+const maxCNAMEChain = 8
+
+// maxNameLength is the maximum length of an encoded name, including the
+// terminating zero length label.
+const maxNameLength = 255
+
+type question struct {
+	name   string
+	qtype  uint16
+	qclass uint16
+}
+
+var errTruncated = errors.New("message truncated")
+
+// parseQuery returns the ID, flags and the question of a query.
+func parseQuery(msg []byte) (uint16, uint16, *question, error) {
+	if len(msg) < 12 {
+		return 0, 0, nil, errTruncated
+	}
+	id := binary.BigEndian.Uint16(msg[0:2])
+	flags := binary.BigEndian.Uint16(msg[2:4])
+	if binary.BigEndian.Uint16(msg[4:6]) != 1 {
+		return id, flags, nil, fmt.Errorf("expected exactly one question")
+	}
+	var labels []string
+	offset := 12
+	for {
+		if offset >= len(msg) {
+			return id, flags, nil, errTruncated
+		}
+		length := int(msg[offset])
+		offset++
+		if length == 0 {
+			break
+		}
+		if length > 63 || offset+length > len(msg) {
+			// Queries do not use compression.
+			return id, flags, nil, fmt.Errorf("invalid label")
+		}
+		if offset+length-12 > maxNameLength {
+			return id, flags, nil, fmt.Errorf("name too long")
+		}
+		labels = append(labels, string(msg[offset:offset+length]))
+		offset += length
+	}
+	if offset+4 > len(msg) {
+		return id, flags, nil, errTruncated
+	}
+	q := &question{
+		name:   strings.Join(labels, ".") + ".",
+		qtype:  binary.BigEndian.Uint16(msg[offset : offset+2]),
+		qclass: binary.BigEndian.Uint16(msg[offset+2 : offset+4]),
+	}
+	return id, flags, q, nil
+}
+
+// encodeName encodes a fully qualified name without compression.
+func encodeName(name string) ([]byte, error) {
+	var buf []byte
+	for _, label := range strings.Split(strings.TrimSuffix(name, "."), ".") {
+		if label == "" {
+			continue
+		}
+		if len(label) > 63 {
+			return nil, fmt.Errorf("label %q too long", label)
+		}
+		buf = append(append(buf, byte(len(label))), label...)
+	}
+	if len(buf)+1 > maxNameLength {
+		return nil, fmt.Errorf("name %q too long", name)
+	}
+	return append(buf, 0), nil
+}
+
+// resolve answers a question from the zone. It returns the answer records
+// and the response code.
+func (z zone) resolve(q *question) ([]*record, int) {
+	if q.qclass != classIN {
+		return nil, rcodeNotImplemented
+	}
+	var answers []*record
+	name := q.name
+	for i := 0; i <= maxCNAMEChain; i++ {
+		records, ok := z[strings.ToLower(name)]
+		if !ok {
+			if len(answers) > 0 {
+				// The target of a CNAME is outside of the zone.
+				return answers, rcodeSuccess
+			}
+			return nil, rcodeNameError
+		}
+		var cname *record
+		for _, r := range records {
+			if r.rrtype == q.qtype {
+				answers = append(answers, r)
+			} else if r.rrtype == typeCNAME {
+				cname = r
+			}
+		}
+		if cname == nil || q.qtype == typeCNAME {
+			return answers, rcodeSuccess
+		}
+		answers = append(answers, cname)
+		name = cname.Value
+	}
+	return answers, rcodeSuccess
+}
+
+// buildResponse encodes the response to a query. Answer names are written
+// uncompressed. If the answers do not fit into maxSize bytes, they are left
+// out and the TC flag is set.
+func buildResponse(id, queryFlags uint16, q *question, answers []*record, rcode int, maxSize int) []byte {
+	// QR, the opcode and RD are taken from the query, AA is set.
+	flags := uint16(0x8000) | queryFlags&0x7900 | 0x0400 | uint16(rcode)
+	msg := make([]byte, 12, 512)
+	binary.BigEndian.PutUint16(msg[0:2], id)
+	binary.BigEndian.PutUint16(msg[2:4], flags)
+	if q == nil {
+		return msg
+	}
+	binary.BigEndian.PutUint16(msg[4:6], 1)
+	binary.BigEndian.PutUint16(msg[6:8], uint16(len(answers)))
+	qname, _ := encodeName(q.name)
+	msg = append(msg, qname...)
+	msg = binary.BigEndian.AppendUint16(msg, q.qtype)
+	msg = binary.BigEndian.AppendUint16(msg, q.qclass)
+	questionEnd := len(msg)
+	for _, r := range answers {
+		name, _ := encodeName(r.Name)
+		msg = append(msg, name...)
+		msg = binary.BigEndian.AppendUint16(msg, r.rrtype)
+		msg = binary.BigEndian.AppendUint16(msg, classIN)
+		msg = binary.BigEndian.AppendUint32(msg, r.TTL)
+		msg = binary.BigEndian.AppendUint16(msg, uint16(len(r.data)))
+		msg = append(msg, r.data...)
+	}
+	if len(msg) > maxSize {
+		msg = msg[:questionEnd]
+		msg[2] |= 0x02
+		binary.BigEndian.PutUint16(msg[6:8], 0)
+	}
+	return msg
+}
@@ -0,0 +1,180 @@
+// Copyright (c) Ansible Project
+// GNU General Public License v3.0+ (see COPYING or https://www.gnu.org/licenses/gpl-3.0.txt)
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// buildQuery returns a query for an already encoded name.
+func buildQuery(qname []byte, qtype uint16) []byte {
+	msg := []byte{0x12, 0x34, 0x01, 0x00, 0, 1, 0, 0, 0, 0, 0, 0}
+	msg = append(msg, qname...)
+	msg = binary.BigEndian.AppendUint16(msg, qtype)
+	return binary.BigEndian.AppendUint16(msg, classIN)
+}
+
+func TestParseQuery(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		qname    []byte
+		expected string
+	}{
+		{"root", []byte{0}, "."},
+		{"single label", []byte{3, 'c', 'o', 'm', 0}, "com."},
+		{"two labels", []byte{3, 'w', 'w', 'w', 7, 'e', 'x', 'a', 'm', 'p', 'l', 'e', 0}, "www.example."},
+		{"longest label", append(append([]byte{63}, bytes.Repeat([]byte{'a'}, 63)...), 0), strings.Repeat("a", 63) + "."},
+	} {
+		id, flags, q, err := parseQuery(buildQuery(tc.qname, typeA))
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", tc.name, err)
+			continue
+		}
+		expected := &question{name: tc.expected, qtype: typeA, qclass: classIN}
+		if id != 0x1234 || flags != 0x0100 || !reflect.DeepEqual(q, expected) {
+			t.Errorf("%s: got %#x, %#x, %#v, expected 0x1234, 0x100, %#v", tc.name, id, flags, q, expected)
+		}
+	}
+}
+
+func TestParseQueryErrors(t *testing.T) {
+	longName := bytes.Repeat(append([]byte{63}, bytes.Repeat([]byte{'a'}, 63)...), 4)
+	for _, tc := range []struct {
+		name string
+		msg  []byte
+		err  string
+	}{
+		{"short header", []byte{0x12, 0x34, 0x01}, "message truncated"},
+		{"no question", []byte{0x12, 0x34, 0x01, 0x00, 0, 0, 0, 0, 0, 0, 0, 0}, "exactly one question"},
+		{"two questions", []byte{0x12, 0x34, 0x01, 0x00, 0, 2, 0, 0, 0, 0, 0, 0, 0, 0, 1, 0, 1}, "exactly one question"},
+		{"missing name", buildQuery(nil, typeA)[:12], "message truncated"},
+		{"label too long", buildQuery(append(append([]byte{64}, bytes.Repeat([]byte{'a'}, 64)...), 0), typeA), "invalid label"},
+		{"compression pointer", buildQuery([]byte{0xc0, 0x0c}, typeA), "invalid label"},
+		{"label beyond end", []byte{0x12, 0x34, 0x01, 0x00, 0, 1, 0, 0, 0, 0, 0, 0, 5, 'a', 'b'}, "invalid label"},
+		{"missing terminator", []byte{0x12, 0x34, 0x01, 0x00, 0, 1, 0, 0, 0, 0, 0, 0, 1, 'a'}, "message truncated"},
+		{"name too long", buildQuery(append(longName, 0), typeA), "name too long"},
+		{"missing type", buildQuery([]byte{0}, typeA)[:14], "message truncated"},
+	} {
+		_, _, q, err := parseQuery(tc.msg)
+		if err == nil {
+			t.Errorf("%s: got %#v, expected an error", tc.name, q)
+		} else if !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("%s: got error %q, expected %q", tc.name, err, tc.err)
+		}
+	}
+}
+
+func TestResolve(t *testing.T) {
+	a := &record{Name: "web.example.com.", Type: "A", Value: "10.1.0.2", rrtype: typeA, data: []byte{10, 1, 0, 2}}
+	www := &record{Name: "www.example.com.", Type: "CNAME", Value: "web.example.com.", rrtype: typeCNAME}
+	outside := &record{Name: "ext.example.com.", Type: "CNAME", Value: "example.org.", rrtype: typeCNAME}
+	chain := &record{Name: "chain.example.com.", Type: "CNAME", Value: "ext.example.com.", rrtype: typeCNAME}
+	loop := &record{Name: "loop.example.com.", Type: "CNAME", Value: "loop.example.com.", rrtype: typeCNAME}
+	root := &record{Name: ".", Type: "TXT", Value: "root", rrtype: typeTXT}
+	z := zone{
+		"web.example.com.":   {a},
+		"www.example.com.":   {www},
+		"ext.example.com.":   {outside},
+		"chain.example.com.": {chain},
+		"loop.example.com.":  {loop},
+		".":                  {root},
+	}
+
+	for _, tc := range []struct {
+		name     string
+		q        question
+		expected []*record
+		rcode    int
+	}{
+		{"address", question{"web.example.com.", typeA, classIN}, []*record{a}, rcodeSuccess},
+		{"case insensitive", question{"WEB.Example.COM.", typeA, classIN}, []*record{a}, rcodeSuccess},
+		{"other type", question{"web.example.com.", typeAAAA, classIN}, nil, rcodeSuccess},
+		{"unknown name", question{"nope.example.com.", typeA, classIN}, nil, rcodeNameError},
+		{"root", question{".", typeTXT, classIN}, []*record{root}, rcodeSuccess},
+		{"other class", question{"web.example.com.", typeA, 3}, nil, rcodeNotImplemented},
+		{"cname", question{"www.example.com.", typeA, classIN}, []*record{www, a}, rcodeSuccess},
+		{"cname query", question{"www.example.com.", typeCNAME, classIN}, []*record{www}, rcodeSuccess},
+		// The target outside of the zone is left to the resolver.
+		{"cname leaving zone", question{"ext.example.com.", typeA, classIN}, []*record{outside}, rcodeSuccess},
+		{"cname chain leaving zone", question{"chain.example.com.", typeA, classIN}, []*record{chain, outside}, rcodeSuccess},
+	} {
+		answers, rcode := z.resolve(&tc.q)
+		if len(answers) == 0 && len(tc.expected) == 0 {
+			answers, tc.expected = nil, nil
+		}
+		if rcode != tc.rcode || !reflect.DeepEqual(answers, tc.expected) {
+			t.Errorf("%s: got %v, %d, expected %v, %d", tc.name, answers, rcode, tc.expected, tc.rcode)
+		}
+	}
+
+	answers, rcode := z.resolve(&question{"loop.example.com.", typeA, classIN})
+	if rcode != rcodeSuccess || len(answers) != maxCNAMEChain+1 {
+		t.Errorf("loop: got %d answers, %d, expected %d answers", len(answers), rcode, maxCNAMEChain+1)
+	}
+}
+
+func TestBuildResponse(t *testing.T) {
+	q := &question{name: "web.example.com.", qtype: typeA, qclass: classIN}
+	a := &record{Name: "web.example.com.", TTL: 60, rrtype: typeA, data: []byte{10, 1, 0, 2}}
+	resp := buildResponse(0x1234, 0x0100, q, []*record{a}, rcodeSuccess, maxUDPSize)
+
+	qname, _ := encodeName(q.name)
+	expected := []byte{0x12, 0x34, 0x85, 0x00, 0, 1, 0, 1, 0, 0, 0, 0}
+	expected = append(expected, qname...)
+	expected = append(expected, 0, typeA, 0, classIN)
+	expected = append(expected, qname...)
+	expected = append(expected, 0, typeA, 0, classIN, 0, 0, 0, 60, 0, 4, 10, 1, 0, 2)
+	if !bytes.Equal(resp, expected) {
+		t.Errorf("got %v, expected %v", resp, expected)
+	}
+
+	root := buildResponse(0x1234, 0x0100, &question{name: ".", qtype: typeA, qclass: classIN}, nil, rcodeNameError, maxUDPSize)
+	expected = []byte{0x12, 0x34, 0x85, 0x03, 0, 1, 0, 0, 0, 0, 0, 0, 0, 0, typeA, 0, classIN}
+	if !bytes.Equal(root, expected) {
+		t.Errorf("root: got %v, expected %v", root, expected)
+	}
+
+	formatError := buildResponse(0x1234, 0x0100, nil, nil, rcodeFormatError, maxUDPSize)
+	expected = []byte{0x12, 0x34, 0x85, 0x01, 0, 0, 0, 0, 0, 0, 0, 0}
+	if !bytes.Equal(formatError, expected) {
+		t.Errorf("format error: got %v, expected %v", formatError, expected)
+	}
+}
+
+func TestBuildResponseTruncated(t *testing.T) {
+	q := &question{name: "many.example.com.", qtype: typeA, qclass: classIN}
+	var answers []*record
+	for i := 0; i < 40; i++ {
+		ip := net.IPv4(10, 1, 0, byte(i)).To4()
+		answers = append(answers, &record{Name: q.name, TTL: 60, rrtype: typeA, data: ip})
+	}
+
+	full := buildResponse(0x1234, 0x0100, q, answers, rcodeSuccess, maxTCPSize)
+	if len(full) <= maxUDPSize {
+		t.Fatalf("response of %d bytes is not larger than %d bytes", len(full), maxUDPSize)
+	}
+	if full[2]&0x02 != 0 || binary.BigEndian.Uint16(full[6:8]) != 40 {
+		t.Errorf("TCP response is truncated: flags %#x, %d answers", full[2:4], binary.BigEndian.Uint16(full[6:8]))
+	}
+
+	resp := buildResponse(0x1234, 0x0100, q, answers, rcodeSuccess, maxUDPSize)
+	qname, _ := encodeName(q.name)
+	if len(resp) != 12+len(qname)+4 {
+		t.Errorf("UDP response has %d bytes, expected only the question", len(resp))
+	}
+	if resp[2]&0x02 == 0 {
+		t.Errorf("UDP response does not have the TC flag: %#x", resp[2:4])
+	}
+	if count := binary.BigEndian.Uint16(resp[6:8]); count != 0 {
+		t.Errorf("UDP response has an answer count of %d", count)
+	}
+	if !bytes.Equal(resp[12:], full[12:len(resp)]) {
+		t.Errorf("UDP response has the question %v, expected %v", resp[12:], full[12:len(resp)])
+	}
+}
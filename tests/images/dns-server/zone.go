@@ -0,0 +1,205 @@
+// Copyright (c) Ansible Project
+// GNU General Public License v3.0+ (see COPYING or https://www.gnu.org/licenses/gpl-3.0.txt)
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Record types supported in zone files.
+const (
+	typeA     = 1
+	typeCNAME = 5
+	typePTR   = 12
+	typeTXT   = 16
+	typeAAAA  = 28
+)
+
+var typeNames = map[uint16]string{
+	typeA:     "A",
+	typeCNAME: "CNAME",
+	typePTR:   "PTR",
+	typeTXT:   "TXT",
+	typeAAAA:  "AAAA",
+}
+
+type record struct {
+	Name  string `json:"name"`
+	TTL   uint32 `json:"ttl"`
+	Type  string `json:"type"`
+	Value string `json:"value"`
+
+	rrtype uint16
+	data   []byte
+}
+
+// zone maps lower-case fully qualified names to their records.
+type zone map[string][]*record
+
+// readZone parses a zone file in a subset of the master file format of
+// RFC 1035: one record per line in the form NAME [TTL] [IN] TYPE VALUE, with
+// the $ORIGIN and $TTL directives, @ for the origin, relative names, and
+// comments starting with a semicolon. Parentheses and omitted names are not
+// supported.
+func readZone(path string) (zone, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	z := make(zone)
+	origin := "."
+	ttl := uint32(3600)
+	scanner := bufio.NewScanner(f)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := stripComment(scanner.Text())
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		fail := func(format string, args ...interface{}) error {
+			return fmt.Errorf("%s:%d: %s", path, lineNumber, fmt.Sprintf(format, args...))
+		}
+		switch fields[0] {
+		case "$ORIGIN":
+			if len(fields) != 2 {
+				return nil, fail("invalid $ORIGIN")
+			}
+			origin = qualify(fields[1], origin)
+			continue
+		case "$TTL":
+			n, err := strconv.ParseUint(fieldAt(fields, 1), 10, 32)
+			if err != nil || len(fields) != 2 {
+				return nil, fail("invalid $TTL")
+			}
+			ttl = uint32(n)
+			continue
+		}
+
+		r := &record{Name: qualify(fields[0], origin), TTL: ttl}
+		rest := fields[1:]
+		if len(rest) > 0 {
+			if n, err := strconv.ParseUint(rest[0], 10, 32); err == nil {
+				r.TTL = uint32(n)
+				rest = rest[1:]
+			}
+		}
+		if len(rest) > 0 && strings.EqualFold(rest[0], "IN") {
+			rest = rest[1:]
+		}
+		if len(rest) < 2 {
+			return nil, fail("missing type or value")
+		}
+		r.Type = strings.ToUpper(rest[0])
+		for rrtype, name := range typeNames {
+			if name == r.Type {
+				r.rrtype = rrtype
+			}
+		}
+		switch r.rrtype {
+		case typeA, typeAAAA:
+			ip := net.ParseIP(rest[1])
+			if ip == nil || (r.rrtype == typeA) != (ip.To4() != nil) || len(rest) != 2 {
+				return nil, fail("invalid address %q for %s record", rest[1], r.Type)
+			}
+			r.Value = ip.String()
+			if r.rrtype == typeA {
+				r.data = ip.To4()
+			} else {
+				r.data = ip.To16()
+			}
+		case typeCNAME, typePTR:
+			if len(rest) != 2 {
+				return nil, fail("invalid %s record", r.Type)
+			}
+			r.Value = qualify(rest[1], origin)
+			if r.data, err = encodeName(r.Value); err != nil {
+				return nil, fail("%v", err)
+			}
+		case typeTXT:
+			// The value is the remainder of the line, optionally quoted.
+			value := strings.TrimSpace(line[fieldOffsets(line)[len(fields)-len(rest)+1]:])
+			if unquoted, err := strconv.Unquote(value); err == nil {
+				value = unquoted
+			}
+			r.Value = value
+			for len(value) > 0 {
+				chunk := value[:min(len(value), 255)]
+				r.data = append(append(r.data, byte(len(chunk))), chunk...)
+				value = value[len(chunk):]
+			}
+			if r.data == nil {
+				r.data = []byte{0}
+			}
+		default:
+			return nil, fail("unsupported type %s", rest[0])
+		}
+		key := strings.ToLower(r.Name)
+		z[key] = append(z[key], r)
+	}
+	return z, scanner.Err()
+}
+
+// stripComment removes a comment, which starts with a semicolon outside of
+// double quotes, from a line.
+func stripComment(line string) string {
+	quoted := false
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case '\\':
+			if quoted {
+				i++
+			}
+		case '"':
+			quoted = !quoted
+		case ';':
+			if !quoted {
+				return line[:i]
+			}
+		}
+	}
+	return line
+}
+
+// fieldOffsets returns the offsets of the fields of line, as split by
+// strings.Fields.
+func fieldOffsets(line string) []int {
+	var offsets []int
+	inField := false
+	for i, c := range line {
+		space := unicode.IsSpace(c)
+		if !space && !inField {
+			offsets = append(offsets, i)
+		}
+		inField = !space
+	}
+	return offsets
+}
+
+func fieldAt(fields []string, i int) string {
+	if i < len(fields) {
+		return fields[i]
+	}
+	return ""
+}
+
+// qualify returns the fully qualified form of a name relative to origin.
+func qualify(name, origin string) string {
+	switch {
+	case name == "@":
+		return origin
+	case strings.HasSuffix(name, "."):
+		return name
+	case origin == ".":
+		return name + "."
+	}
+	return name + "." + origin
+}
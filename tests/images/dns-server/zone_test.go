@@ -0,0 +1,122 @@
+// Copyright (c) Ansible Project
+// GNU General Public License v3.0+ (see COPYING or https://www.gnu.org/licenses/gpl-3.0.txt)
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeZone(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "zone")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestReadZone(t *testing.T) {
+	z, err := readZone(writeZone(t, `
+$ORIGIN example.com.
+$TTL 60
+@      IN A     10.1.0.1 ; the origin
+web    300 A    10.1.0.2
+www       CNAME web
+ext       CNAME example.org.
+v6        AAAA  fd00::1
+info      TXT   "served by dns-server; hello"
+2.0.1.10.in-addr.arpa. PTR web
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tc := range []struct {
+		name, rtype, value string
+		ttl                uint32
+		data               string
+	}{
+		{"example.com.", "A", "10.1.0.1", 60, "\x0a\x01\x00\x01"},
+		{"web.example.com.", "A", "10.1.0.2", 300, "\x0a\x01\x00\x02"},
+		{"www.example.com.", "CNAME", "web.example.com.", 60, "\x03web\x07example\x03com\x00"},
+		{"ext.example.com.", "CNAME", "example.org.", 60, "\x07example\x03org\x00"},
+		{"v6.example.com.", "AAAA", "fd00::1", 60, "\xfd" + strings.Repeat("\x00", 14) + "\x01"},
+		{"info.example.com.", "TXT", "served by dns-server; hello", 60, "\x1bserved by dns-server; hello"},
+		{"2.0.1.10.in-addr.arpa.", "PTR", "web.example.com.", 60, "\x03web\x07example\x03com\x00"},
+	} {
+		records := z[tc.name]
+		if len(records) != 1 {
+			t.Errorf("%s: got %d records, expected 1", tc.name, len(records))
+			continue
+		}
+		r := records[0]
+		if r.Type != tc.rtype || r.Value != tc.value || r.TTL != tc.ttl || string(r.data) != tc.data {
+			t.Errorf("%s: got %+v, expected %s %d %s", tc.name, r, tc.rtype, tc.ttl, tc.value)
+		}
+	}
+	if len(z) != 7 {
+		t.Errorf("got %d names, expected 7", len(z))
+	}
+}
+
+func TestReadZoneLongTXT(t *testing.T) {
+	value := strings.Repeat("x", 300)
+	z, err := readZone(writeZone(t, "txt.example. TXT "+value+"\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "\xff" + value[:255] + "\x2d" + value[255:]
+	if got := string(z["txt.example."][0].data); got != expected {
+		t.Errorf("got %q, expected %q", got, expected)
+	}
+}
+
+func TestReadZoneTXT(t *testing.T) {
+	z, err := readZone(writeZone(t, `
+TXTweb.example. TXT "x"
+comment.example. TXT "a; b" ; comment
+escaped.example. TXT "say \"hi\"; ok" ; comment
+unquoted.example.  300  IN  TXT  plain  text ; comment
+empty.example. TXT ""
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, expected := range map[string]string{
+		"txtweb.example.":   "x",
+		"comment.example.":  "a; b",
+		"escaped.example.":  `say "hi"; ok`,
+		"unquoted.example.": "plain  text",
+		"empty.example.":    "",
+	} {
+		if records := z[name]; len(records) != 1 || records[0].Value != expected {
+			t.Errorf("%s: got %+v, expected the value %q", name, records, expected)
+		}
+	}
+}
+
+func TestReadZoneErrors(t *testing.T) {
+	for _, tc := range []struct {
+		content string
+		err     string
+	}{
+		{"$ORIGIN\n", "invalid $ORIGIN"},
+		{"$TTL soon\n", "invalid $TTL"},
+		{"web.example. A\n", "missing type or value"},
+		{"web.example. A fd00::1\n", "invalid address"},
+		{"web.example. AAAA 10.1.0.1\n", "invalid address"},
+		{"web.example. MX 10 mail.example.\n", "unsupported type MX"},
+		{"www.example. CNAME a b\n", "invalid CNAME record"},
+		{"www.example. CNAME " + strings.Repeat("a", 64) + ".example.\n", `label "`},
+		{"www.example. CNAME " + strings.Repeat(strings.Repeat("a", 63)+".", 4) + "\n", "name \""},
+	} {
+		_, err := readZone(writeZone(t, "\n"+tc.content))
+		if err == nil {
+			t.Errorf("%q: expected an error", tc.content)
+		} else if !strings.Contains(err.Error(), ":2: "+tc.err) {
+			t.Errorf("%q: got error %q, expected %q", tc.content, err, tc.err)
+		}
+	}
+}
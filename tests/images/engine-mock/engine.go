@@ -0,0 +1,319 @@
+// Copyright (c) Ansible Project
+// GNU General Public License v3.0+ (see COPYING or https://www.gnu.org/licenses/gpl-3.0.txt)
+
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ansible-collections/community.docker/tests/images/internal/fixture"
+)
+
+const apiVersion = "1.41"
+
+var (
+	containerPathRegexp    = regexp.MustCompile(`^/containers/([^/]+)(/[a-z]+)?$`)
+	containerNameRegexp    = regexp.MustCompile(`^/?[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+	defaultVersionResponse = map[string]interface{}{
+		"Version":       "20.10.0",
+		"ApiVersion":    apiVersion,
+		"MinAPIVersion": "1.12",
+		"GitCommit":     "engine-mock",
+		"GoVersion":     "go1.22",
+		"Os":            "linux",
+		"Arch":          "amd64",
+		"KernelVersion": "5.10.0",
+	}
+	defaultInfoResponse = map[string]interface{}{
+		"ID":              "ENGINE:MOCK",
+		"Name":            "engine-mock",
+		"ServerVersion":   "20.10.0",
+		"OperatingSystem": "engine-mock",
+		"OSType":          "linux",
+		"Architecture":    "x86_64",
+		"Driver":          "overlay2",
+		"DefaultRuntime":  "runc",
+		"Runtimes":        map[string]interface{}{"runc": map[string]interface{}{"path": "runc"}},
+		"Swarm":           map[string]interface{}{"LocalNodeState": "inactive"},
+	}
+)
+
+type container struct {
+	ID         string
+	Name       string
+	Created    time.Time
+	Config     map[string]interface{}
+	HostConfig map[string]interface{}
+	Running    bool
+	StartedAt  time.Time
+	FinishedAt time.Time
+}
+
+// engine implements the built-in endpoints. Containers are only kept in
+// memory.
+type engine struct {
+	version map[string]interface{}
+	info    map[string]interface{}
+	images  []map[string]interface{}
+
+	mu         sync.Mutex
+	containers map[string]*container
+}
+
+func newEngine(sc scenario) *engine {
+	e := &engine{
+		version:    merge(defaultVersionResponse, sc.Version),
+		info:       merge(defaultInfoResponse, sc.Info),
+		images:     sc.Images,
+		containers: make(map[string]*container),
+	}
+	if e.images == nil {
+		e.images = []map[string]interface{}{}
+	}
+	return e
+}
+
+func merge(defaults, overrides map[string]interface{}) map[string]interface{} {
+	result := make(map[string]interface{})
+	for key, value := range defaults {
+		result[key] = value
+	}
+	for key, value := range overrides {
+		result[key] = value
+	}
+	return result
+}
+
+// writeError answers with an error in the format used by the daemon.
+func writeError(w http.ResponseWriter, status int, format string, args ...interface{}) {
+	fixture.ServeJSONStatus(w, status, map[string]string{"message": fmt.Sprintf(format, args...)})
+}
+
+func (e *engine) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Api-Version", apiVersion)
+	w.Header().Set("Server", "Docker/20.10.0 (linux)")
+
+	path := r.URL.Path
+	switch {
+	case path == "/_ping" && (r.Method == http.MethodGet || r.Method == http.MethodHead):
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
+		w.Write([]byte("OK"))
+	case path == "/version" && r.Method == http.MethodGet:
+		fixture.ServeJSON(w, e.version)
+	case path == "/info" && r.Method == http.MethodGet:
+		e.mu.Lock()
+		info := merge(e.info, map[string]interface{}{"Containers": len(e.containers), "Images": len(e.images)})
+		e.mu.Unlock()
+		fixture.ServeJSON(w, info)
+	case path == "/images/json" && r.Method == http.MethodGet:
+		fixture.ServeJSON(w, e.images)
+	case path == "/containers/json" && r.Method == http.MethodGet:
+		e.listContainers(w, r)
+	case path == "/containers/create" && r.Method == http.MethodPost:
+		e.createContainer(w, r)
+	case containerPathRegexp.MatchString(path):
+		match := containerPathRegexp.FindStringSubmatch(path)
+		e.serveContainer(w, r, match[1], match[2])
+	default:
+		writeError(w, http.StatusNotFound, "page not found")
+	}
+}
+
+// lookup finds a container by ID, name or unique ID prefix, in this order of
+// precedence. The caller must hold e.mu.
+func (e *engine) lookup(ref string) *container {
+	if c := e.containers[ref]; c != nil {
+		return c
+	}
+	name := strings.TrimPrefix(ref, "/")
+	for _, c := range e.containers {
+		if c.Name == name {
+			return c
+		}
+	}
+	var found *container
+	for _, c := range e.containers {
+		if strings.HasPrefix(c.ID, ref) {
+			if found != nil {
+				return nil
+			}
+			found = c
+		}
+	}
+	return found
+}
+
+func (e *engine) listContainers(w http.ResponseWriter, r *http.Request) {
+	all := r.URL.Query().Get("all")
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	list := []map[string]interface{}{}
+	for _, c := range e.containers {
+		if !c.Running && all != "1" && all != "true" {
+			continue
+		}
+		list = append(list, map[string]interface{}{
+			"Id":      c.ID,
+			"Names":   []string{"/" + c.Name},
+			"Image":   c.Config["Image"],
+			"Created": c.Created.Unix(),
+			"State":   c.status(),
+			"Labels":  c.Config["Labels"],
+		})
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i]["Id"].(string) < list[j]["Id"].(string)
+	})
+	fixture.ServeJSON(w, list)
+}
+
+func (e *engine) createContainer(w http.ResponseWriter, r *http.Request) {
+	var config map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&config); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid JSON: %v", err)
+		return
+	}
+	if image, _ := config["Image"].(string); image == "" {
+		writeError(w, http.StatusBadRequest, "config is invalid: no image specified")
+		return
+	}
+	hostConfig, _ := config["HostConfig"].(map[string]interface{})
+	delete(config, "HostConfig")
+	delete(config, "NetworkingConfig")
+
+	var b [32]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		writeError(w, http.StatusInternalServerError, "%v", err)
+		return
+	}
+	c := &container{
+		ID:         hex.EncodeToString(b[:]),
+		Created:    time.Now().UTC(),
+		Config:     config,
+		HostConfig: hostConfig,
+	}
+	c.Name = c.ID[:12]
+	if name := r.URL.Query().Get("name"); name != "" {
+		if !containerNameRegexp.MatchString(name) {
+			writeError(w, http.StatusBadRequest, "Invalid container name (%s)", name)
+			return
+		}
+		c.Name = strings.TrimPrefix(name, "/")
+	}
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if existing := e.lookup(c.Name); existing != nil && existing.Name == c.Name {
+		writeError(w, http.StatusConflict, `Conflict. The container name "/%s" is already in use by container "%s".`, c.Name, existing.ID)
+		return
+	}
+	e.containers[c.ID] = c
+	fixture.ServeJSONStatus(w, http.StatusCreated, map[string]interface{}{"Id": c.ID, "Warnings": []string{}})
+}
+
+func (e *engine) serveContainer(w http.ResponseWriter, r *http.Request, ref, action string) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	c := e.lookup(ref)
+	if c == nil {
+		writeError(w, http.StatusNotFound, "No such container: %s", ref)
+		return
+	}
+
+	switch {
+	case action == "/json" && r.Method == http.MethodGet:
+		fixture.ServeJSON(w, c.inspect())
+	case action == "/start" && r.Method == http.MethodPost:
+		if c.Running {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		c.Running = true
+		c.StartedAt = time.Now().UTC()
+		w.WriteHeader(http.StatusNoContent)
+	case action == "/stop" && r.Method == http.MethodPost:
+		if !c.Running {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		c.Running = false
+		c.FinishedAt = time.Now().UTC()
+		w.WriteHeader(http.StatusNoContent)
+	case action == "" && r.Method == http.MethodDelete:
+		force := r.URL.Query().Get("force")
+		if c.Running && force != "1" && force != "true" {
+			writeError(w, http.StatusConflict, "You cannot remove a running container %s. Stop the container before attempting removal or force remove", c.ID)
+			return
+		}
+		delete(e.containers, c.ID)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, http.StatusNotFound, "page not found")
+	}
+}
+
+func (c *container) status() string {
+	switch {
+	case c.Running:
+		return "running"
+	case c.StartedAt.IsZero():
+		return "created"
+	}
+	return "exited"
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "0001-01-01T00:00:00Z"
+	}
+	return t.Format(time.RFC3339Nano)
+}
+
+// inspect returns the container in the format of GET /containers/{id}/json.
+func (c *container) inspect() map[string]interface{} {
+	pid := 0
+	if c.Running {
+		pid = 4242
+	}
+	hostConfig := c.HostConfig
+	if hostConfig == nil {
+		hostConfig = map[string]interface{}{}
+	}
+	return map[string]interface{}{
+		"Id":      c.ID,
+		"Name":    "/" + c.Name,
+		"Created": formatTime(c.Created),
+		"Image":   c.Config["Image"],
+		"State": map[string]interface{}{
+			"Status":     c.status(),
+			"Running":    c.Running,
+			"Paused":     false,
+			"Restarting": false,
+			"OOMKilled":  false,
+			"Dead":       false,
+			"Pid":        pid,
+			"ExitCode":   0,
+			"Error":      "",
+			"StartedAt":  formatTime(c.StartedAt),
+			"FinishedAt": formatTime(c.FinishedAt),
+		},
+		"Config":     c.Config,
+		"HostConfig": hostConfig,
+		"Mounts":     []interface{}{},
+		"NetworkSettings": map[string]interface{}{
+			"Networks": map[string]interface{}{},
+			"Ports":    map[string]interface{}{},
+		},
+		"RestartCount": 0,
+	}
+}
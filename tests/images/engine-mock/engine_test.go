@@ -0,0 +1,45 @@
+// Copyright (c) Ansible Project
+// GNU General Public License v3.0+ (see COPYING or https://www.gnu.org/licenses/gpl-3.0.txt)
+
+package main
+
+import "testing"
+
+func TestLookup(t *testing.T) {
+	e := newEngine(scenario{})
+	for _, c := range []*container{
+		{ID: "abc111", Name: "web"},
+		{ID: "abc222", Name: "abc"},
+		{ID: "def333", Name: "db"},
+	} {
+		e.containers[c.ID] = c
+	}
+
+	for _, tc := range []struct {
+		ref      string
+		expected string
+	}{
+		{"abc111", "abc111"},
+		{"web", "abc111"},
+		{"/web", "abc111"},
+		// The name wins over the ambiguous ID prefix, regardless of the
+		// iteration order of the map.
+		{"abc", "abc222"},
+		{"def", "def333"},
+		{"abc1", "abc111"},
+		{"a", ""},
+		{"xyz", ""},
+	} {
+		// Repeat to cover different map iteration orders.
+		for i := 0; i < 20; i++ {
+			got := ""
+			if c := e.lookup(tc.ref); c != nil {
+				got = c.ID
+			}
+			if got != tc.expected {
+				t.Errorf("lookup(%q) = %q, expected %q", tc.ref, got, tc.expected)
+				break
+			}
+		}
+	}
+}
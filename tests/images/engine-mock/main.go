@@ -0,0 +1,121 @@
+// Copyright (c) Ansible Project
+// GNU General Public License v3.0+ (see COPYING or https://www.gnu.org/licenses/gpl-3.0.txt)
+
+// Command engine-mock speaks a subset of the Docker Engine API, so that
+// modules and plugins can be tested against daemon behavior which is hard to
+// provoke with a real daemon.
+//
+// The following endpoints are implemented, with and without API version
+// prefix (like /v1.41): GET and HEAD /_ping, GET /version, GET /info,
+// GET /images/json, GET /containers/json, POST /containers/create,
+// GET /containers/{id}/json, POST /containers/{id}/start,
+// POST /containers/{id}/stop, and DELETE /containers/{id}. Containers only
+// exist in memory; starting them does not run anything.
+//
+// A scenario file given with -scenario can override the version and info
+// responses, provide the image list, and script responses:
+//
+//	{
+//	  "version": {"ApiVersion": "1.25"},
+//	  "info": {"Runtimes": {"runc": {"path": "runc"}}},
+//	  "images": [{"Id": "sha256:...", "RepoTags": ["hello:latest"]}],
+//	  "responses": [
+//	    {"method": "GET", "path": "^/containers/[^/]+/json$", "status": 500, "body": {"message": "boom"}, "count": 1},
+//	    {"path": "^/version$", "fault": "truncate"}
+//	  ]
+//	}
+//
+// A scripted response matches requests by method and by a regular expression
+// for the path without API version prefix. The first matching response is
+// used; with count, it only applies to the next count matching requests. The
+// response is sent after an optional delay, with the given status (200 by
+// default), headers and JSON body. Instead, a fault can be injected:
+// "timeout" never answers, "close" closes the connection without answering,
+// and "truncate" sends the start of the body with chunked transfer encoding
+// and closes the connection before the body is complete.
+//
+// Scripted responses can be listed, replaced and removed at runtime with GET,
+// PUT and DELETE requests to /_mock/responses. GET /_mock/requests returns
+// all requests received so far (method, path, query and body), and DELETE
+// /_mock/requests clears that list.
+//
+// Usage:
+//
+//	engine-mock [-listen ADDRESS] [-socket PATH] [-scenario FILE]
+//
+// At least one of -listen (for example -listen :2375) and -socket must be
+// given. The program runs until it receives SIGTERM or SIGINT.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"net"
+	"net/http"
+	"os"
+
+	"github.com/ansible-collections/community.docker/tests/images/internal/fixture"
+)
+
+type scenario struct {
+	Version   map[string]interface{}   `json:"version"`
+	Info      map[string]interface{}   `json:"info"`
+	Images    []map[string]interface{} `json:"images"`
+	Responses json.RawMessage          `json:"responses"`
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("engine-mock: ")
+
+	listen := flag.String("listen", "", "TCP address to listen on")
+	socket := flag.String("socket", "", "path of a unix socket to listen on")
+	scenarioFile := flag.String("scenario", "", "JSON file describing the scenario")
+	flag.Parse()
+
+	if *listen == "" && *socket == "" {
+		log.Fatal("at least one of -listen and -socket must be given")
+	}
+
+	var sc scenario
+	if *scenarioFile != "" {
+		content, err := os.ReadFile(*scenarioFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if err := json.Unmarshal(content, &sc); err != nil {
+			log.Fatalf("cannot parse %s: %v", *scenarioFile, err)
+		}
+	}
+
+	script := &script{}
+	if sc.Responses != nil {
+		if err := script.set(sc.Responses); err != nil {
+			log.Fatal(err)
+		}
+	}
+	eng := newEngine(sc)
+
+	mux := http.NewServeMux()
+	mux.Handle("/_mock/responses", fixture.Control{Get: script.list, Set: script.set, Clear: script.clear})
+	mux.Handle("/_mock/requests", fixture.Control{Get: script.listRequests, Clear: script.clearRequests})
+	mux.Handle("/", script.wrap(eng))
+	handler := fixture.LogRequests(mux)
+
+	if *listen != "" {
+		fixture.Serve(*listen, handler)
+	}
+	if *socket != "" {
+		os.Remove(*socket)
+		listener, err := net.Listen("unix", *socket)
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer os.Remove(*socket)
+		go func() {
+			log.Fatal(http.Serve(listener, handler))
+		}()
+	}
+	fixture.WaitForSignal()
+}
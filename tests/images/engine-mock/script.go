@@ -0,0 +1,185 @@
+// Copyright (c) Ansible Project
+// GNU General Public License v3.0+ (see COPYING or https://www.gnu.org/licenses/gpl-3.0.txt)
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ansible-collections/community.docker/tests/images/internal/fixture"
+)
+
+type scriptedResponse struct {
+	fixture.RequestRule
+	Status  int               `json:"status,omitempty"`
+	Headers map[string]string `json:"headers,omitempty"`
+	Body    json.RawMessage   `json:"body,omitempty"`
+	Delay   string            `json:"delay,omitempty"`
+	Fault   string            `json:"fault,omitempty"`
+
+	delay time.Duration
+}
+
+type recordedRequest struct {
+	Method string `json:"method"`
+	Path   string `json:"path"`
+	Query  string `json:"query"`
+	Body   string `json:"body"`
+}
+
+// script holds the scripted responses and records all requests.
+type script struct {
+	mu        sync.Mutex
+	responses []*scriptedResponse
+	requests  []recordedRequest
+}
+
+func (resp *scriptedResponse) compile() error {
+	if err := resp.CompilePath(); err != nil {
+		return err
+	}
+	if resp.Delay != "" {
+		var err error
+		if resp.delay, err = time.ParseDuration(resp.Delay); err != nil {
+			return fmt.Errorf("invalid delay %q: %v", resp.Delay, err)
+		}
+	}
+	switch resp.Fault {
+	case "", "timeout", "close", "truncate":
+	default:
+		return fmt.Errorf("invalid fault %q", resp.Fault)
+	}
+	if resp.Status == 0 {
+		resp.Status = http.StatusOK
+	}
+	return nil
+}
+
+func (s *script) set(content []byte) error {
+	var responses []*scriptedResponse
+	if err := json.Unmarshal(content, &responses); err != nil {
+		return fmt.Errorf("cannot parse responses: %v", err)
+	}
+	for _, resp := range responses {
+		if err := resp.compile(); err != nil {
+			return err
+		}
+	}
+	s.mu.Lock()
+	s.responses = responses
+	s.mu.Unlock()
+	return nil
+}
+
+// match returns the first scripted response matching the request, and counts
+// it as used.
+func (s *script) match(method, path string) *scriptedResponse {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return fixture.Match(&s.responses, method, path, nil)
+}
+
+// wrap records every request, strips the API version prefix from the path,
+// and answers with a scripted response if one matches. Otherwise the request
+// is passed on to handler.
+func (s *script) wrap(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		path := fixture.StripAPIVersion(r.URL.Path)
+		r.URL.Path = path
+
+		s.mu.Lock()
+		s.requests = append(s.requests, recordedRequest{
+			Method: r.Method,
+			Path:   path,
+			Query:  r.URL.RawQuery,
+			Body:   string(body),
+		})
+		s.mu.Unlock()
+
+		resp := s.match(r.Method, path)
+		if resp == nil {
+			handler.ServeHTTP(w, r)
+			return
+		}
+		time.Sleep(resp.delay)
+		switch resp.Fault {
+		case "timeout":
+			<-r.Context().Done()
+		case "close", "truncate":
+			conn, buf, err := http.NewResponseController(w).Hijack()
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			defer conn.Close()
+			if resp.Fault == "truncate" {
+				// Announce a chunk larger than the whole body, send only the
+				// first half of the body, and close the connection. This
+				// is incomplete even for empty bodies, for which a chunk
+				// of size 0 would end the response.
+				partial := resp.Body[:len(resp.Body)/2]
+				fmt.Fprintf(buf, "HTTP/1.1 %d %s\r\n", resp.Status, http.StatusText(resp.Status))
+				fmt.Fprint(buf, "Content-Type: application/json\r\nTransfer-Encoding: chunked\r\n")
+				for key, value := range resp.Headers {
+					fmt.Fprintf(buf, "%s: %s\r\n", key, value)
+				}
+				fmt.Fprintf(buf, "\r\n%x\r\n", len(resp.Body)+1)
+				buf.Write(partial)
+				buf.Flush()
+			}
+		default:
+			w.Header().Set("Content-Type", "application/json")
+			for key, value := range resp.Headers {
+				w.Header().Set(key, value)
+			}
+			w.Header().Set("Content-Length", strconv.Itoa(len(resp.Body)))
+			w.WriteHeader(resp.Status)
+			w.Write(resp.Body)
+		}
+	})
+}
+
+// list returns a snapshot of the scripted responses for the /_mock/responses
+// endpoint.
+func (s *script) list(*http.Request) interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	responses := make([]scriptedResponse, len(s.responses))
+	for i, resp := range s.responses {
+		responses[i] = *resp
+	}
+	return responses
+}
+
+func (s *script) clear() {
+	s.mu.Lock()
+	s.responses = nil
+	s.mu.Unlock()
+}
+
+// listRequests returns a snapshot of the recorded requests for the
+// /_mock/requests endpoint.
+func (s *script) listRequests(*http.Request) interface{} {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]recordedRequest{}, s.requests...)
+}
+
+func (s *script) clearRequests() {
+	s.mu.Lock()
+	s.requests = nil
+	s.mu.Unlock()
+}
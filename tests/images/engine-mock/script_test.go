@@ -0,0 +1,41 @@
+// Copyright (c) Ansible Project
+// GNU General Public License v3.0+ (see COPYING or https://www.gnu.org/licenses/gpl-3.0.txt)
+
+package main
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTruncateFault(t *testing.T) {
+	for _, body := range []string{``, `1`, `{"Version":"25.0.0"}`} {
+		s := &script{}
+		responses := `[{"path":"^/version$","fault":"truncate"}]`
+		if body != "" {
+			responses = `[{"path":"^/version$","fault":"truncate","body":` + body + `}]`
+		}
+		if err := s.set([]byte(responses)); err != nil {
+			t.Fatal(err)
+		}
+		server := httptest.NewServer(s.wrap(http.NotFoundHandler()))
+
+		resp, err := http.Get(server.URL + "/v1.43/version")
+		if err != nil {
+			server.Close()
+			t.Fatalf("body %q: %v", body, err)
+		}
+		content, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		server.Close()
+		if !errors.Is(err, io.ErrUnexpectedEOF) {
+			t.Errorf("body %q: got %q and error %v, expected an unexpected EOF", body, content, err)
+		}
+		if len(content) != len(body)/2 {
+			t.Errorf("body %q: got %q, expected the first half", body, content)
+		}
+	}
+}
@@ -0,0 +1,125 @@
+// Copyright (c) Ansible Project
+// GNU General Public License v3.0+ (see COPYING or https://www.gnu.org/licenses/gpl-3.0.txt)
+
+// Command event-driver makes its container go through a fixed schedule of
+// state changes, so that the daemon emits a predictable sequence of events.
+// The schedule is a comma-separated list of steps:
+//
+//	healthy:DURATION    report healthy for DURATION
+//	unhealthy:DURATION  report unhealthy for DURATION
+//	exit:CODE           exit with status CODE
+//
+// Every step is reported as a JSON line on stdout when it starts:
+//
+//	{"time":"2021-05-01T12:00:00.123Z","step":2,"action":"unhealthy","argument":"5s"}
+//
+// The health status is kept in the state file, which the image's health
+// check reads by running the program with -check. Every health check is an
+// exec, so the container also produces exec_create, exec_start and
+// exec_die events at the health check interval. With an exit step and a
+// restart policy, the container restarts and runs the schedule again. If the
+// schedule does not end with an exit step, the program keeps the last health
+// status until it receives SIGTERM or SIGINT.
+//
+// Usage:
+//
+//	event-driver [-schedule SCHEDULE] [-state FILE]
+//	event-driver -check [-state FILE]
+//
+// The default schedule is healthy:10s,unhealthy:10s,exit:0, and the default
+// state file is /event-driver.state. With -check, the program exits with
+// status 0 if the state file says healthy, and 1 otherwise.
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ansible-collections/community.docker/tests/images/internal/fixture"
+)
+
+type step struct {
+	Time     time.Time `json:"time"`
+	Step     int       `json:"step"`
+	Action   string    `json:"action"`
+	Argument string    `json:"argument"`
+
+	duration time.Duration
+	exitCode int
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("event-driver: ")
+
+	schedule := flag.String("schedule", "healthy:10s,unhealthy:10s,exit:0", "steps to go through")
+	state := flag.String("state", "/event-driver.state", "file containing the health status")
+	check := flag.Bool("check", false, "exit with the health status from the state file")
+	flag.Parse()
+
+	if *check {
+		content, err := os.ReadFile(*state)
+		if err != nil || string(content) != "healthy" {
+			os.Exit(1)
+		}
+		return
+	}
+
+	steps, err := parseSchedule(*schedule)
+	if err != nil {
+		fmt.Fprintf(flag.CommandLine.Output(), "invalid -schedule: %v\n", err)
+		os.Exit(2)
+	}
+	if err := os.Remove(*state); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		log.Fatal(err)
+	}
+
+	go run(steps, *state)
+	fixture.WaitForSignal()
+}
+
+func parseSchedule(schedule string) ([]step, error) {
+	var steps []step
+	for i, item := range strings.Split(schedule, ",") {
+		action, argument, _ := strings.Cut(strings.TrimSpace(item), ":")
+		s := step{Step: i + 1, Action: action, Argument: argument}
+		var err error
+		switch action {
+		case "healthy", "unhealthy":
+			if s.duration, err = time.ParseDuration(argument); err != nil || s.duration < 0 {
+				return nil, fmt.Errorf("invalid duration in %q", item)
+			}
+		case "exit":
+			if s.exitCode, err = strconv.Atoi(argument); err != nil || s.exitCode < 0 || s.exitCode > 255 {
+				return nil, fmt.Errorf("invalid exit code in %q", item)
+			}
+		default:
+			return nil, fmt.Errorf("unknown action in %q", item)
+		}
+		steps = append(steps, s)
+	}
+	return steps, nil
+}
+
+// run goes through the steps, and returns after the last one unless that
+// one exits the program.
+func run(steps []step, state string) {
+	for _, s := range steps {
+		s.Time = time.Now().UTC()
+		fixture.WriteJSON(s)
+		if s.Action == "exit" {
+			os.Exit(s.exitCode)
+		}
+		if err := os.WriteFile(state, []byte(s.Action), 0o644); err != nil {
+			log.Fatal(err)
+		}
+		time.Sleep(s.duration)
+	}
+}
@@ -0,0 +1,218 @@
+// Copyright (c) Ansible Project
+// GNU General Public License v3.0+ (see COPYING or https://www.gnu.org/licenses/gpl-3.0.txt)
+
+// Command exec-recorder records how its companion program record-exec is
+// executed, for example by docker exec. The image contains the same binary
+// as both /exec-recorder and /record-exec; the program chooses its role by
+// the name it is called with.
+//
+// Called as record-exec, the program appends one line of JSON describing the
+// invocation to the log file:
+//
+//	{"argv":["/record-exec","a"],"env":["HOME=/","PATH=..."],"cwd":"/","uid":0,"gid":0,"pid":12,"tty":false,"stdin":"hello\n","stdin_size":6,"started":"2024-01-01T12:00:00.000000001Z","finished":"2024-01-01T12:00:00.000200001Z","duration_seconds":0.0002}
+//
+// Unless stdin is a terminal, record-exec reads stdin until EOF and copies it
+// to stdout. A terminal is not read, since without an attached stream it
+// would never signal EOF. The log file is RECORD_EXEC_LOG, by default
+// /exec-recorder.jsonl. record-exec exits with RECORD_EXEC_EXIT_CODE, by
+// default 0.
+//
+// Called as exec-recorder, the program creates an empty log file and runs
+// until it receives SIGTERM or SIGINT, so that the container stays up for
+// docker exec. With -listen, the recorded invocations are served as a JSON
+// array on GET requests, and DELETE requests empty the log. With -print, the
+// recorded invocations are printed as a JSON array instead, and the program
+// exits; this is meant to be run with docker exec.
+//
+// Usage:
+//
+//	exec-recorder [-log FILE] [-listen ADDRESS]
+//	exec-recorder -print [-log FILE]
+//	record-exec [ARG...]
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"io"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"github.com/ansible-collections/community.docker/tests/images/internal/fixture"
+)
+
+const defaultLog = "/exec-recorder.jsonl"
+
+type invocation struct {
+	Argv            []string  `json:"argv"`
+	Env             []string  `json:"env"`
+	Cwd             string    `json:"cwd"`
+	UID             int       `json:"uid"`
+	GID             int       `json:"gid"`
+	PID             int       `json:"pid"`
+	TTY             bool      `json:"tty"`
+	Stdin           *string   `json:"stdin"`
+	StdinSize       int       `json:"stdin_size"`
+	Started         time.Time `json:"started"`
+	Finished        time.Time `json:"finished"`
+	DurationSeconds float64   `json:"duration_seconds"`
+}
+
+func main() {
+	log.SetFlags(0)
+	if filepath.Base(os.Args[0]) == "record-exec" {
+		log.SetPrefix("record-exec: ")
+		recordExec()
+		return
+	}
+	log.SetPrefix("exec-recorder: ")
+
+	logFile := flag.String("log", defaultLog, "file to record invocations in")
+	listen := flag.String("listen", "", "serve the recorded invocations on this address")
+	printRecords := flag.Bool("print", false, "print the recorded invocations and exit")
+	flag.Parse()
+
+	if *printRecords {
+		records, err := readRecords(*logFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fixture.WriteJSON(records)
+		return
+	}
+
+	if err := os.WriteFile(*logFile, nil, 0o666); err != nil {
+		log.Fatal(err)
+	}
+	// Every user has to be able to record invocations, regardless of the
+	// umask.
+	if err := os.Chmod(*logFile, 0o666); err != nil {
+		log.Fatal(err)
+	}
+	if *listen != "" {
+		http.Handle("/", &server{path: *logFile})
+		fixture.Serve(*listen, nil)
+	}
+	fixture.WaitForSignal()
+}
+
+func recordExec() {
+	started := time.Now()
+	exitCode := 0
+	if value := os.Getenv("RECORD_EXEC_EXIT_CODE"); value != "" {
+		var err error
+		if exitCode, err = strconv.Atoi(value); err != nil {
+			log.Fatalf("invalid RECORD_EXEC_EXIT_CODE: %v", err)
+		}
+	}
+	logFile := os.Getenv("RECORD_EXEC_LOG")
+	if logFile == "" {
+		logFile = defaultLog
+	}
+
+	env := os.Environ()
+	sort.Strings(env)
+	cwd, _ := os.Getwd()
+	record := invocation{
+		Argv: os.Args,
+		Env:  env,
+		Cwd:  cwd,
+		UID:  os.Getuid(),
+		GID:  os.Getgid(),
+		PID:  os.Getpid(),
+		TTY:  isTerminal(os.Stdin),
+	}
+	if !record.TTY {
+		var stdin bytes.Buffer
+		if _, err := io.Copy(io.MultiWriter(&stdin, os.Stdout), os.Stdin); err != nil {
+			log.Fatal(err)
+		}
+		content := stdin.String()
+		record.Stdin = &content
+		record.StdinSize = stdin.Len()
+	}
+	record.Started = started
+	record.Finished = time.Now()
+	record.DurationSeconds = record.Finished.Sub(started).Seconds()
+
+	content, err := json.Marshal(record)
+	if err != nil {
+		log.Fatal(err)
+	}
+	f, err := os.OpenFile(logFile, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o666)
+	if err != nil {
+		log.Fatal(err)
+	}
+	// A single write keeps lines of concurrent invocations apart
+	if _, err := f.Write(append(content, '\n')); err != nil {
+		log.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		log.Fatal(err)
+	}
+	os.Exit(exitCode)
+}
+
+// isTerminal reports whether f is a terminal.
+func isTerminal(f *os.File) bool {
+	var termios syscall.Termios
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, f.Fd(), syscall.TCGETS, uintptr(unsafe.Pointer(&termios)))
+	return errno == 0
+}
+
+// readRecords returns the invocations recorded in the log file.
+func readRecords(path string) ([]json.RawMessage, error) {
+	records := []json.RawMessage{}
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return records, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 16<<20)
+	for scanner.Scan() {
+		records = append(records, json.RawMessage(bytes.Clone(scanner.Bytes())))
+	}
+	return records, scanner.Err()
+}
+
+type server struct {
+	path string
+	mu   sync.Mutex
+}
+
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch r.Method {
+	case http.MethodGet:
+		records, err := readRecords(s.path)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		fixture.ServeJSON(w, records)
+	case http.MethodDelete:
+		if err := os.Truncate(s.path, 0); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,168 @@
+// Copyright (c) Ansible Project
+// GNU General Public License v3.0+ (see COPYING or https://www.gnu.org/licenses/gpl-3.0.txt)
+
+// Command file-server serves the content of a directory over HTTP.
+//
+// GET requests for files return the file's content. GET requests for
+// directories return a JSON listing of the directory:
+//
+//	{"path":"/sub","entries":[{"name":"file.txt","type":"file","size":12,"mode":"0644"},{"name":"dir","type":"directory","size":4096,"mode":"0755"}]}
+//
+// GET requests with the query parameter stat return information about the
+// entry itself instead, including the SHA-256 of regular files and the target
+// of symbolic links:
+//
+//	{"path":"/sub/file.txt","type":"file","size":12,"mode":"0644","uid":1000,"gid":1000,"sha256":"a948904f..."}
+//
+// Symbolic links are not followed unless the query parameter follow is given
+// as well, as in /sub/link?stat&follow.
+//
+// Every request is logged to stderr.
+//
+// Usage:
+//
+//	file-server [-listen ADDRESS] [-root DIRECTORY]
+//
+// The defaults are -listen :8080 and -root /srv. The program runs until it
+// receives SIGTERM or SIGINT.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+
+	"github.com/ansible-collections/community.docker/tests/images/internal/fixture"
+)
+
+type listingEntry struct {
+	Name string `json:"name"`
+	Type string `json:"type"`
+	Size int64  `json:"size"`
+	Mode string `json:"mode"`
+}
+
+type listing struct {
+	Path    string         `json:"path"`
+	Entries []listingEntry `json:"entries"`
+}
+
+type server struct {
+	root string
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("file-server: ")
+
+	listen := flag.String("listen", ":8080", "address to listen on")
+	root := flag.String("root", "/srv", "directory to serve")
+	flag.Parse()
+
+	if info, err := os.Stat(*root); err != nil {
+		log.Fatal(err)
+	} else if !info.IsDir() {
+		log.Fatalf("%s is not a directory", *root)
+	}
+
+	fixture.Serve(*listen, fixture.LogRequests(&server{root: *root}))
+	fixture.WaitForSignal()
+}
+
+func (s *server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	urlPath := path.Clean("/" + r.URL.Path)
+	name := filepath.Join(s.root, filepath.FromSlash(urlPath))
+	if query := r.URL.Query(); query.Has("stat") {
+		serveStat(w, urlPath, name, query.Has("follow"))
+		return
+	}
+	f, err := os.Open(name)
+	if err != nil {
+		fail(w, err)
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		fail(w, err)
+		return
+	}
+	if !info.IsDir() {
+		http.ServeContent(w, r, info.Name(), info.ModTime(), f)
+		return
+	}
+
+	dirEntries, err := f.ReadDir(-1)
+	if err != nil {
+		fail(w, err)
+		return
+	}
+	sort.Slice(dirEntries, func(i, j int) bool {
+		return dirEntries[i].Name() < dirEntries[j].Name()
+	})
+	result := listing{Path: urlPath, Entries: []listingEntry{}}
+	for _, dirEntry := range dirEntries {
+		entryInfo, err := dirEntry.Info()
+		if err != nil {
+			// The entry was removed in the meantime
+			continue
+		}
+		result.Entries = append(result.Entries, listingEntry{
+			Name: dirEntry.Name(),
+			Type: typeName(entryInfo.Mode()),
+			Size: entryInfo.Size(),
+			Mode: formatMode(entryInfo.Mode()),
+		})
+	}
+	fixture.ServeJSON(w, result)
+}
+
+// fail responds with the status matching err.
+func fail(w http.ResponseWriter, err error) {
+	status := http.StatusInternalServerError
+	switch {
+	case os.IsNotExist(err):
+		status = http.StatusNotFound
+	case os.IsPermission(err):
+		status = http.StatusForbidden
+	}
+	http.Error(w, err.Error(), status)
+}
+
+func typeName(mode fs.FileMode) string {
+	switch {
+	case mode.IsRegular():
+		return "file"
+	case mode.IsDir():
+		return "directory"
+	case mode&fs.ModeSymlink != 0:
+		return "symlink"
+	}
+	return "other"
+}
+
+// formatMode returns the permission bits of mode in octal, including the
+// setuid, setgid and sticky bits, which fs.FileMode keeps elsewhere.
+func formatMode(mode fs.FileMode) string {
+	bits := uint32(mode.Perm())
+	if mode&fs.ModeSetuid != 0 {
+		bits |= 0o4000
+	}
+	if mode&fs.ModeSetgid != 0 {
+		bits |= 0o2000
+	}
+	if mode&fs.ModeSticky != 0 {
+		bits |= 0o1000
+	}
+	return fmt.Sprintf("%04o", bits)
+}
@@ -0,0 +1,29 @@
+// Copyright (c) Ansible Project
+// GNU General Public License v3.0+ (see COPYING or https://www.gnu.org/licenses/gpl-3.0.txt)
+
+package main
+
+import (
+	"io/fs"
+	"testing"
+)
+
+func TestFormatMode(t *testing.T) {
+	for _, tc := range []struct {
+		mode     fs.FileMode
+		expected string
+	}{
+		{0, "0000"},
+		{0o644, "0644"},
+		{fs.ModeDir | 0o755, "0755"},
+		{fs.ModeSymlink | 0o777, "0777"},
+		{fs.ModeSetuid | 0o755, "4755"},
+		{fs.ModeSetgid | 0o750, "2750"},
+		{fs.ModeDir | fs.ModeSticky | 0o777, "1777"},
+		{fs.ModeSetuid | fs.ModeSetgid | fs.ModeSticky | 0o7, "7007"},
+	} {
+		if got := formatMode(tc.mode); got != tc.expected {
+			t.Errorf("formatMode(%v) = %q, expected %q", tc.mode, got, tc.expected)
+		}
+	}
+}
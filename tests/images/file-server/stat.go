@@ -0,0 +1,76 @@
+// Copyright (c) Ansible Project
+// GNU General Public License v3.0+ (see COPYING or https://www.gnu.org/licenses/gpl-3.0.txt)
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"os"
+	"syscall"
+
+	"github.com/ansible-collections/community.docker/tests/images/internal/fixture"
+)
+
+type statResult struct {
+	Path       string `json:"path"`
+	Type       string `json:"type"`
+	Size       int64  `json:"size"`
+	Mode       string `json:"mode"`
+	UID        uint32 `json:"uid"`
+	GID        uint32 `json:"gid"`
+	SHA256     string `json:"sha256,omitempty"`
+	LinkTarget string `json:"link_target,omitempty"`
+}
+
+// serveStat reports information about name, which urlPath refers to.
+// Symbolic links are followed if follow is true.
+func serveStat(w http.ResponseWriter, urlPath, name string, follow bool) {
+	stat := os.Lstat
+	if follow {
+		stat = os.Stat
+	}
+	info, err := stat(name)
+	if err != nil {
+		fail(w, err)
+		return
+	}
+	result := statResult{
+		Path: urlPath,
+		Type: typeName(info.Mode()),
+		Size: info.Size(),
+		Mode: formatMode(info.Mode()),
+	}
+	if sys, ok := info.Sys().(*syscall.Stat_t); ok {
+		result.UID = sys.Uid
+		result.GID = sys.Gid
+	}
+	switch {
+	case info.Mode().IsRegular():
+		if result.SHA256, err = hashFile(name); err != nil {
+			fail(w, err)
+			return
+		}
+	case info.Mode()&os.ModeSymlink != 0:
+		if result.LinkTarget, err = os.Readlink(name); err != nil {
+			fail(w, err)
+			return
+		}
+	}
+	fixture.ServeJSON(w, result)
+}
+
+func hashFile(name string) (string, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
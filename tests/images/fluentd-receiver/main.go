@@ -0,0 +1,268 @@
+// Copyright (c) Ansible Project
+// GNU General Public License v3.0+ (see COPYING or https://www.gnu.org/licenses/gpl-3.0.txt)
+
+// Command fluentd-receiver receives events over TCP with the fluentd forward
+// protocol, as sent by the Docker fluentd log driver, and serves them as JSON
+// list on GET /events:
+//
+//	[{"received":"2021-05-01T12:00:00.123Z","mode":"message","tag":"docker.my-container","time":"2021-05-01T12:00:00.120Z","record":{"container_id":"8c3e...","container_name":"/my-container","log":"hello","source":"stdout"}}]
+//
+// All modes of the protocol are supported: message, forward, packed_forward
+// and compressed_packed_forward. Chunks are acknowledged if the sender
+// requests it.
+//
+// GET /events?tag=TAG returns only the events with that tag. DELETE /events
+// removes all events received so far.
+//
+// Usage:
+//
+//	fluentd-receiver [-forward ADDRESS] [-listen ADDRESS]
+//
+// The defaults are -forward :24224 and -listen :8080. The program runs until
+// it receives SIGTERM or SIGINT.
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ansible-collections/community.docker/tests/images/internal/fixture"
+)
+
+type event struct {
+	Received time.Time              `json:"received"`
+	Mode     string                 `json:"mode"`
+	Tag      string                 `json:"tag"`
+	Time     time.Time              `json:"time"`
+	Record   map[string]interface{} `json:"record"`
+}
+
+type store struct {
+	mu     sync.Mutex
+	events []event
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("fluentd-receiver: ")
+
+	forward := flag.String("forward", ":24224", "TCP address to receive events on")
+	listen := flag.String("listen", ":8080", "address to serve the received events on")
+	flag.Parse()
+
+	s := &store{}
+	listener, err := net.Listen("tcp", *forward)
+	if err != nil {
+		log.Fatal(err)
+	}
+	go s.accept(listener)
+
+	mux := http.NewServeMux()
+	mux.Handle("/events", fixture.Control{Get: s.list, Clear: s.clear})
+	fixture.Serve(*listen, mux)
+	fixture.WaitForSignal()
+}
+
+func (s *store) accept(listener net.Listener) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			log.Fatal(err)
+		}
+		go s.receive(conn)
+	}
+}
+
+// receive reads messages from a connection until it is closed.
+func (s *store) receive(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for {
+		v, err := decodeMsgpack(r)
+		if err != nil {
+			if !errors.Is(err, io.EOF) {
+				log.Printf("%s: %v", conn.RemoteAddr(), err)
+			}
+			return
+		}
+		events, option, err := parseMessage(v)
+		if err != nil {
+			log.Printf("%s: %v", conn.RemoteAddr(), err)
+			return
+		}
+		received := time.Now().UTC()
+		s.mu.Lock()
+		for _, e := range events {
+			e.Received = received
+			s.events = append(s.events, e)
+		}
+		s.mu.Unlock()
+		if chunk, ok := option["chunk"].(string); ok {
+			if _, err := conn.Write(encodeAck(chunk)); err != nil {
+				log.Printf("%s: %v", conn.RemoteAddr(), err)
+				return
+			}
+		}
+	}
+}
+
+// parseMessage returns the events of a message and its options.
+func parseMessage(v interface{}) ([]event, map[interface{}]interface{}, error) {
+	message, ok := v.([]interface{})
+	if !ok || len(message) < 2 {
+		return nil, nil, fmt.Errorf("invalid message %v", v)
+	}
+	tag, ok := message[0].(string)
+	if !ok {
+		return nil, nil, fmt.Errorf("invalid tag %v", message[0])
+	}
+	option := func(i int) map[interface{}]interface{} {
+		if len(message) > i {
+			if m, ok := message[i].(map[interface{}]interface{}); ok {
+				return m
+			}
+		}
+		return map[interface{}]interface{}{}
+	}
+
+	switch entries := message[1].(type) {
+	case []interface{}:
+		events := make([]event, 0, len(entries))
+		for _, entry := range entries {
+			e, err := parseEntry(tag, "forward", entry)
+			if err != nil {
+				return nil, nil, err
+			}
+			events = append(events, e)
+		}
+		return events, option(2), nil
+	case string:
+		opt := option(2)
+		mode := "packed_forward"
+		var stream io.Reader = bytes.NewReader([]byte(entries))
+		if compressed, _ := opt["compressed"].(string); compressed == "gzip" {
+			mode = "compressed_packed_forward"
+			zr, err := gzip.NewReader(stream)
+			if err != nil {
+				return nil, nil, err
+			}
+			stream = zr
+		}
+		r := bufio.NewReader(stream)
+		var events []event
+		for {
+			entry, err := decodeMsgpack(r)
+			if errors.Is(err, io.EOF) {
+				break
+			} else if err != nil {
+				return nil, nil, err
+			}
+			e, err := parseEntry(tag, mode, entry)
+			if err != nil {
+				return nil, nil, err
+			}
+			events = append(events, e)
+		}
+		return events, opt, nil
+	default:
+		if len(message) < 3 {
+			return nil, nil, fmt.Errorf("invalid message %v", v)
+		}
+		e, err := parseEntry(tag, "message", []interface{}{message[1], message[2]})
+		if err != nil {
+			return nil, nil, err
+		}
+		return []event{e}, option(3), nil
+	}
+}
+
+// parseEntry parses an entry consisting of time and record.
+func parseEntry(tag, mode string, v interface{}) (event, error) {
+	entry, ok := v.([]interface{})
+	if !ok || len(entry) != 2 {
+		return event{}, fmt.Errorf("invalid entry %v", v)
+	}
+	t, err := parseTime(entry[0])
+	if err != nil {
+		return event{}, err
+	}
+	record, ok := jsonValue(entry[1]).(map[string]interface{})
+	if !ok {
+		return event{}, fmt.Errorf("invalid record %v", entry[1])
+	}
+	return event{Mode: mode, Tag: tag, Time: t, Record: record}, nil
+}
+
+// parseTime parses an event time, given in seconds or as EventTime extension.
+func parseTime(v interface{}) (time.Time, error) {
+	switch t := v.(type) {
+	case int64:
+		return time.Unix(t, 0).UTC(), nil
+	case uint64:
+		return time.Unix(int64(t), 0).UTC(), nil
+	case float64:
+		sec, frac := math.Modf(t)
+		return time.Unix(int64(sec), int64(frac*1e9)).UTC(), nil
+	case extension:
+		if t.Type == 0 && len(t.Data) == 8 {
+			sec := binary.BigEndian.Uint32(t.Data[:4])
+			nsec := binary.BigEndian.Uint32(t.Data[4:])
+			return time.Unix(int64(sec), int64(nsec)).UTC(), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %v", v)
+}
+
+// jsonValue converts a decoded msgpack value into one which can be encoded as
+// JSON.
+func jsonValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for key, value := range v {
+			m[fmt.Sprint(key)] = jsonValue(value)
+		}
+		return m
+	case []interface{}:
+		for i, value := range v {
+			v[i] = jsonValue(value)
+		}
+		return v
+	case extension:
+		return map[string]interface{}{"type": v.Type, "data": v.Data}
+	}
+	return v
+}
+
+// list returns the received events for the /events endpoint, optionally
+// filtered by the tag query parameter.
+func (s *store) list(r *http.Request) interface{} {
+	tag, filter := r.URL.Query()["tag"]
+	events := []event{}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, e := range s.events {
+		if !filter || e.Tag == tag[0] {
+			events = append(events, e)
+		}
+	}
+	return events
+}
+
+func (s *store) clear() {
+	s.mu.Lock()
+	s.events = nil
+	s.mu.Unlock()
+}
@@ -0,0 +1,220 @@
+// Copyright (c) Ansible Project
+// GNU General Public License v3.0+ (see COPYING or https://www.gnu.org/licenses/gpl-3.0.txt)
+
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+)
+
+// extension is a msgpack extension value. The forward protocol uses type 0
+// for event times with nanosecond precision.
+type extension struct {
+	Type int8
+	Data []byte
+}
+
+const (
+	// maxLength limits the length of strings, arrays and maps, so that
+	// corrupt input cannot cause huge allocations.
+	maxLength = 16 * 1024 * 1024
+	// maxDepth limits the nesting of arrays and maps, so that corrupt input
+	// cannot exhaust the stack.
+	maxDepth = 64
+)
+
+// decodeMsgpack reads a single msgpack value. Strings and binary data are
+// returned as strings, integers as int64 or uint64, maps as
+// map[interface{}]interface{}, and arrays as []interface{}.
+func decodeMsgpack(r *bufio.Reader) (interface{}, error) {
+	return decodeValue(r, 0)
+}
+
+// decodeValue reads a msgpack value nested in depth arrays or maps.
+func decodeValue(r *bufio.Reader, depth int) (interface{}, error) {
+	b, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case b <= 0x7f:
+		return int64(b), nil
+	case b >= 0xe0:
+		return int64(int8(b)), nil
+	case b&0xf0 == 0x80:
+		return decodeMap(r, int(b&0x0f), depth)
+	case b&0xf0 == 0x90:
+		return decodeArray(r, int(b&0x0f), depth)
+	case b&0xe0 == 0xa0:
+		return decodeString(r, int(b&0x1f))
+	}
+	switch b {
+	case 0xc0:
+		return nil, nil
+	case 0xc2:
+		return false, nil
+	case 0xc3:
+		return true, nil
+	case 0xc4, 0xd9:
+		return decodeString(r, readLength(r, 1, &err))
+	case 0xc5, 0xda:
+		return decodeString(r, readLength(r, 2, &err))
+	case 0xc6, 0xdb:
+		return decodeString(r, readLength(r, 4, &err))
+	case 0xc7:
+		return decodeExtension(r, readLength(r, 1, &err))
+	case 0xc8:
+		return decodeExtension(r, readLength(r, 2, &err))
+	case 0xc9:
+		return decodeExtension(r, readLength(r, 4, &err))
+	case 0xca:
+		return float64(math.Float32frombits(uint32(readUint(r, 4, &err)))), err
+	case 0xcb:
+		return math.Float64frombits(readUint(r, 8, &err)), err
+	case 0xcc:
+		return readUint(r, 1, &err), err
+	case 0xcd:
+		return readUint(r, 2, &err), err
+	case 0xce:
+		return readUint(r, 4, &err), err
+	case 0xcf:
+		return readUint(r, 8, &err), err
+	case 0xd0:
+		return int64(int8(readUint(r, 1, &err))), err
+	case 0xd1:
+		return int64(int16(readUint(r, 2, &err))), err
+	case 0xd2:
+		return int64(int32(readUint(r, 4, &err))), err
+	case 0xd3:
+		return int64(readUint(r, 8, &err)), err
+	case 0xd4:
+		return decodeExtension(r, 1)
+	case 0xd5:
+		return decodeExtension(r, 2)
+	case 0xd6:
+		return decodeExtension(r, 4)
+	case 0xd7:
+		return decodeExtension(r, 8)
+	case 0xd8:
+		return decodeExtension(r, 16)
+	case 0xdc:
+		return decodeArray(r, readLength(r, 2, &err), depth)
+	case 0xdd:
+		return decodeArray(r, readLength(r, 4, &err), depth)
+	case 0xde:
+		return decodeMap(r, readLength(r, 2, &err), depth)
+	case 0xdf:
+		return decodeMap(r, readLength(r, 4, &err), depth)
+	}
+	return nil, fmt.Errorf("invalid msgpack type 0x%02x", b)
+}
+
+// readUint reads a big endian unsigned integer of size bytes. Errors are
+// stored in *err, unless it already holds one.
+func readUint(r *bufio.Reader, size int, err *error) uint64 {
+	if *err != nil {
+		return 0
+	}
+	buf := make([]byte, 8)
+	if _, *err = io.ReadFull(r, buf[8-size:]); *err != nil {
+		return 0
+	}
+	return binary.BigEndian.Uint64(buf)
+}
+
+// readLength reads a length like readUint. Invalid lengths are reported as -1.
+func readLength(r *bufio.Reader, size int, err *error) int {
+	n := readUint(r, size, err)
+	if *err != nil || n > maxLength {
+		return -1
+	}
+	return int(n)
+}
+
+func decodeString(r *bufio.Reader, n int) (interface{}, error) {
+	if n < 0 {
+		return nil, fmt.Errorf("invalid msgpack length")
+	}
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, err
+	}
+	return string(buf), nil
+}
+
+func decodeExtension(r *bufio.Reader, n int) (interface{}, error) {
+	if n < 0 {
+		return nil, fmt.Errorf("invalid msgpack length")
+	}
+	t, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(r, buf); err != nil {
+		return nil, err
+	}
+	return extension{Type: int8(t), Data: buf}, nil
+}
+
+func decodeArray(r *bufio.Reader, n, depth int) (interface{}, error) {
+	if n < 0 {
+		return nil, fmt.Errorf("invalid msgpack length")
+	}
+	if depth >= maxDepth {
+		return nil, fmt.Errorf("msgpack values nested too deeply")
+	}
+	array := make([]interface{}, 0, min(n, 1024))
+	for i := 0; i < n; i++ {
+		v, err := decodeValue(r, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		array = append(array, v)
+	}
+	return array, nil
+}
+
+func decodeMap(r *bufio.Reader, n, depth int) (interface{}, error) {
+	if n < 0 {
+		return nil, fmt.Errorf("invalid msgpack length")
+	}
+	if depth >= maxDepth {
+		return nil, fmt.Errorf("msgpack values nested too deeply")
+	}
+	m := make(map[interface{}]interface{}, min(n, 1024))
+	for i := 0; i < n; i++ {
+		key, err := decodeValue(r, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		value, err := decodeValue(r, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		switch key.(type) {
+		case map[interface{}]interface{}, []interface{}, extension:
+			return nil, fmt.Errorf("unsupported msgpack map key %v", key)
+		}
+		m[key] = value
+	}
+	return m, nil
+}
+
+// encodeAck encodes the acknowledgement of a chunk, {"ack": chunk}.
+func encodeAck(chunk string) []byte {
+	buf := []byte{0x81, 0xa3, 'a', 'c', 'k'}
+	switch {
+	case len(chunk) < 32:
+		buf = append(buf, 0xa0|byte(len(chunk)))
+	case len(chunk) < 256:
+		buf = append(buf, 0xd9, byte(len(chunk)))
+	default:
+		buf = append(buf, 0xda, byte(len(chunk)>>8), byte(len(chunk)))
+	}
+	return append(buf, chunk...)
+}
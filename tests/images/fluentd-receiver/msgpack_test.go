@@ -0,0 +1,152 @@
+// Copyright (c) Ansible Project
+// GNU General Public License v3.0+ (see COPYING or https://www.gnu.org/licenses/gpl-3.0.txt)
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func decode(input []byte) (interface{}, error) {
+	return decodeMsgpack(bufio.NewReader(bytes.NewReader(input)))
+}
+
+func TestDecodeMsgpack(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		input    []byte
+		expected interface{}
+	}{
+		{"positive fixint", []byte{0x05}, int64(5)},
+		{"negative fixint", []byte{0xff}, int64(-1)},
+		{"fixmap", []byte{0x81, 0xa1, 'k', 0x01}, map[interface{}]interface{}{"k": int64(1)}},
+		{"fixarray", []byte{0x92, 0x01, 0xc0}, []interface{}{int64(1), nil}},
+		{"fixstr", []byte{0xa3, 'a', 'b', 'c'}, "abc"},
+		{"nil", []byte{0xc0}, nil},
+		{"false", []byte{0xc2}, false},
+		{"true", []byte{0xc3}, true},
+		{"bin 8", []byte{0xc4, 0x02, 'h', 'i'}, "hi"},
+		{"bin 16", []byte{0xc5, 0x00, 0x02, 'h', 'i'}, "hi"},
+		{"bin 32", []byte{0xc6, 0x00, 0x00, 0x00, 0x02, 'h', 'i'}, "hi"},
+		{"ext 8", []byte{0xc7, 0x01, 0x05, 0xaa}, extension{Type: 5, Data: []byte{0xaa}}},
+		{"ext 16", []byte{0xc8, 0x00, 0x01, 0x05, 0xaa}, extension{Type: 5, Data: []byte{0xaa}}},
+		{"ext 32", []byte{0xc9, 0x00, 0x00, 0x00, 0x01, 0x05, 0xaa}, extension{Type: 5, Data: []byte{0xaa}}},
+		{"float 32", []byte{0xca, 0x3f, 0xc0, 0x00, 0x00}, 1.5},
+		{"float 64", []byte{0xcb, 0x3f, 0xf8, 0, 0, 0, 0, 0, 0}, 1.5},
+		{"uint 8", []byte{0xcc, 0xff}, uint64(255)},
+		{"uint 16", []byte{0xcd, 0x01, 0x00}, uint64(256)},
+		{"uint 32", []byte{0xce, 0x00, 0x01, 0x00, 0x00}, uint64(65536)},
+		{"uint 64", []byte{0xcf, 0, 0, 0, 1, 0, 0, 0, 0}, uint64(1 << 32)},
+		{"int 8", []byte{0xd0, 0x80}, int64(-128)},
+		{"int 16", []byte{0xd1, 0xff, 0x00}, int64(-256)},
+		{"int 32", []byte{0xd2, 0xff, 0xff, 0xff, 0x00}, int64(-256)},
+		{"int 64", []byte{0xd3, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x00}, int64(-256)},
+		{"fixext 1", []byte{0xd4, 0x01, 0xaa}, extension{Type: 1, Data: []byte{0xaa}}},
+		{"fixext 2", []byte{0xd5, 0x01, 0xaa, 0xbb}, extension{Type: 1, Data: []byte{0xaa, 0xbb}}},
+		{"fixext 4", []byte{0xd6, 0x01, 1, 2, 3, 4}, extension{Type: 1, Data: []byte{1, 2, 3, 4}}},
+		// EventTime: seconds and nanoseconds as 32-bit big endian integers.
+		{"fixext 8 event time", []byte{0xd7, 0x00, 0x65, 0x92, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01},
+			extension{Type: 0, Data: []byte{0x65, 0x92, 0x00, 0x00, 0x00, 0x00, 0x00, 0x01}}},
+		{"fixext 16", append([]byte{0xd8, 0x02}, make([]byte, 16)...), extension{Type: 2, Data: make([]byte, 16)}},
+		{"str 8", []byte{0xd9, 0x02, 'h', 'i'}, "hi"},
+		{"str 16", []byte{0xda, 0x00, 0x02, 'h', 'i'}, "hi"},
+		{"str 32", []byte{0xdb, 0x00, 0x00, 0x00, 0x02, 'h', 'i'}, "hi"},
+		{"array 16", []byte{0xdc, 0x00, 0x01, 0x07}, []interface{}{int64(7)}},
+		{"array 32", []byte{0xdd, 0x00, 0x00, 0x00, 0x01, 0x07}, []interface{}{int64(7)}},
+		{"map 16", []byte{0xde, 0x00, 0x01, 0xa1, 'k', 0xc3}, map[interface{}]interface{}{"k": true}},
+		{"map 32", []byte{0xdf, 0x00, 0x00, 0x00, 0x01, 0xa1, 'k', 0xc3}, map[interface{}]interface{}{"k": true}},
+		// Message mode: [tag, time, record, option]
+		{"message mode", []byte{
+			0x94, 0xa3, 'a', 'p', 'p', 0xce, 0x65, 0x92, 0x00, 0x00,
+			0x81, 0xa3, 'l', 'o', 'g', 0xa2, 'h', 'i',
+			0x81, 0xa5, 'c', 'h', 'u', 'n', 'k', 0xa1, 'x',
+		}, []interface{}{
+			"app", uint64(0x65920000),
+			map[interface{}]interface{}{"log": "hi"},
+			map[interface{}]interface{}{"chunk": "x"},
+		}},
+		// Forward mode: [tag, [[time, record], ...]]
+		{"forward mode", []byte{
+			0x92, 0xa3, 'a', 'p', 'p', 0x91, 0x92, 0x01, 0x80,
+		}, []interface{}{
+			"app", []interface{}{[]interface{}{int64(1), map[interface{}]interface{}{}}},
+		}},
+		// PackedForward mode: [tag, bin with concatenated entries]
+		{"packed forward mode", []byte{
+			0x92, 0xa3, 'a', 'p', 'p', 0xc4, 0x03, 0x92, 0x01, 0x80,
+		}, []interface{}{"app", "\x92\x01\x80"}},
+	} {
+		got, err := decode(tc.input)
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", tc.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, tc.expected) {
+			t.Errorf("%s: got %#v, expected %#v", tc.name, got, tc.expected)
+		}
+	}
+}
+
+func TestDecodeMsgpackErrors(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		input []byte
+		err   string
+	}{
+		{"empty", []byte{}, "EOF"},
+		{"reserved type", []byte{0xc1}, "invalid msgpack type 0xc1"},
+		{"truncated fixstr", []byte{0xa3, 'a'}, "unexpected EOF"},
+		{"truncated str 8 length", []byte{0xd9}, "invalid msgpack length"},
+		{"truncated str 16", []byte{0xda, 0x00, 0x05, 'a'}, "unexpected EOF"},
+		{"truncated bin 32 length", []byte{0xc6, 0x00, 0x00}, "invalid msgpack length"},
+		{"too long str 32", []byte{0xdb, 0xff, 0xff, 0xff, 0xff}, "invalid msgpack length"},
+		{"too long array 32", []byte{0xdd, 0xff, 0xff, 0xff, 0xff}, "invalid msgpack length"},
+		{"too long map 32", []byte{0xdf, 0xff, 0xff, 0xff, 0xff}, "invalid msgpack length"},
+		{"truncated ext 8", []byte{0xc7, 0x04, 0x00, 0x01}, "unexpected EOF"},
+		{"missing ext type", []byte{0xd7}, "EOF"},
+		{"truncated fixext 8", []byte{0xd7, 0x00, 0x01, 0x02}, "unexpected EOF"},
+		{"truncated float 64", []byte{0xcb, 0x3f, 0xf8}, "unexpected EOF"},
+		{"truncated uint 32", []byte{0xce, 0x01}, "unexpected EOF"},
+		{"truncated int 16", []byte{0xd1}, "EOF"},
+		{"truncated array", []byte{0x93, 0x01, 0x02}, "EOF"},
+		{"truncated array 16 length", []byte{0xdc, 0x00}, "invalid msgpack length"},
+		{"truncated map value", []byte{0x81, 0xa1, 'k'}, "EOF"},
+		{"truncated map 16", []byte{0xde, 0x00, 0x02, 0x01, 0x02}, "EOF"},
+		{"array map key", []byte{0x81, 0x90, 0x01}, "unsupported msgpack map key"},
+		{"map map key", []byte{0x81, 0x80, 0x01}, "unsupported msgpack map key"},
+		{"extension map key", []byte{0x81, 0xd4, 0x00, 0x00, 0x01}, "unsupported msgpack map key"},
+		{"truncated message mode", []byte{0x94, 0xa3, 'a', 'p', 'p', 0xce, 0x65}, "unexpected EOF"},
+		{"truncated forward entries", []byte{0x92, 0xa3, 'a', 'p', 'p', 0x92, 0x92, 0x01}, "EOF"},
+		{"deeply nested arrays", bytes.Repeat([]byte{0x91}, 100000), "nested too deeply"},
+		{"deeply nested maps", bytes.Repeat([]byte{0x81, 0x01}, 100000), "nested too deeply"},
+	} {
+		got, err := decode(tc.input)
+		if err == nil {
+			t.Errorf("%s: got %#v, expected an error", tc.name, got)
+		} else if !strings.Contains(err.Error(), tc.err) {
+			t.Errorf("%s: got error %q, expected %q", tc.name, err, tc.err)
+		}
+	}
+}
+
+func TestEncodeAck(t *testing.T) {
+	for _, chunk := range []string{"", "abc", strings.Repeat("x", 31), strings.Repeat("x", 32), strings.Repeat("x", 300)} {
+		got, err := decode(encodeAck(chunk))
+		if err != nil {
+			t.Errorf("chunk of length %d: %v", len(chunk), err)
+			continue
+		}
+		expected := map[interface{}]interface{}{"ack": chunk}
+		if !reflect.DeepEqual(got, expected) {
+			t.Errorf("chunk of length %d: got %#v", len(chunk), got)
+		}
+	}
+	if _, err := decode(encodeAck("x")[:4]); err != io.ErrUnexpectedEOF {
+		t.Errorf("truncated ack: got error %v, expected an unexpected EOF", err)
+	}
+}
@@ -0,0 +1,199 @@
+// Copyright (c) Ansible Project
+// GNU General Public License v3.0+ (see COPYING or https://www.gnu.org/licenses/gpl-3.0.txt)
+
+// Command gelf-receiver receives GELF messages over UDP, as sent by the Docker
+// gelf log driver, and serves them as JSON list on GET /messages:
+//
+//	[{"received":"2021-05-01T12:00:00.123Z","compression":"gzip","chunks":1,"message":{"version":"1.1","host":"docker-host","short_message":"hello","timestamp":1619870400.12,"level":6,"_container_name":"my-container","_tag":"8c3e..."}}]
+//
+// Messages can be uncompressed, or compressed with zlib or gzip, which is
+// reported as "none", "zlib" or "gzip". Chunked messages are reassembled;
+// incomplete ones are dropped after five seconds, as the GELF specification
+// requires.
+//
+// GET /messages?tag=TAG returns only the messages with that value of the
+// _tag field. DELETE /messages removes all messages received so far.
+//
+// Usage:
+//
+//	gelf-receiver [-udp ADDRESS] [-listen ADDRESS]
+//
+// The defaults are -udp :12201 and -listen :8080. The program runs until it
+// receives SIGTERM or SIGINT.
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ansible-collections/community.docker/tests/images/internal/fixture"
+)
+
+const (
+	// maxChunks is the maximum number of chunks of a message.
+	maxChunks = 128
+	// chunkTimeout is the time after which incomplete messages are dropped.
+	chunkTimeout = 5 * time.Second
+)
+
+var chunkMagic = []byte{0x1e, 0x0f}
+
+type message struct {
+	Received    time.Time              `json:"received"`
+	Compression string                 `json:"compression"`
+	Chunks      int                    `json:"chunks"`
+	Message     map[string]interface{} `json:"message"`
+}
+
+// partialMessage holds the chunks of a message received so far.
+type partialMessage struct {
+	first    time.Time
+	chunks   [][]byte
+	received int
+}
+
+type store struct {
+	mu       sync.Mutex
+	messages []message
+	partial  map[string]*partialMessage
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("gelf-receiver: ")
+
+	udpAddress := flag.String("udp", ":12201", "UDP address to receive messages on")
+	listen := flag.String("listen", ":8080", "address to serve the received messages on")
+	flag.Parse()
+
+	s := &store{partial: make(map[string]*partialMessage)}
+	conn, err := net.ListenPacket("udp", *udpAddress)
+	if err != nil {
+		log.Fatal(err)
+	}
+	go s.receive(conn)
+
+	mux := http.NewServeMux()
+	mux.Handle("/messages", fixture.Control{Get: s.list, Clear: s.clear})
+	fixture.Serve(*listen, mux)
+	fixture.WaitForSignal()
+}
+
+func (s *store) receive(conn net.PacketConn) {
+	buf := make([]byte, 65536)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			log.Fatal(err)
+		}
+		packet := append([]byte(nil), buf[:n]...)
+		if err := s.add(packet); err != nil {
+			log.Printf("%s: %v", addr, err)
+		}
+	}
+}
+
+// add processes a packet, which is either a complete message or a chunk.
+func (s *store) add(packet []byte) error {
+	if !bytes.HasPrefix(packet, chunkMagic) {
+		return s.addMessage(packet, 1)
+	}
+	if len(packet) < 12 {
+		return fmt.Errorf("chunk too short")
+	}
+	id := string(packet[2:10])
+	seq, count := int(packet[10]), int(packet[11])
+	if count == 0 || count > maxChunks || seq >= count {
+		return fmt.Errorf("invalid chunk %d of %d", seq, count)
+	}
+
+	s.mu.Lock()
+	now := time.Now()
+	for key, p := range s.partial {
+		if now.Sub(p.first) > chunkTimeout {
+			log.Printf("dropping incomplete message with %d of %d chunks", p.received, len(p.chunks))
+			delete(s.partial, key)
+		}
+	}
+	p := s.partial[id]
+	if p == nil {
+		p = &partialMessage{first: now, chunks: make([][]byte, count)}
+		s.partial[id] = p
+	}
+	if len(p.chunks) != count {
+		s.mu.Unlock()
+		return fmt.Errorf("chunk count changed from %d to %d", len(p.chunks), count)
+	}
+	if p.chunks[seq] == nil {
+		p.chunks[seq] = packet[12:]
+		p.received++
+	}
+	complete := p.received == count
+	if complete {
+		delete(s.partial, id)
+	}
+	s.mu.Unlock()
+
+	if !complete {
+		return nil
+	}
+	return s.addMessage(bytes.Join(p.chunks, nil), count)
+}
+
+// addMessage decompresses and decodes a complete message.
+func (s *store) addMessage(payload []byte, chunks int) error {
+	compression := "none"
+	var r io.Reader = bytes.NewReader(payload)
+	var err error
+	switch {
+	case bytes.HasPrefix(payload, []byte{0x1f, 0x8b}):
+		compression = "gzip"
+		r, err = gzip.NewReader(r)
+	case len(payload) > 0 && payload[0] == 0x78:
+		compression = "zlib"
+		r, err = zlib.NewReader(r)
+	}
+	if err != nil {
+		return fmt.Errorf("cannot decompress %s message: %v", compression, err)
+	}
+	m := message{Compression: compression, Chunks: chunks}
+	if err := json.NewDecoder(r).Decode(&m.Message); err != nil {
+		return fmt.Errorf("cannot decode %s message: %v", compression, err)
+	}
+	m.Received = time.Now().UTC()
+	s.mu.Lock()
+	s.messages = append(s.messages, m)
+	s.mu.Unlock()
+	return nil
+}
+
+// list returns the received messages for the /messages endpoint, optionally
+// filtered by the tag query parameter.
+func (s *store) list(r *http.Request) interface{} {
+	tag, filter := r.URL.Query()["tag"]
+	messages := []message{}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, m := range s.messages {
+		if !filter || m.Message["_tag"] == tag[0] {
+			messages = append(messages, m)
+		}
+	}
+	return messages
+}
+
+func (s *store) clear() {
+	s.mu.Lock()
+	s.messages = nil
+	s.mu.Unlock()
+}
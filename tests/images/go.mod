@@ -0,0 +1,9 @@
+module github.com/ansible-collections/community.docker/tests/images
+
+go 1.22
+
+require (
+	golang.org/x/crypto v0.33.0
+	golang.org/x/sys v0.30.0
+	gopkg.in/yaml.v3 v3.0.1
+)
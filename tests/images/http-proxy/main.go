@@ -0,0 +1,203 @@
+// Copyright (c) Ansible Project
+// GNU General Public License v3.0+ (see COPYING or https://www.gnu.org/licenses/gpl-3.0.txt)
+
+// Command http-proxy is an HTTP proxy for testing access to Docker daemons
+// through proxies. It tunnels CONNECT requests, which are used for HTTPS and
+// other TCP connections, and forwards plain HTTP requests with absolute
+// URLs.
+//
+// With -username and -password, clients have to authenticate with basic
+// authentication in the Proxy-Authorization header; otherwise the proxy
+// answers with status 407.
+//
+// Every proxied request is logged to stderr and recorded. GET /_mock/requests
+// returns the recorded requests as JSON list, and DELETE /_mock/requests
+// clears it:
+//
+//	[{"method":"CONNECT","target":"docker-host:2376","user":"proxyuser","status":200}]
+//
+// Usage:
+//
+//	http-proxy [-listen ADDRESS] [-username USERNAME -password PASSWORD]
+//
+// The default is -listen :3128. The program runs until it receives SIGTERM or
+// SIGINT.
+package main
+
+import (
+	"flag"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/ansible-collections/community.docker/tests/images/internal/fixture"
+)
+
+// hopHeaders are removed when forwarding requests and responses.
+var hopHeaders = []string{
+	"Connection", "Keep-Alive", "Proxy-Authenticate", "Proxy-Authorization",
+	"Proxy-Connection", "TE", "Trailer", "Transfer-Encoding", "Upgrade",
+}
+
+type recordedRequest struct {
+	Method string `json:"method"`
+	Target string `json:"target"`
+	User   string `json:"user"`
+	Status int    `json:"status"`
+}
+
+type proxy struct {
+	username  string
+	password  string
+	transport *http.Transport
+
+	mu       sync.Mutex
+	requests []recordedRequest
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("http-proxy: ")
+
+	listen := flag.String("listen", ":3128", "address to listen on")
+	username := flag.String("username", "", "require basic authentication with this username")
+	password := flag.String("password", "", "require basic authentication with this password")
+	flag.Parse()
+
+	if (*username == "") != (*password == "") {
+		log.Fatal("-username and -password must be given together")
+	}
+
+	p := &proxy{
+		username:  *username,
+		password:  *password,
+		transport: &http.Transport{Proxy: nil, DisableKeepAlives: true},
+	}
+	fixture.Serve(*listen, p)
+	fixture.WaitForSignal()
+}
+
+func (p *proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodConnect && !r.URL.IsAbs() {
+		p.serveControl(w, r)
+		return
+	}
+	target := r.Host
+	if r.Method != http.MethodConnect {
+		target = r.URL.String()
+	}
+	user, status := p.authenticate(r)
+	if status == http.StatusOK {
+		if r.Method == http.MethodConnect {
+			status = p.tunnel(w, r)
+		} else {
+			status = p.forward(w, r)
+		}
+	} else {
+		w.Header().Set("Proxy-Authenticate", `Basic realm="http-proxy"`)
+		http.Error(w, "proxy authentication required", status)
+	}
+	log.Printf("%s %s %s %d", r.Method, target, user, status)
+	p.mu.Lock()
+	p.requests = append(p.requests, recordedRequest{Method: r.Method, Target: target, User: user, Status: status})
+	p.mu.Unlock()
+}
+
+// authenticate returns the user of the request, and whether it may use the
+// proxy.
+func (p *proxy) authenticate(r *http.Request) (string, int) {
+	// Parse the header with the logic for the Authorization header.
+	creds := &http.Request{Header: http.Header{"Authorization": r.Header["Proxy-Authorization"]}}
+	user, password, ok := creds.BasicAuth()
+	if p.username == "" {
+		return user, http.StatusOK
+	}
+	if !ok || user != p.username || password != p.password {
+		return user, http.StatusProxyAuthRequired
+	}
+	return user, http.StatusOK
+}
+
+// tunnel connects the client to the target of a CONNECT request, and returns
+// the status sent to the client.
+func (p *proxy) tunnel(w http.ResponseWriter, r *http.Request) int {
+	up, err := net.DialTimeout("tcp", r.Host, 10*time.Second)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return http.StatusBadGateway
+	}
+	conn, buf, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		up.Close()
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return http.StatusInternalServerError
+	}
+	buf.WriteString("HTTP/1.1 200 Connection established\r\n\r\n")
+	if err := buf.Flush(); err != nil {
+		conn.Close()
+		up.Close()
+		return http.StatusOK
+	}
+	go func() {
+		defer conn.Close()
+		defer up.Close()
+		go func() {
+			// Data the client sent along with the request is buffered.
+			io.Copy(up, buf)
+			if closer, ok := up.(interface{ CloseWrite() error }); ok {
+				closer.CloseWrite()
+			}
+		}()
+		io.Copy(conn, up)
+	}()
+	return http.StatusOK
+}
+
+// forward passes a plain HTTP request on, and returns the response status.
+func (p *proxy) forward(w http.ResponseWriter, r *http.Request) int {
+	out := r.Clone(r.Context())
+	out.RequestURI = ""
+	for _, header := range hopHeaders {
+		out.Header.Del(header)
+	}
+	resp, err := p.transport.RoundTrip(out)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return http.StatusBadGateway
+	}
+	defer resp.Body.Close()
+	for _, header := range hopHeaders {
+		resp.Header.Del(header)
+	}
+	for key, values := range resp.Header {
+		w.Header()[key] = values
+	}
+	w.WriteHeader(resp.StatusCode)
+	io.Copy(w, resp.Body)
+	return resp.StatusCode
+}
+
+// serveControl handles requests addressed to the proxy itself.
+func (p *proxy) serveControl(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/_mock/requests" {
+		http.NotFound(w, r)
+		return
+	}
+	fixture.Control{Get: p.listRequests, Clear: p.clearRequests}.ServeHTTP(w, r)
+}
+
+// listRequests returns a snapshot of the recorded requests.
+func (p *proxy) listRequests(*http.Request) interface{} {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]recordedRequest{}, p.requests...)
+}
+
+func (p *proxy) clearRequests() {
+	p.mu.Lock()
+	p.requests = nil
+	p.mu.Unlock()
+}
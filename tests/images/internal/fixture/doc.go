@@ -0,0 +1,10 @@
+// Copyright (c) Ansible Project
+// GNU General Public License v3.0+ (see COPYING or https://www.gnu.org/licenses/gpl-3.0.txt)
+
+// Package fixture contains helpers shared by the test image programs: writing
+// JSON reports, waiting for termination signals, serving reports and mock
+// control endpoints over HTTP, logging requests, serving Docker plugins on
+// their socket, matching requests against mock rules like injected faults,
+// checking whether targets are reachable, naming error numbers, and parsing
+// command line values.
+package fixture
@@ -0,0 +1,112 @@
+// Copyright (c) Ansible Project
+// GNU General Public License v3.0+ (see COPYING or https://www.gnu.org/licenses/gpl-3.0.txt)
+
+package fixture
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+)
+
+// Serve serves handler on address in the background. The program ends if the
+// server fails.
+func Serve(address string, handler http.Handler) {
+	go func() {
+		log.Fatal(http.ListenAndServe(address, handler))
+	}()
+}
+
+// ServeJSON responds with the JSON encoding of v.
+func ServeJSON(w http.ResponseWriter, v interface{}) {
+	ServeJSONStatus(w, http.StatusOK, v)
+}
+
+// ServeJSONStatus responds with status and the JSON encoding of v.
+func ServeJSONStatus(w http.ResponseWriter, status int, v interface{}) {
+	content, err := json.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(append(content, '\n'))
+}
+
+// ReportHandler returns a handler responding to GET requests with the report
+// returned by read.
+func ReportHandler(read func() (interface{}, error)) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		report, err := read()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		ServeJSON(w, report)
+	})
+}
+
+// Control is the handler of a mock control endpoint. GET requests respond
+// with the value returned by Get, PUT requests pass their JSON body to Set,
+// and DELETE requests call Clear. PUT requests are rejected if Set is nil.
+//
+// The functions are responsible for their own locking. Since the value
+// returned by Get is encoded after Get returned, it must be a snapshot which
+// is not modified afterwards.
+type Control struct {
+	Get   func(r *http.Request) interface{}
+	Set   func(content []byte) error
+	Clear func()
+}
+
+func (c Control) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.Method == http.MethodGet:
+		ServeJSON(w, c.Get(r))
+	case r.Method == http.MethodPut && c.Set != nil:
+		var content json.RawMessage
+		if err := json.NewDecoder(r.Body).Decode(&content); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := c.Set(content); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == http.MethodDelete:
+		c.Clear()
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+// Unwrap allows http.ResponseController to hijack the connection.
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// LogRequests logs every request with its response status to stderr.
+func LogRequests(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		handler.ServeHTTP(sw, r)
+		log.Printf("%s %s %d", r.Method, r.URL.Path, sw.status)
+	})
+}
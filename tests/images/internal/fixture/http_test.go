@@ -0,0 +1,141 @@
+// Copyright (c) Ansible Project
+// GNU General Public License v3.0+ (see COPYING or https://www.gnu.org/licenses/gpl-3.0.txt)
+
+package fixture
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestReportHandler(t *testing.T) {
+	calls := 0
+	handler := ReportHandler(func() (interface{}, error) {
+		calls++
+		if calls > 1 {
+			return nil, errors.New("gone")
+		}
+		return map[string]int{"calls": calls}, nil
+	})
+
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusOK || w.Body.String() != "{\"calls\":1}\n" {
+		t.Errorf("first GET: got %d %q", w.Code, w.Body.String())
+	}
+	if got := w.Header().Get("Content-Type"); got != "application/json" {
+		t.Errorf("Content-Type = %q", got)
+	}
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusInternalServerError || !strings.Contains(w.Body.String(), "gone") {
+		t.Errorf("failing GET: got %d %q", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	handler.ServeHTTP(w, httptest.NewRequest(http.MethodPost, "/", nil))
+	if w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST: got %d", w.Code)
+	}
+}
+
+func TestServeJSONStatus(t *testing.T) {
+	w := httptest.NewRecorder()
+	ServeJSONStatus(w, http.StatusCreated, map[string]string{"Id": "abc"})
+	if w.Code != http.StatusCreated || w.Body.String() != "{\"Id\":\"abc\"}\n" {
+		t.Errorf("got %d %q", w.Code, w.Body.String())
+	}
+
+	w = httptest.NewRecorder()
+	ServeJSONStatus(w, http.StatusCreated, func() {})
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("unencodable value: got %d", w.Code)
+	}
+}
+
+func TestControl(t *testing.T) {
+	list := []string{}
+	control := Control{
+		Get: func(r *http.Request) interface{} {
+			return list
+		},
+		Set: func(content []byte) error {
+			var entries []string
+			if err := json.Unmarshal(content, &entries); err != nil {
+				return err
+			}
+			list = entries
+			return nil
+		},
+		Clear: func() {
+			list = []string{}
+		},
+	}
+	serve := func(method, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		control.ServeHTTP(w, httptest.NewRequest(method, "/_mock/list", strings.NewReader(body)))
+		return w
+	}
+
+	if w := serve(http.MethodPut, `["a","b"]`); w.Code != http.StatusNoContent {
+		t.Fatalf("PUT: got %d %q", w.Code, w.Body.String())
+	}
+	if w := serve(http.MethodGet, ""); w.Body.String() != "[\"a\",\"b\"]\n" {
+		t.Errorf("GET after PUT: got %q", w.Body.String())
+	}
+	for _, body := range []string{`{"a":1}`, `[`} {
+		if w := serve(http.MethodPut, body); w.Code != http.StatusBadRequest {
+			t.Errorf("PUT %s: got %d", body, w.Code)
+		}
+	}
+	if w := serve(http.MethodDelete, ""); w.Code != http.StatusNoContent {
+		t.Errorf("DELETE: got %d", w.Code)
+	}
+	if w := serve(http.MethodGet, ""); w.Body.String() != "[]\n" {
+		t.Errorf("GET after DELETE: got %q", w.Body.String())
+	}
+	if w := serve(http.MethodPost, ""); w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("POST: got %d", w.Code)
+	}
+
+	control.Set = nil
+	if w := serve(http.MethodPut, `["c"]`); w.Code != http.StatusMethodNotAllowed {
+		t.Errorf("PUT without Set: got %d", w.Code)
+	}
+}
+
+func TestLogRequests(t *testing.T) {
+	var buf bytes.Buffer
+	flags := log.Flags()
+	log.SetOutput(&buf)
+	log.SetFlags(0)
+	defer func() {
+		log.SetOutput(os.Stderr)
+		log.SetFlags(flags)
+	}()
+
+	handler := LogRequests(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		if http.NewResponseController(w).Flush() != nil {
+			t.Error("cannot flush through the status writer")
+		}
+	}))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/found", nil))
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodDelete, "/missing", nil))
+
+	want := "GET /found 200\nDELETE /missing 404\n"
+	if buf.String() != want {
+		t.Errorf("logged %q, want %q", buf.String(), want)
+	}
+}
@@ -0,0 +1,33 @@
+// Copyright (c) Ansible Project
+// GNU General Public License v3.0+ (see COPYING or https://www.gnu.org/licenses/gpl-3.0.txt)
+
+package fixture
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// WriteJSON prints v as a single line of JSON on stdout.
+func WriteJSON(v interface{}) {
+	if err := json.NewEncoder(os.Stdout).Encode(v); err != nil {
+		log.Fatalf("cannot write JSON: %v", err)
+	}
+}
+
+// WaitForSignal blocks until SIGTERM or SIGINT is received. Installing a
+// handler is required since signals without one are ignored for PID 1.
+func WaitForSignal() {
+	<-TerminationSignals()
+}
+
+// TerminationSignals returns a channel which receives SIGTERM and SIGINT, for
+// programs which wait for them together with other events.
+func TerminationSignals() <-chan os.Signal {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGTERM, syscall.SIGINT)
+	return ch
+}
@@ -0,0 +1,70 @@
+// Copyright (c) Ansible Project
+// GNU General Public License v3.0+ (see COPYING or https://www.gnu.org/licenses/gpl-3.0.txt)
+
+package fixture
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		input string
+		want  int64
+	}{
+		{"0", 0},
+		{"512", 512},
+		{"16k", 16 << 10},
+		{"16M", 16 << 20},
+		{"16MB", 16 << 20},
+		{"1GiB", 1 << 30},
+		{" 1.5 g ", 3 << 29},
+		{"2T", 2 << 40},
+	}
+	for _, test := range tests {
+		got, err := ParseSize(test.input)
+		if err != nil {
+			t.Errorf("ParseSize(%q) failed: %v", test.input, err)
+		} else if got != test.want {
+			t.Errorf("ParseSize(%q) = %d, want %d", test.input, got, test.want)
+		}
+	}
+}
+
+func TestParseSizeInvalid(t *testing.T) {
+	for _, input := range []string{"", "M", "16X", "1..5M", "-1", "16MM"} {
+		if got, err := ParseSize(input); err == nil {
+			t.Errorf("ParseSize(%q) = %d, want error", input, got)
+		}
+	}
+}
+
+func TestParsePorts(t *testing.T) {
+	tests := []struct {
+		input string
+		want  []int
+	}{
+		{"", nil},
+		{"80", []int{80}},
+		{"8000-8003", []int{8000, 8001, 8002, 8003}},
+		{"9000,8000-8001, 9000", []int{9000, 8000, 8001}},
+		{"65535-65535", []int{65535}},
+	}
+	for _, test := range tests {
+		got, err := ParsePorts(test.input, 10)
+		if err != nil {
+			t.Errorf("ParsePorts(%q) failed: %v", test.input, err)
+		} else if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("ParsePorts(%q) = %v, want %v", test.input, got, test.want)
+		}
+	}
+}
+
+func TestParsePortsInvalid(t *testing.T) {
+	for _, input := range []string{"0", "65536", "http", "80,", "90-80", "1-", "1-11"} {
+		if got, err := ParsePorts(input, 10); err == nil {
+			t.Errorf("ParsePorts(%q) = %v, want error", input, got)
+		}
+	}
+}
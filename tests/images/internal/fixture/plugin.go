@@ -0,0 +1,49 @@
+// Copyright (c) Ansible Project
+// GNU General Public License v3.0+ (see COPYING or https://www.gnu.org/licenses/gpl-3.0.txt)
+
+package fixture
+
+import (
+	"encoding/json"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+)
+
+// NewPluginMux returns a mux for a Docker plugin which answers the activation
+// handshake with the subsystems the plugin implements, like VolumeDriver.
+func NewPluginMux(implements ...string) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/Plugin.Activate", func(w http.ResponseWriter, r *http.Request) {
+		ServePluginJSON(w, map[string]interface{}{"Implements": implements})
+	})
+	return mux
+}
+
+// ServePluginJSON responds with the JSON encoding of v in the media type of
+// the plugin protocol.
+func ServePluginJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/vnd.docker.plugins.v1+json")
+	json.NewEncoder(w).Encode(v)
+}
+
+// ServePlugin serves handler on the plugin socket at path until SIGTERM or
+// SIGINT is received, and removes the socket afterwards. A stale socket is
+// replaced. The program ends if the server fails.
+func ServePlugin(path string, handler http.Handler) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		log.Fatal(err)
+	}
+	os.Remove(path)
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer os.Remove(path)
+	go func() {
+		log.Fatal(http.Serve(listener, handler))
+	}()
+	WaitForSignal()
+}
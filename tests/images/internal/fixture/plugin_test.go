@@ -0,0 +1,34 @@
+// Copyright (c) Ansible Project
+// GNU General Public License v3.0+ (see COPYING or https://www.gnu.org/licenses/gpl-3.0.txt)
+
+package fixture
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNewPluginMux(t *testing.T) {
+	mux := NewPluginMux("VolumeDriver", "authz")
+	mux.HandleFunc("/VolumeDriver.Get", func(w http.ResponseWriter, r *http.Request) {
+		ServePluginJSON(w, map[string]string{"Err": "no such volume"})
+	})
+
+	for _, tc := range []struct {
+		path     string
+		expected string
+	}{
+		{"/Plugin.Activate", "{\"Implements\":[\"VolumeDriver\",\"authz\"]}\n"},
+		{"/VolumeDriver.Get", "{\"Err\":\"no such volume\"}\n"},
+	} {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest(http.MethodPost, tc.path, nil))
+		if w.Code != http.StatusOK || w.Body.String() != tc.expected {
+			t.Errorf("%s: got %d %q, expected %q", tc.path, w.Code, w.Body.String(), tc.expected)
+		}
+		if got := w.Header().Get("Content-Type"); got != "application/vnd.docker.plugins.v1+json" {
+			t.Errorf("%s: Content-Type = %q", tc.path, got)
+		}
+	}
+}
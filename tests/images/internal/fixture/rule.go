@@ -0,0 +1,76 @@
+// Copyright (c) Ansible Project
+// GNU General Public License v3.0+ (see COPYING or https://www.gnu.org/licenses/gpl-3.0.txt)
+
+package fixture
+
+import (
+	"fmt"
+	"regexp"
+)
+
+var apiVersionRegexp = regexp.MustCompile(`^/v[0-9]+\.[0-9]+/`)
+
+// StripAPIVersion removes the version prefix, like /v1.43, from a Docker
+// Engine API path.
+func StripAPIVersion(path string) string {
+	return apiVersionRegexp.ReplaceAllString(path, "/")
+}
+
+// RequestRule selects the requests a mock rule, like an injected fault or a
+// scripted response, applies to: by method (any if empty), and by a regular
+// expression for the path. With a positive count, the rule only applies to
+// the next count matching requests. Rule types embed it.
+type RequestRule struct {
+	Method string `json:"method,omitempty"`
+	Path   string `json:"path,omitempty"`
+	Count  int    `json:"count,omitempty"`
+
+	pathRegexp *regexp.Regexp
+}
+
+// Rule is implemented by pointers to rule types embedding RequestRule.
+type Rule interface {
+	requestRule() *RequestRule
+}
+
+func (rr *RequestRule) requestRule() *RequestRule {
+	return rr
+}
+
+// CompilePath compiles the path expression. It must be called before the
+// rule is matched.
+func (rr *RequestRule) CompilePath() error {
+	var err error
+	if rr.pathRegexp, err = regexp.Compile(rr.Path); err != nil {
+		return fmt.Errorf("invalid path %q: %v", rr.Path, err)
+	}
+	return nil
+}
+
+// Matches tells whether a request with method and path is selected.
+func (rr *RequestRule) Matches(method, path string) bool {
+	return (rr.Method == "" || rr.Method == method) && rr.pathRegexp.MatchString(path)
+}
+
+// Match returns the first rule in *rules which selects a request with method
+// and path, and for which accept, if not nil, returns true as well. The rule
+// is counted as used, and removed from *rules when its count is exhausted.
+// The zero value is returned if no rule matches. The caller must hold the
+// lock protecting *rules.
+func Match[R Rule](rules *[]R, method, path string, accept func(R) bool) R {
+	for i, rule := range *rules {
+		rr := rule.requestRule()
+		if !rr.Matches(method, path) || (accept != nil && !accept(rule)) {
+			continue
+		}
+		if rr.Count > 0 {
+			rr.Count--
+			if rr.Count == 0 {
+				*rules = append((*rules)[:i:i], (*rules)[i+1:]...)
+			}
+		}
+		return rule
+	}
+	var none R
+	return none
+}
@@ -0,0 +1,81 @@
+// Copyright (c) Ansible Project
+// GNU General Public License v3.0+ (see COPYING or https://www.gnu.org/licenses/gpl-3.0.txt)
+
+package fixture
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+type testRule struct {
+	RequestRule
+	Name string `json:"name"`
+}
+
+func TestMatch(t *testing.T) {
+	var rules []*testRule
+	err := json.Unmarshal([]byte(`[
+		{"method": "POST", "path": "^/containers/", "count": 2, "name": "create"},
+		{"path": "^/containers/", "name": "reject"},
+		{"path": ".*", "name": "any"}
+	]`), &rules)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, rule := range rules {
+		if err := rule.CompilePath(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	notReject := func(rule *testRule) bool {
+		return rule.Name != "reject"
+	}
+
+	for i, tc := range []struct {
+		method, path string
+		accept       func(*testRule) bool
+		expected     string
+		remaining    int
+	}{
+		{"GET", "/containers/json", nil, "reject", 3},
+		{"POST", "/containers/create", nil, "create", 3},
+		{"POST", "/containers/create", nil, "create", 2},
+		{"POST", "/containers/create", nil, "reject", 2},
+		{"GET", "/containers/json", notReject, "any", 2},
+		{"GET", "/version", nil, "any", 2},
+	} {
+		got := Match(&rules, tc.method, tc.path, tc.accept)
+		if got == nil || got.Name != tc.expected {
+			t.Errorf("%d: %s %s matched %+v, expected %s", i, tc.method, tc.path, got, tc.expected)
+		}
+		if len(rules) != tc.remaining {
+			t.Errorf("%d: %d rules remaining, expected %d", i, len(rules), tc.remaining)
+		}
+	}
+
+	rules = rules[:1]
+	if got := Match(&rules, "GET", "/version", nil); got != nil {
+		t.Errorf("matched %+v, expected nil", got)
+	}
+}
+
+func TestCompilePath(t *testing.T) {
+	rule := &RequestRule{Path: "("}
+	if err := rule.CompilePath(); err == nil {
+		t.Error("expected an error for an invalid expression")
+	}
+}
+
+func TestStripAPIVersion(t *testing.T) {
+	for path, expected := range map[string]string{
+		"/v1.43/containers/json": "/containers/json",
+		"/containers/json":       "/containers/json",
+		"/v1/version":            "/v1/version",
+		"/_ping":                 "/_ping",
+	} {
+		if got := StripAPIVersion(path); got != expected {
+			t.Errorf("StripAPIVersion(%q) = %q, expected %q", path, got, expected)
+		}
+	}
+}
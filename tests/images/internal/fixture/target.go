@@ -0,0 +1,77 @@
+// Copyright (c) Ansible Project
+// GNU General Public License v3.0+ (see COPYING or https://www.gnu.org/licenses/gpl-3.0.txt)
+
+package fixture
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+	"net/http"
+	"syscall"
+)
+
+var errnoNames = map[syscall.Errno]string{
+	syscall.EACCES:        "EACCES",
+	syscall.EADDRNOTAVAIL: "EADDRNOTAVAIL",
+	syscall.ECONNREFUSED:  "ECONNREFUSED",
+	syscall.ECONNRESET:    "ECONNRESET",
+	syscall.EDQUOT:        "EDQUOT",
+	syscall.EEXIST:        "EEXIST",
+	syscall.EHOSTUNREACH:  "EHOSTUNREACH",
+	syscall.EINVAL:        "EINVAL",
+	syscall.EISDIR:        "EISDIR",
+	syscall.ENETUNREACH:   "ENETUNREACH",
+	syscall.ENOENT:        "ENOENT",
+	syscall.ENOSPC:        "ENOSPC",
+	syscall.ENOTDIR:       "ENOTDIR",
+	syscall.EPERM:         "EPERM",
+	syscall.EROFS:         "EROFS",
+	syscall.ETIMEDOUT:     "ETIMEDOUT",
+	syscall.ETXTBSY:       "ETXTBSY",
+}
+
+// ErrnoName returns the name of the error number err wraps, like
+// ECONNREFUSED, or an empty string if it wraps none or an unknown one.
+func ErrnoName(err error) string {
+	var errno syscall.Errno
+	if errors.As(err, &errno) {
+		return errnoNames[errno]
+	}
+	return ""
+}
+
+// CheckTCP checks a tcp://HOST:PORT target by establishing a connection to
+// address and closing it again.
+func CheckTCP(ctx context.Context, address string) error {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return err
+	}
+	return conn.Close()
+}
+
+// NewHTTPClient returns a client for checking http:// and https:// targets,
+// which does not verify certificates if insecure is true.
+func NewHTTPClient(insecure bool) *http.Client {
+	return &http.Client{Transport: &http.Transport{
+		TLSClientConfig: &tls.Config{InsecureSkipVerify: insecure},
+	}}
+}
+
+// CheckHTTP checks an http:// or https:// target by sending a GET request to
+// it, and returns the status of the response.
+func CheckHTTP(ctx context.Context, client *http.Client, target string) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,76 @@
+// Copyright (c) Ansible Project
+// GNU General Public License v3.0+ (see COPYING or https://www.gnu.org/licenses/gpl-3.0.txt)
+
+package fixture
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"syscall"
+	"testing"
+)
+
+func TestErrnoName(t *testing.T) {
+	for _, tc := range []struct {
+		err      error
+		expected string
+	}{
+		{syscall.ECONNREFUSED, "ECONNREFUSED"},
+		{&os.PathError{Op: "open", Path: "/x", Err: syscall.EROFS}, "EROFS"},
+		{fmt.Errorf("dial: %w", &net.OpError{Op: "dial", Err: os.NewSyscallError("connect", syscall.EHOSTUNREACH)}), "EHOSTUNREACH"},
+		{syscall.EBADF, ""},
+		{errors.New("no errno"), ""},
+	} {
+		if got := ErrnoName(tc.err); got != tc.expected {
+			t.Errorf("ErrnoName(%v) = %q, expected %q", tc.err, got, tc.expected)
+		}
+	}
+}
+
+func TestCheckTCP(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := listener.Addr().String()
+	if err := CheckTCP(context.Background(), address); err != nil {
+		t.Errorf("open port: %v", err)
+	}
+	listener.Close()
+	if err := CheckTCP(context.Background(), address); ErrnoName(err) != "ECONNREFUSED" {
+		t.Errorf("closed port: got %v, expected ECONNREFUSED", err)
+	}
+}
+
+func TestCheckHTTP(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+	server := httptest.NewServer(handler)
+	defer server.Close()
+	tlsServer := httptest.NewTLSServer(handler)
+	defer tlsServer.Close()
+
+	for _, tc := range []struct {
+		name     string
+		insecure bool
+		target   string
+		status   int
+		fails    bool
+	}{
+		{"http", false, server.URL, http.StatusTeapot, false},
+		{"https insecure", true, tlsServer.URL, http.StatusTeapot, false},
+		{"https unverified certificate", false, tlsServer.URL, 0, true},
+		{"invalid url", false, "http://[::1", 0, true},
+	} {
+		status, err := CheckHTTP(context.Background(), NewHTTPClient(tc.insecure), tc.target)
+		if status != tc.status || (err != nil) != tc.fails {
+			t.Errorf("%s: got %d, %v, expected %d", tc.name, status, err, tc.status)
+		}
+	}
+}
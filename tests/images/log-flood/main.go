@@ -0,0 +1,169 @@
+// Copyright (c) Ansible Project
+// GNU General Public License v3.0+ (see COPYING or https://www.gnu.org/licenses/gpl-3.0.txt)
+
+// Command log-flood writes numbered lines at a target rate, to test log
+// drivers and the handling of large amounts of container output. Every line
+// has the same length; it starts with its zero-based sequence number as
+// twelve digits, which allows to verify that no lines were lost or
+// reordered, and is padded with dots:
+//
+//	000000000042 ...................................................
+//
+// When the total has been written, or SIGTERM or SIGINT is received, a
+// summary is printed as a single line of JSON on stderr:
+//
+//	{"lines":1048576,"bytes":104857600,"elapsed_seconds":20.001,"bytes_per_second":5242618.9}
+//
+// Usage:
+//
+//	log-flood [-rate SIZE/s] [-total SIZE] [-line-size BYTES] [-stream stdout|stderr] [-keep-running]
+//
+// SIZE is a number of bytes with an optional unit (B, K, M, G, or the
+// equivalent KB/KiB, MB/MiB, GB/GiB; all units are powers of 1024); the
+// rate may end in /s. The defaults are -rate 1MiB/s, -total 10MiB,
+// -line-size 100 and -stream stdout. A rate of 0 writes as fast as
+// possible, and a total of 0 writes until a signal is received. Only
+// complete lines are written, so the total is rounded down to a multiple of
+// the line size. With -keep-running, the program stays alive after writing
+// the total until it receives SIGTERM or SIGINT.
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ansible-collections/community.docker/tests/images/internal/fixture"
+)
+
+// tick is the interval in which a rate limited flood writes the lines due.
+const tick = 10 * time.Millisecond
+
+// batchSize is the amount of output written at once without a rate limit.
+// Lines longer than that are written one at a time.
+const batchSize = 64 << 10
+
+type summary struct {
+	Lines          int64   `json:"lines"`
+	Bytes          int64   `json:"bytes"`
+	ElapsedSeconds float64 `json:"elapsed_seconds"`
+	BytesPerSecond float64 `json:"bytes_per_second"`
+}
+
+type flood struct {
+	out      io.Writer
+	lineSize int
+	lines    int64
+	buf      bytes.Buffer
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("log-flood: ")
+
+	rateFlag := flag.String("rate", "1MiB/s", "bytes per second (0 means unlimited)")
+	totalFlag := flag.String("total", "10MiB", "total bytes to write (0 means until SIGTERM or SIGINT)")
+	lineSize := flag.Int("line-size", 100, "length of every line including the newline")
+	stream := flag.String("stream", "stdout", "stream to write to: stdout or stderr")
+	keepRunning := flag.Bool("keep-running", false, "keep running after writing until SIGTERM or SIGINT")
+	flag.Parse()
+
+	rate, err := fixture.ParseSize(strings.TrimSuffix(*rateFlag, "/s"))
+	if err != nil {
+		fmt.Fprintf(flag.CommandLine.Output(), "invalid -rate: %v\n", err)
+		os.Exit(2)
+	}
+	total, err := fixture.ParseSize(*totalFlag)
+	if err != nil {
+		fmt.Fprintf(flag.CommandLine.Output(), "invalid -total: %v\n", err)
+		os.Exit(2)
+	}
+	if *lineSize < 14 {
+		fmt.Fprintln(flag.CommandLine.Output(), "-line-size must be at least 14")
+		os.Exit(2)
+	}
+	f := &flood{lineSize: *lineSize}
+	switch *stream {
+	case "stdout":
+		f.out = os.Stdout
+	case "stderr":
+		f.out = os.Stderr
+	default:
+		fmt.Fprintf(flag.CommandLine.Output(), "invalid -stream %q\n", *stream)
+		os.Exit(2)
+	}
+	maxLines := total / int64(*lineSize)
+
+	start := time.Now()
+	signals := fixture.TerminationSignals()
+	ticker := time.NewTicker(tick)
+	defer ticker.Stop()
+	interrupted := false
+	for !interrupted && (total == 0 || f.lines < maxLines) {
+		var due int64
+		if rate == 0 {
+			due = f.lines + max(1, batchSize/int64(*lineSize))
+			select {
+			case <-signals:
+				interrupted = true
+			default:
+			}
+		} else {
+			select {
+			case <-signals:
+				interrupted = true
+			case <-ticker.C:
+			}
+			due = int64(time.Since(start).Seconds() * float64(rate) / float64(*lineSize))
+		}
+		if total != 0 && due > maxLines {
+			due = maxLines
+		}
+		if err := f.write(due - f.lines); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	elapsed := time.Since(start).Seconds()
+	written := f.lines * int64(*lineSize)
+	s := summary{Lines: f.lines, Bytes: written, ElapsedSeconds: elapsed}
+	if elapsed > 0 {
+		s.BytesPerSecond = float64(written) / elapsed
+	}
+	writeSummary(s)
+
+	if *keepRunning && !interrupted {
+		<-signals
+	}
+}
+
+// write writes the next n lines with a single write call.
+func (f *flood) write(n int64) error {
+	if n <= 0 {
+		return nil
+	}
+	f.buf.Reset()
+	padding := strings.Repeat(".", f.lineSize-14)
+	for i := int64(0); i < n; i++ {
+		fmt.Fprintf(&f.buf, "%012d %s\n", f.lines+i, padding)
+	}
+	if _, err := f.out.Write(f.buf.Bytes()); err != nil {
+		return err
+	}
+	f.lines += n
+	return nil
+}
+
+func writeSummary(s summary) {
+	content, err := json.Marshal(s)
+	if err != nil {
+		log.Fatalf("cannot write JSON: %v", err)
+	}
+	os.Stderr.Write(append(content, '\n'))
+}
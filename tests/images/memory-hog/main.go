@@ -0,0 +1,97 @@
+// Copyright (c) Ansible Project
+// GNU General Public License v3.0+ (see COPYING or https://www.gnu.org/licenses/gpl-3.0.txt)
+
+// Command memory-hog allocates memory at a configurable rate up to a
+// configurable ceiling. Every allocated page is written to, so that the
+// memory is actually resident and counts against the container's memory
+// limit. This allows to trigger the OOM killer in a controlled way.
+//
+// After every allocation step, the total amount allocated so far is printed
+// as a single line of JSON on stdout:
+//
+//	{"allocated":16777216,"done":false}
+//
+// Once the ceiling is reached, a last line with "done":true is printed, and
+// the program keeps the memory allocated until it receives SIGTERM or
+// SIGINT.
+//
+// Usage:
+//
+//	memory-hog [-rate SIZE] [-interval DURATION] [-limit SIZE]
+//
+// SIZE is a number of bytes with an optional unit (B, K, M, G, or the
+// equivalent KB/KiB, MB/MiB, GB/GiB; all units are powers of 1024). The
+// default rate is 16M per second. A limit of 0, the default, allocates until
+// the process is killed.
+package main
+
+import (
+	"flag"
+	"log"
+	"runtime"
+	"time"
+
+	"github.com/ansible-collections/community.docker/tests/images/internal/fixture"
+)
+
+const pageSize = 4096
+
+type progress struct {
+	Allocated int64 `json:"allocated"`
+	Done      bool  `json:"done"`
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("memory-hog: ")
+
+	rateFlag := flag.String("rate", "16M", "amount of memory to allocate per second")
+	interval := flag.Duration("interval", 100*time.Millisecond, "time between two allocation steps")
+	limitFlag := flag.String("limit", "0", "stop allocating after this amount of memory (0 means never stop)")
+	flag.Parse()
+
+	rate, err := fixture.ParseSize(*rateFlag)
+	if err != nil || rate <= 0 {
+		log.Fatalf("invalid rate %q", *rateFlag)
+	}
+	limit, err := fixture.ParseSize(*limitFlag)
+	if err != nil {
+		log.Fatalf("invalid limit %q", *limitFlag)
+	}
+	if *interval <= 0 {
+		log.Fatalf("invalid interval %s", *interval)
+	}
+	step := int64(float64(rate) * interval.Seconds())
+	if step < pageSize {
+		step = pageSize
+	}
+
+	// All chunks are kept referenced so that the garbage collector cannot
+	// release them.
+	var chunks [][]byte
+	var allocated int64
+	ticker := time.NewTicker(*interval)
+	defer ticker.Stop()
+	for limit == 0 || allocated < limit {
+		size := step
+		if limit > 0 && allocated+size > limit {
+			size = limit - allocated
+		}
+		chunks = append(chunks, touch(make([]byte, size)))
+		allocated += size
+		fixture.WriteJSON(progress{Allocated: allocated, Done: limit > 0 && allocated >= limit})
+		<-ticker.C
+	}
+
+	fixture.WaitForSignal()
+	runtime.KeepAlive(chunks)
+}
+
+// touch writes to every page of b so that the kernel has to back it with
+// actual memory.
+func touch(b []byte) []byte {
+	for i := 0; i < len(b); i += pageSize {
+		b[i] = 1
+	}
+	return b
+}
@@ -0,0 +1,174 @@
+// Copyright (c) Ansible Project
+// GNU General Public License v3.0+ (see COPYING or https://www.gnu.org/licenses/gpl-3.0.txt)
+
+// Command mounts-reporter prints the mount table of its own mount namespace,
+// as found in /proc/self/mounts, as a single line of JSON on stdout:
+//
+//	{"mounts":[{"source":"/dev/sda1","target":"/data","fstype":"ext4","options":["rw","relatime"],"propagation":"private"},...]}
+//
+// The propagation of every mount (private, shared, slave or unbindable) is
+// taken from /proc/self/mountinfo, since /proc/self/mounts does not contain
+// it.
+//
+// Usage:
+//
+//	mounts-reporter [-keep-running] [-listen ADDRESS]
+//
+// With -keep-running, the program stays alive after printing until it
+// receives SIGTERM or SIGINT. With -listen (for example -listen :8080), it
+// additionally serves the current mount table on every HTTP GET request to
+// ADDRESS until it receives SIGTERM or SIGINT; the table is read anew for
+// every request.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/ansible-collections/community.docker/tests/images/internal/fixture"
+)
+
+type mount struct {
+	Source      string   `json:"source"`
+	Target      string   `json:"target"`
+	FSType      string   `json:"fstype"`
+	Options     []string `json:"options"`
+	Propagation string   `json:"propagation,omitempty"`
+}
+
+type mountTable struct {
+	Mounts []mount `json:"mounts"`
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("mounts-reporter: ")
+
+	keepRunning := flag.Bool("keep-running", false, "keep running after printing until SIGTERM or SIGINT")
+	listen := flag.String("listen", "", "serve the mount table over HTTP on this address")
+	flag.Parse()
+
+	table, err := readMountTable()
+	if err != nil {
+		log.Fatal(err)
+	}
+	fixture.WriteJSON(table)
+
+	if *listen != "" {
+		fixture.Serve(*listen, fixture.ReportHandler(func() (interface{}, error) {
+			return readMountTable()
+		}))
+		fixture.WaitForSignal()
+	} else if *keepRunning {
+		fixture.WaitForSignal()
+	}
+}
+
+func readMountTable() (*mountTable, error) {
+	mounts, err := readMounts("/proc/self/mounts")
+	if err != nil {
+		return nil, err
+	}
+	// Both files list the same mounts in the same order, so the propagation
+	// can be matched by position. If the mount table changed between reading
+	// both files, the propagation is omitted.
+	propagations, err := readPropagations("/proc/self/mountinfo")
+	if err == nil && len(propagations) == len(mounts) {
+		for i := range mounts {
+			mounts[i].Propagation = propagations[i]
+		}
+	}
+	return &mountTable{Mounts: mounts}, nil
+}
+
+func readMounts(path string) ([]mount, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	mounts := []mount{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 {
+			return nil, fmt.Errorf("cannot parse line %q of %s", scanner.Text(), path)
+		}
+		mounts = append(mounts, mount{
+			Source:  unescape(fields[0]),
+			Target:  unescape(fields[1]),
+			FSType:  unescape(fields[2]),
+			Options: strings.Split(fields[3], ","),
+		})
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("cannot read %s: %v", path, err)
+	}
+	return mounts, nil
+}
+
+// readPropagations returns the propagation type of every mount listed in
+// the given mountinfo file, see proc(5).
+func readPropagations(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	propagations := []string{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 7 {
+			return nil, fmt.Errorf("cannot parse line %q of %s", scanner.Text(), path)
+		}
+		propagation := "private"
+		// The optional fields start with the seventh field and are
+		// terminated by a single hyphen.
+		for _, field := range fields[6:] {
+			if field == "-" {
+				break
+			}
+			switch {
+			case strings.HasPrefix(field, "master:"):
+				propagation = "slave"
+			case strings.HasPrefix(field, "shared:") && propagation != "slave":
+				propagation = "shared"
+			case field == "unbindable":
+				propagation = "unbindable"
+			}
+		}
+		propagations = append(propagations, propagation)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("cannot read %s: %v", path, err)
+	}
+	return propagations, nil
+}
+
+// unescape decodes the octal escapes (like \040 for a space) the kernel uses
+// for whitespace and backslashes in mount table fields.
+func unescape(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+4 <= len(s) {
+			if v, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(v))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
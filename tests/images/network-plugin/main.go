@@ -0,0 +1,318 @@
+// Copyright (c) Ansible Project
+// GNU General Public License v3.0+ (see COPYING or https://www.gnu.org/licenses/gpl-3.0.txt)
+
+// Command network-plugin is a Docker network plugin implementing the remote
+// network driver API of libnetwork. Containers connected to its networks get
+// an interface of a veth pair whose other end stays unconnected in the host's
+// network namespace, so they have addresses but no connectivity.
+//
+// The plugin records the driver options and IPAM data it received for every
+// network and the endpoints created in it. With -listen, it serves these as
+// JSON on GET /networks, keyed by network ID:
+//
+//	{"5e1f...":{"options":{"foo":"bar"},"ipv4_data":[{"Pool":"172.30.0.0/16","Gateway":"172.30.0.1/16"}],"endpoints":{"a2c4...":{"address":"172.30.0.2/16","mac_address":""}}}}
+//
+// Errors can be injected with driver options: a network created with the
+// option fail_create_network=MESSAGE is rejected with MESSAGE. Similarly,
+// fail_create_endpoint=MESSAGE and fail_join=MESSAGE make connecting
+// containers to the network fail, and fail_delete_network=MESSAGE makes
+// removing the network fail.
+//
+// Usage:
+//
+//	network-plugin [-socket PATH] [-listen ADDRESS]
+//
+// The default socket is /run/docker/plugins/network-plugin.sock, which is
+// used when running as managed plugin. config.json in this directory makes
+// the plugin listen on 127.0.0.1:8089 of the host. To install the plugin,
+// create its root filesystem from the image and combine it with config.json:
+//
+//	docker build -t network-plugin-rootfs -f tests/images/network-plugin/Dockerfile tests/images
+//	mkdir -p /tmp/network-plugin/rootfs
+//	docker export "$(docker create network-plugin-rootfs)" | tar -x -C /tmp/network-plugin/rootfs
+//	mkdir -p /tmp/network-plugin/rootfs/run/docker/plugins
+//	cp tests/images/network-plugin/config.json /tmp/network-plugin/
+//	docker plugin create network-plugin /tmp/network-plugin
+//	docker plugin enable network-plugin
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"github.com/ansible-collections/community.docker/tests/images/internal/fixture"
+)
+
+// genericOptionsKey is the key of the user supplied driver options in the
+// options of a network.
+const genericOptionsKey = "com.docker.network.generic"
+
+type ipamData struct {
+	AddressSpace string            `json:"AddressSpace,omitempty"`
+	Pool         string            `json:"Pool,omitempty"`
+	Gateway      string            `json:"Gateway,omitempty"`
+	AuxAddresses map[string]string `json:"AuxAddresses,omitempty"`
+}
+
+type endpoint struct {
+	Address     string `json:"address"`
+	AddressIPv6 string `json:"address_ipv6,omitempty"`
+	MacAddress  string `json:"mac_address"`
+	// Interface is the host side name of the veth pair, once joined.
+	Interface string `json:"interface,omitempty"`
+}
+
+type network struct {
+	Options   map[string]string    `json:"options"`
+	IPv4Data  []ipamData           `json:"ipv4_data"`
+	IPv6Data  []ipamData           `json:"ipv6_data"`
+	Endpoints map[string]*endpoint `json:"endpoints"`
+}
+
+type request struct {
+	NetworkID  string                     `json:"NetworkID"`
+	EndpointID string                     `json:"EndpointID"`
+	Options    map[string]json.RawMessage `json:"Options"`
+	IPv4Data   []ipamData                 `json:"IPv4Data"`
+	IPv6Data   []ipamData                 `json:"IPv6Data"`
+	Interface  *struct {
+		Address     string `json:"Address"`
+		AddressIPv6 string `json:"AddressIPv6"`
+		MacAddress  string `json:"MacAddress"`
+	} `json:"Interface"`
+}
+
+type driver struct {
+	mu       sync.Mutex
+	networks map[string]*network
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("network-plugin: ")
+
+	socket := flag.String("socket", "/run/docker/plugins/network-plugin.sock", "path of the plugin socket")
+	listen := flag.String("listen", "", "serve the recorded networks over HTTP on this address")
+	flag.Parse()
+
+	d := &driver{networks: make(map[string]*network)}
+
+	mux := fixture.NewPluginMux("NetworkDriver")
+	mux.HandleFunc("/NetworkDriver.GetCapabilities", func(w http.ResponseWriter, r *http.Request) {
+		fixture.ServePluginJSON(w, map[string]interface{}{"Scope": "local", "ConnectivityScope": "local"})
+	})
+	mux.HandleFunc("/NetworkDriver.CreateNetwork", d.handle(d.createNetwork))
+	mux.HandleFunc("/NetworkDriver.DeleteNetwork", d.handle(d.deleteNetwork))
+	mux.HandleFunc("/NetworkDriver.CreateEndpoint", d.handle(d.createEndpoint))
+	mux.HandleFunc("/NetworkDriver.DeleteEndpoint", d.handle(d.deleteEndpoint))
+	mux.HandleFunc("/NetworkDriver.EndpointOperInfo", d.handle(d.endpointOperInfo))
+	mux.HandleFunc("/NetworkDriver.Join", d.handle(d.join))
+	mux.HandleFunc("/NetworkDriver.Leave", d.handle(d.leave))
+	// These notifications require no action from a local driver.
+	for _, name := range []string{"DiscoverNew", "DiscoverDelete", "ProgramExternalConnectivity", "RevokeExternalConnectivity"} {
+		mux.HandleFunc("/NetworkDriver."+name, d.handle(nil))
+	}
+
+	if *listen != "" {
+		status := http.NewServeMux()
+		status.HandleFunc("/networks", d.serveNetworks)
+		fixture.Serve(*listen, status)
+	}
+
+	fixture.ServePlugin(*socket, mux)
+}
+
+// handle decodes the request for a network driver endpoint, and encodes the
+// response. Errors are reported in the Err field, as the protocol requires.
+func (d *driver) handle(f func(req *request) (map[string]interface{}, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req request
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				fixture.ServePluginJSON(w, map[string]string{"Err": fmt.Sprintf("invalid request: %v", err)})
+				return
+			}
+		}
+		var response map[string]interface{}
+		var err error
+		if f != nil {
+			d.mu.Lock()
+			response, err = f(&req)
+			d.mu.Unlock()
+		}
+		if response == nil {
+			response = make(map[string]interface{})
+		}
+		result := "ok"
+		if err != nil {
+			response = map[string]interface{}{"Err": err.Error()}
+			result = err.Error()
+		}
+		log.Printf("%s %s %s: %s", r.URL.Path, req.NetworkID, req.EndpointID, result)
+		fixture.ServePluginJSON(w, response)
+	}
+}
+
+func (d *driver) serveNetworks(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	fixture.ServeJSON(w, d.networks)
+}
+
+func (d *driver) lookup(networkID string) (*network, error) {
+	n := d.networks[networkID]
+	if n == nil {
+		return nil, fmt.Errorf("network %s does not exist", networkID)
+	}
+	return n, nil
+}
+
+// injectedFailure returns the error requested with a fail_* driver option.
+func (n *network) injectedFailure(operation string) error {
+	if message, ok := n.Options["fail_"+operation]; ok {
+		return fmt.Errorf("%s", message)
+	}
+	return nil
+}
+
+func (d *driver) createNetwork(req *request) (map[string]interface{}, error) {
+	n := &network{
+		Options:   make(map[string]string),
+		IPv4Data:  req.IPv4Data,
+		IPv6Data:  req.IPv6Data,
+		Endpoints: make(map[string]*endpoint),
+	}
+	if raw, ok := req.Options[genericOptionsKey]; ok {
+		// Option values are strings, except for some set by Docker itself.
+		var generic map[string]interface{}
+		if err := json.Unmarshal(raw, &generic); err != nil {
+			return nil, fmt.Errorf("invalid driver options: %v", err)
+		}
+		for key, value := range generic {
+			n.Options[key] = fmt.Sprint(value)
+		}
+	}
+	if err := n.injectedFailure("create_network"); err != nil {
+		return nil, err
+	}
+	d.networks[req.NetworkID] = n
+	return nil, nil
+}
+
+func (d *driver) deleteNetwork(req *request) (map[string]interface{}, error) {
+	n, err := d.lookup(req.NetworkID)
+	if err != nil {
+		return nil, err
+	}
+	if err := n.injectedFailure("delete_network"); err != nil {
+		return nil, err
+	}
+	delete(d.networks, req.NetworkID)
+	return nil, nil
+}
+
+func (d *driver) createEndpoint(req *request) (map[string]interface{}, error) {
+	n, err := d.lookup(req.NetworkID)
+	if err != nil {
+		return nil, err
+	}
+	if err := n.injectedFailure("create_endpoint"); err != nil {
+		return nil, err
+	}
+	ep := &endpoint{}
+	if req.Interface != nil {
+		ep.Address = req.Interface.Address
+		ep.AddressIPv6 = req.Interface.AddressIPv6
+		ep.MacAddress = req.Interface.MacAddress
+	}
+	n.Endpoints[req.EndpointID] = ep
+	// An empty interface tells Docker to keep the addresses it allocated.
+	return map[string]interface{}{"Interface": map[string]string{}}, nil
+}
+
+func (d *driver) deleteEndpoint(req *request) (map[string]interface{}, error) {
+	n, err := d.lookup(req.NetworkID)
+	if err != nil {
+		return nil, err
+	}
+	delete(n.Endpoints, req.EndpointID)
+	return nil, nil
+}
+
+func (d *driver) endpointOperInfo(req *request) (map[string]interface{}, error) {
+	n, err := d.lookup(req.NetworkID)
+	if err != nil {
+		return nil, err
+	}
+	ep := n.Endpoints[req.EndpointID]
+	if ep == nil {
+		return nil, fmt.Errorf("endpoint %s does not exist", req.EndpointID)
+	}
+	return map[string]interface{}{"Value": map[string]string{"interface": ep.Interface}}, nil
+}
+
+// vethNames returns the names of both ends of the veth pair of an endpoint.
+// Interface names are limited to 15 characters.
+func vethNames(endpointID string) (string, string) {
+	id := endpointID
+	if len(id) > 7 {
+		id = id[:7]
+	}
+	return "tnp" + id, "tnc" + id
+}
+
+func (d *driver) join(req *request) (map[string]interface{}, error) {
+	n, err := d.lookup(req.NetworkID)
+	if err != nil {
+		return nil, err
+	}
+	ep := n.Endpoints[req.EndpointID]
+	if ep == nil {
+		return nil, fmt.Errorf("endpoint %s does not exist", req.EndpointID)
+	}
+	if err := n.injectedFailure("join"); err != nil {
+		return nil, err
+	}
+	hostName, containerName := vethNames(req.EndpointID)
+	if err := ip("link", "add", hostName, "type", "veth", "peer", "name", containerName); err != nil {
+		return nil, err
+	}
+	if err := ip("link", "set", hostName, "up"); err != nil {
+		return nil, err
+	}
+	ep.Interface = hostName
+	return map[string]interface{}{
+		"InterfaceName": map[string]string{"SrcName": containerName, "DstPrefix": "eth"},
+	}, nil
+}
+
+func (d *driver) leave(req *request) (map[string]interface{}, error) {
+	n, err := d.lookup(req.NetworkID)
+	if err != nil {
+		return nil, err
+	}
+	if ep := n.Endpoints[req.EndpointID]; ep != nil {
+		ep.Interface = ""
+	}
+	// Deleting one end of a veth pair removes the other one as well.
+	hostName, _ := vethNames(req.EndpointID)
+	return nil, ip("link", "del", hostName)
+}
+
+func ip(args ...string) error {
+	output, err := exec.Command("ip", args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("ip %s: %v: %s", strings.Join(args, " "), err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
@@ -0,0 +1,104 @@
+// Copyright (c) Ansible Project
+// GNU General Public License v3.0+ (see COPYING or https://www.gnu.org/licenses/gpl-3.0.txt)
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+type hostsEntry struct {
+	Address string   `json:"address"`
+	Names   []string `json:"names"`
+}
+
+type resolvConf struct {
+	Nameservers []string `json:"nameservers"`
+	Search      []string `json:"search"`
+	Options     []string `json:"options"`
+}
+
+type resolverConfig struct {
+	Hosts      []hostsEntry `json:"hosts"`
+	ResolvConf resolvConf   `json:"resolv_conf"`
+}
+
+func readResolverConfig() (interface{}, error) {
+	hosts, err := readHosts("/etc/hosts")
+	if err != nil {
+		return nil, err
+	}
+	conf, err := readResolvConf("/etc/resolv.conf")
+	if err != nil {
+		return nil, err
+	}
+	return &resolverConfig{Hosts: hosts, ResolvConf: *conf}, nil
+}
+
+// readLines returns the lines of a file with comments (starting with any of
+// commentChars) and surrounding whitespace removed, skipping empty lines.
+func readLines(path, commentChars string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	lines := []string{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.IndexAny(line, commentChars); i >= 0 {
+			line = line[:i]
+		}
+		line = strings.TrimSpace(line)
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("cannot read %s: %v", path, err)
+	}
+	return lines, nil
+}
+
+func readHosts(path string) ([]hostsEntry, error) {
+	lines, err := readLines(path, "#")
+	if err != nil {
+		return nil, err
+	}
+	entries := []hostsEntry{}
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("cannot parse line %q of %s", line, path)
+		}
+		entries = append(entries, hostsEntry{Address: fields[0], Names: fields[1:]})
+	}
+	return entries, nil
+}
+
+// readResolvConf parses the keywords of resolv.conf(5) that Docker manages.
+// As with the resolver, a later search or domain line replaces earlier ones.
+func readResolvConf(path string) (*resolvConf, error) {
+	lines, err := readLines(path, "#;")
+	if err != nil {
+		return nil, err
+	}
+	conf := &resolvConf{Nameservers: []string{}, Search: []string{}, Options: []string{}}
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		switch fields[0] {
+		case "nameserver":
+			conf.Nameservers = append(conf.Nameservers, fields[1:]...)
+		case "search", "domain":
+			conf.Search = fields[1:]
+		case "options":
+			conf.Options = append(conf.Options, fields[1:]...)
+		}
+	}
+	return conf, nil
+}
@@ -0,0 +1,75 @@
+// Copyright (c) Ansible Project
+// GNU General Public License v3.0+ (see COPYING or https://www.gnu.org/licenses/gpl-3.0.txt)
+
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"strings"
+	"time"
+
+	// The image contains no time zone database, but TZ can name any zone.
+	_ "time/tzdata"
+)
+
+type timezone struct {
+	Name         *string `json:"name"`
+	Abbreviation string  `json:"abbreviation"`
+	Offset       int     `json:"offset"`
+}
+
+type hostnameReport struct {
+	Hostname    string   `json:"hostname"`
+	Domainname  string   `json:"domainname"`
+	EtcHostname *string  `json:"etc_hostname"`
+	Timezone    timezone `json:"timezone"`
+}
+
+func readHostname() (interface{}, error) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return nil, err
+	}
+	report := &hostnameReport{Hostname: hostname}
+	// The kernel reports an unset domain name as "(none)".
+	content, err := os.ReadFile("/proc/sys/kernel/domainname")
+	if err != nil {
+		return nil, err
+	}
+	if domainname := strings.TrimSpace(string(content)); domainname != "(none)" {
+		report.Domainname = domainname
+	}
+	content, err = os.ReadFile("/etc/hostname")
+	if err == nil {
+		value := strings.TrimSpace(string(content))
+		report.EtcHostname = &value
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	report.Timezone.Abbreviation, report.Timezone.Offset = time.Now().Zone()
+	report.Timezone.Name = timezoneName()
+	return report, nil
+}
+
+// timezoneName determines the name of the local time zone from the TZ
+// environment variable, /etc/timezone, or the target of the /etc/localtime
+// symlink, in this order. It returns nil if none of them names a zone.
+func timezoneName() *string {
+	if tz, ok := os.LookupEnv("TZ"); ok && tz != "" {
+		tz = strings.TrimPrefix(tz, ":")
+		return &tz
+	}
+	if content, err := os.ReadFile("/etc/timezone"); err == nil {
+		if tz := strings.TrimSpace(string(content)); tz != "" {
+			return &tz
+		}
+	}
+	if target, err := os.Readlink("/etc/localtime"); err == nil {
+		if _, tz, ok := strings.Cut(target, "zoneinfo/"); ok {
+			return &tz
+		}
+	}
+	return nil
+}
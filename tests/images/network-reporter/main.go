@@ -0,0 +1,125 @@
+// Copyright (c) Ansible Project
+// GNU General Public License v3.0+ (see COPYING or https://www.gnu.org/licenses/gpl-3.0.txt)
+
+// Command network-reporter prints the network interfaces of its network
+// namespace as a single line of JSON on stdout:
+//
+//	{"interfaces":[{"name":"eth0","index":12,"mtu":1500,"mac_address":"02:42:ac:11:00:02","flags":["up","broadcast","multicast"],"addresses":["172.17.0.2/16"]},...]}
+//
+// With -dns, it instead reports the name resolution configuration the
+// container sees, parsed from /etc/hosts and /etc/resolv.conf:
+//
+//	{"hosts":[{"address":"127.0.0.1","names":["localhost"]},...],"resolv_conf":{"nameservers":["127.0.0.11"],"search":["example.com"],"options":["ndots:0"]}}
+//
+// With -hostname, it instead reports the host and domain name of its UTS
+// namespace, the content of /etc/hostname (null if it does not exist), and
+// the local time zone:
+//
+//	{"hostname":"web","domainname":"example.com","etc_hostname":"web","timezone":{"name":"Europe/Berlin","abbreviation":"CEST","offset":7200}}
+//
+// The name of the time zone is taken from the TZ environment variable,
+// /etc/timezone, or the target of the /etc/localtime symlink, and is null if
+// none of them names a zone. Abbreviation and offset (in seconds east of
+// UTC) are those currently in effect, so they show whether a bind-mounted
+// /etc/localtime is used.
+//
+// Usage:
+//
+//	network-reporter [-dns | -hostname] [-keep-running] [-listen ADDRESS]
+//
+// With -keep-running, the program stays alive after printing until it
+// receives SIGTERM or SIGINT. With -listen (for example -listen :8080), it
+// additionally serves the current report on every HTTP GET request to
+// ADDRESS until it receives SIGTERM or SIGINT, which allows to observe
+// networks connected to or disconnected from the running container.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"strings"
+
+	"github.com/ansible-collections/community.docker/tests/images/internal/fixture"
+)
+
+type networkInterface struct {
+	Name       string   `json:"name"`
+	Index      int      `json:"index"`
+	MTU        int      `json:"mtu"`
+	MACAddress string   `json:"mac_address"`
+	Flags      []string `json:"flags"`
+	Addresses  []string `json:"addresses"`
+}
+
+type interfaceList struct {
+	Interfaces []networkInterface `json:"interfaces"`
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("network-reporter: ")
+
+	dns := flag.Bool("dns", false, "report /etc/hosts and /etc/resolv.conf instead of the interfaces")
+	hostname := flag.Bool("hostname", false, "report host name, domain name and time zone instead of the interfaces")
+	keepRunning := flag.Bool("keep-running", false, "keep running after printing until SIGTERM or SIGINT")
+	listen := flag.String("listen", "", "serve the report over HTTP on this address")
+	flag.Parse()
+
+	switch {
+	case *dns && *hostname:
+		log.Fatal("-dns and -hostname cannot be used together")
+	case *dns:
+		readReport = readResolverConfig
+	case *hostname:
+		readReport = readHostname
+	}
+
+	report, err := readReport()
+	if err != nil {
+		log.Fatal(err)
+	}
+	fixture.WriteJSON(report)
+
+	if *listen != "" {
+		fixture.Serve(*listen, fixture.ReportHandler(readReport))
+		fixture.WaitForSignal()
+	} else if *keepRunning {
+		fixture.WaitForSignal()
+	}
+}
+
+// readReport collects the information to report, depending on the mode.
+var readReport = readInterfaces
+
+func readInterfaces() (interface{}, error) {
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, fmt.Errorf("cannot list interfaces: %v", err)
+	}
+	list := &interfaceList{Interfaces: []networkInterface{}}
+	for _, iface := range ifaces {
+		addrs, err := iface.Addrs()
+		if err != nil {
+			return nil, fmt.Errorf("cannot list addresses of %s: %v", iface.Name, err)
+		}
+		addresses := []string{}
+		for _, addr := range addrs {
+			addresses = append(addresses, addr.String())
+		}
+		flags := []string{}
+		if iface.Flags != 0 {
+			flags = strings.Split(iface.Flags.String(), "|")
+		}
+		list.Interfaces = append(list.Interfaces, networkInterface{
+			Name:       iface.Name,
+			Index:      iface.Index,
+			MTU:        iface.MTU,
+			MACAddress: iface.HardwareAddr.String(),
+			Flags:      flags,
+			Addresses:  addresses,
+		})
+	}
+	return list, nil
+}
@@ -0,0 +1,104 @@
+// Copyright (c) Ansible Project
+// GNU General Public License v3.0+ (see COPYING or https://www.gnu.org/licenses/gpl-3.0.txt)
+
+// Command pid-spawner starts a number of sleeping child processes and reports
+// how many of them could be started as a single line of JSON on stdout:
+//
+//	{"requested":50,"started":17,"error":"fork/exec /proc/self/exe: resource temporarily unavailable","pids_current":64}
+//
+// Spawning stops at the first failure, which usually is the container's
+// pids limit being reached. The children are copies of this program, which
+// use a few threads each; since every thread counts against the pids limit,
+// the number of children that can be started is lower than the limit.
+// pids_current is the number of tasks the pids cgroup controller accounts
+// for after spawning, if it can be read from /sys/fs/cgroup.
+//
+// Usage:
+//
+//	pid-spawner [-count N] [-keep-running]
+//
+// The default count is 10. With -keep-running, the program and its children
+// stay alive after printing until SIGTERM or SIGINT is received; otherwise
+// the children are killed right away.
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/ansible-collections/community.docker/tests/images/internal/fixture"
+)
+
+type result struct {
+	Requested   int    `json:"requested"`
+	Started     int    `json:"started"`
+	Error       string `json:"error,omitempty"`
+	PidsCurrent *int   `json:"pids_current,omitempty"`
+}
+
+// pidsCurrentFiles are the locations of the pids controller's current
+// counter for cgroup v2 and cgroup v1.
+var pidsCurrentFiles = []string{
+	"/sys/fs/cgroup/pids.current",
+	"/sys/fs/cgroup/pids/pids.current",
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("pid-spawner: ")
+
+	child := flag.Bool("child", false, "internal: run as a sleeping child process")
+	count := flag.Int("count", 10, "number of child processes to start")
+	keepRunning := flag.Bool("keep-running", false, "keep running after printing until SIGTERM or SIGINT")
+	flag.Parse()
+
+	if *child {
+		fixture.WaitForSignal()
+		return
+	}
+	if *count < 0 {
+		log.Fatalf("invalid count %d", *count)
+	}
+
+	res := result{Requested: *count}
+	var children []*exec.Cmd
+	for i := 0; i < *count; i++ {
+		cmd := exec.Command("/proc/self/exe", "-child")
+		cmd.Env = append(os.Environ(), "GOMAXPROCS=1")
+		if err := cmd.Start(); err != nil {
+			res.Error = err.Error()
+			break
+		}
+		children = append(children, cmd)
+	}
+	res.Started = len(children)
+	res.PidsCurrent = readPidsCurrent()
+	fixture.WriteJSON(res)
+
+	if *keepRunning {
+		fixture.WaitForSignal()
+	}
+	for _, cmd := range children {
+		cmd.Process.Kill()
+		cmd.Wait()
+	}
+}
+
+func readPidsCurrent() *int {
+	for _, path := range pidsCurrentFiles {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		value, err := strconv.Atoi(strings.TrimSpace(string(content)))
+		if err != nil {
+			continue
+		}
+		return &value
+	}
+	return nil
+}
@@ -0,0 +1,139 @@
+// Copyright (c) Ansible Project
+// GNU General Public License v3.0+ (see COPYING or https://www.gnu.org/licenses/gpl-3.0.txt)
+
+// Command port-listener listens on lists or ranges of TCP and UDP ports. It
+// answers every TCP connection and every UDP datagram with a single line of
+// JSON naming the port, so that clients can tell which port a published port
+// leads to:
+//
+//	{"protocol":"tcp","port":8003}
+//
+// TCP connections are closed after the answer. The number of connections and
+// datagrams received per port is served as JSON on every HTTP GET request to
+// the -listen address:
+//
+//	{"tcp":{"8000":2,"8001":0},"udp":{"9000":1}}
+//
+// Usage:
+//
+//	port-listener [-tcp PORTS] [-udp PORTS] [-listen ADDRESS]
+//
+// PORTS is a comma-separated list of ports and ranges, for example
+// 8000-8010,8080. The default is -listen :8080, which must not be one of
+// the TCP ports. The program runs until it receives SIGTERM or SIGINT.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+
+	"github.com/ansible-collections/community.docker/tests/images/internal/fixture"
+)
+
+// maxPorts limits the number of ports per protocol.
+const maxPorts = 1024
+
+type counters struct {
+	mu  sync.Mutex
+	TCP map[string]int `json:"tcp"`
+	UDP map[string]int `json:"udp"`
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("port-listener: ")
+
+	tcpPorts := flag.String("tcp", "", "TCP ports to listen on")
+	udpPorts := flag.String("udp", "", "UDP ports to listen on")
+	listen := flag.String("listen", ":8080", "address to serve the counters on")
+	flag.Parse()
+
+	tcp, err := fixture.ParsePorts(*tcpPorts, maxPorts)
+	if err != nil {
+		log.Fatalf("invalid -tcp: %v", err)
+	}
+	udp, err := fixture.ParsePorts(*udpPorts, maxPorts)
+	if err != nil {
+		log.Fatalf("invalid -udp: %v", err)
+	}
+	if len(tcp) == 0 && len(udp) == 0 {
+		log.Fatal("at least one of -tcp and -udp must be given")
+	}
+
+	c := &counters{TCP: make(map[string]int), UDP: make(map[string]int)}
+	for _, port := range tcp {
+		listener, err := net.Listen("tcp", fmt.Sprintf(":%d", port))
+		if err != nil {
+			log.Fatal(err)
+		}
+		c.TCP[strconv.Itoa(port)] = 0
+		go c.serveTCP(listener, port)
+	}
+	for _, port := range udp {
+		conn, err := net.ListenPacket("udp", fmt.Sprintf(":%d", port))
+		if err != nil {
+			log.Fatal(err)
+		}
+		c.UDP[strconv.Itoa(port)] = 0
+		go c.serveUDP(conn, port)
+	}
+
+	http.Handle("/", c)
+	fixture.Serve(*listen, nil)
+	fixture.WaitForSignal()
+}
+
+func answer(protocol string, port int) []byte {
+	content, _ := json.Marshal(map[string]interface{}{"protocol": protocol, "port": port})
+	return append(content, '\n')
+}
+
+func (c *counters) count(m map[string]int, port int) {
+	c.mu.Lock()
+	m[strconv.Itoa(port)]++
+	c.mu.Unlock()
+}
+
+func (c *counters) serveTCP(listener net.Listener, port int) {
+	content := answer("tcp", port)
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			log.Fatal(err)
+		}
+		c.count(c.TCP, port)
+		go func() {
+			conn.Write(content)
+			conn.Close()
+		}()
+	}
+}
+
+func (c *counters) serveUDP(conn net.PacketConn, port int) {
+	content := answer("udp", port)
+	buf := make([]byte, 65536)
+	for {
+		_, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			log.Fatal(err)
+		}
+		c.count(c.UDP, port)
+		conn.WriteTo(content, addr)
+	}
+}
+
+func (c *counters) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	fixture.ServeJSON(w, c)
+}
@@ -0,0 +1,131 @@
+// Copyright (c) Ansible Project
+// GNU General Public License v3.0+ (see COPYING or https://www.gnu.org/licenses/gpl-3.0.txt)
+
+// Command privilege-reporter tries the operations which Docker only allows
+// in privileged containers and reports the results as a single line of JSON
+// on stdout:
+//
+//	{"privileged":false,"sys_writable":{"ok":false,"error":"read-only file system"},"devices":9,"mknod":{"ok":true},"mount_tmpfs":{"ok":false,"error":"operation not permitted"}}
+//
+// sys_writable tells whether /sys can be written to, which is the case if
+// sysfs is mounted read-write. devices is the number of device nodes below
+// /dev; privileged containers see all devices of the host. mknod creates a
+// character device node for /dev/null in -dir and removes it again, which
+// requires CAP_MKNOD. mount_tmpfs mounts a tmpfs on a new directory in -dir
+// and unmounts it again, which requires CAP_SYS_ADMIN and is denied by the
+// default AppArmor profile and seccomp filter. privileged is true if /sys is
+// writable and the tmpfs could be mounted.
+//
+// This verifies privileged, cap_add and cap_drop functionally, instead of
+// relying on the container's configuration.
+//
+// Usage:
+//
+//	privilege-reporter [-dir DIRECTORY] [-keep-running]
+//
+// The default directory is /. It must be writable, so a tmpfs or volume
+// should be given for containers with a read-only root file system. With
+// -keep-running, the program stays alive after printing until it receives
+// SIGTERM or SIGINT.
+package main
+
+import (
+	"errors"
+	"flag"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"github.com/ansible-collections/community.docker/tests/images/internal/fixture"
+)
+
+// check is the result of trying an operation.
+type check struct {
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+}
+
+type report struct {
+	Privileged  bool  `json:"privileged"`
+	SysWritable check `json:"sys_writable"`
+	Devices     int   `json:"devices"`
+	Mknod       check `json:"mknod"`
+	MountTmpfs  check `json:"mount_tmpfs"`
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("privilege-reporter: ")
+
+	dir := flag.String("dir", "/", "writable directory to create the device node and mount point in")
+	keepRunning := flag.Bool("keep-running", false, "keep running after printing until SIGTERM or SIGINT")
+	flag.Parse()
+
+	devices, err := countDevices("/dev")
+	if err != nil {
+		log.Fatal(err)
+	}
+	r := report{
+		SysWritable: newCheck(syscall.Access("/sys", 2)),
+		Devices:     devices,
+		Mknod:       newCheck(tryMknod(*dir)),
+		MountTmpfs:  newCheck(tryMountTmpfs(*dir)),
+	}
+	r.Privileged = r.SysWritable.OK && r.MountTmpfs.OK
+	fixture.WriteJSON(r)
+
+	if *keepRunning {
+		fixture.WaitForSignal()
+	}
+}
+
+func newCheck(err error) check {
+	if err != nil {
+		return check{Error: err.Error()}
+	}
+	return check{OK: true}
+}
+
+// countDevices returns the number of character and block devices below dir.
+func countDevices(dir string) (int, error) {
+	count := 0
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// Unreadable subdirectories are skipped.
+			if path != dir && errors.Is(err, fs.ErrPermission) {
+				return nil
+			}
+			return err
+		}
+		if d.Type()&fs.ModeDevice != 0 {
+			count++
+		}
+		return nil
+	})
+	return count, err
+}
+
+// tryMknod creates a device node for /dev/null in dir and removes it.
+func tryMknod(dir string) error {
+	path := filepath.Join(dir, ".privilege-reporter-null")
+	if err := syscall.Mknod(path, syscall.S_IFCHR|0o600, 1<<8|3); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+// tryMountTmpfs mounts a tmpfs on a new directory in dir, and unmounts and
+// removes it again.
+func tryMountTmpfs(dir string) error {
+	mountPoint, err := os.MkdirTemp(dir, ".privilege-reporter-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(mountPoint)
+	if err := syscall.Mount("tmpfs", mountPoint, "tmpfs", 0, "size=1m"); err != nil {
+		return err
+	}
+	return syscall.Unmount(mountPoint, 0)
+}
@@ -0,0 +1,107 @@
+// Copyright (c) Ansible Project
+// GNU General Public License v3.0+ (see COPYING or https://www.gnu.org/licenses/gpl-3.0.txt)
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/ansible-collections/community.docker/tests/images/internal/fixture"
+)
+
+type fault struct {
+	fixture.RequestRule
+	Status int    `json:"status,omitempty"`
+	Delay  string `json:"delay,omitempty"`
+
+	delay time.Duration
+}
+
+// faultList is the list of faults to inject.
+type faultList struct {
+	mu     sync.Mutex
+	faults []*fault
+}
+
+func (f *fault) compile() error {
+	if err := f.CompilePath(); err != nil {
+		return err
+	}
+	if f.Delay != "" {
+		var err error
+		if f.delay, err = time.ParseDuration(f.Delay); err != nil {
+			return fmt.Errorf("invalid delay %q: %v", f.Delay, err)
+		}
+	}
+	if f.Status != 0 && (f.Status < 100 || f.Status > 599) {
+		return fmt.Errorf("invalid status %d", f.Status)
+	}
+	return nil
+}
+
+func (l *faultList) load(path string) error {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	return l.set(content)
+}
+
+func (l *faultList) set(content []byte) error {
+	var faults []*fault
+	if err := json.Unmarshal(content, &faults); err != nil {
+		return fmt.Errorf("cannot parse faults: %v", err)
+	}
+	for _, f := range faults {
+		if err := f.compile(); err != nil {
+			return err
+		}
+	}
+	l.mu.Lock()
+	l.faults = faults
+	l.mu.Unlock()
+	return nil
+}
+
+// match returns the first fault matching the request, and counts it as used.
+func (l *faultList) match(r *http.Request) *fault {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return fixture.Match(&l.faults, r.Method, r.URL.Path, nil)
+}
+
+// inject wraps a handler so that matching faults are applied before it.
+func (l *faultList) inject(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if f := l.match(r); f != nil {
+			time.Sleep(f.delay)
+			if f.Status != 0 {
+				writeError(w, f.Status, "UNKNOWN", "injected fault", nil)
+				return
+			}
+		}
+		handler.ServeHTTP(w, r)
+	})
+}
+
+// list returns a snapshot of the faults for the control endpoint.
+func (l *faultList) list(*http.Request) interface{} {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	faults := make([]fault, len(l.faults))
+	for i, f := range l.faults {
+		faults[i] = *f
+	}
+	return faults
+}
+
+func (l *faultList) clear() {
+	l.mu.Lock()
+	l.faults = nil
+	l.mu.Unlock()
+}
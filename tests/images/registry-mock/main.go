@@ -0,0 +1,115 @@
+// Copyright (c) Ansible Project
+// GNU General Public License v3.0+ (see COPYING or https://www.gnu.org/licenses/gpl-3.0.txt)
+
+// Command registry-mock implements the parts of the Docker Registry HTTP API
+// V2 needed to push and pull images: the version check, blob uploads (chunked,
+// monolithic and cross-repository mounts), blob and manifest retrieval,
+// manifest uploads, and tag and repository listing (/v2/_catalog), both with
+// pagination through the n and last query parameters and a Link header.
+//
+// With -delete, manifests (by digest, which also removes all tags pointing to
+// them), tags and blobs can be deleted with DELETE requests. Blobs are only
+// unlinked from the repository. Without it, these requests are answered with
+// 405 UNSUPPORTED, as by the real registry with deletion disabled.
+//
+// Blobs are stored below a storage directory (a new temporary directory by
+// default), everything else is kept in memory.
+//
+// With -username and -password, all API requests require HTTP basic
+// authentication with these credentials.
+//
+// With -token, the registry uses the token authentication flow instead: API
+// requests require a bearer token, which clients obtain from the /token
+// endpoint, and are answered with a WWW-Authenticate challenge naming the
+// required scope otherwise. Pulling requires the pull action on the
+// repository, deleting the delete action, and everything else the push
+// action. Cross-repository mounts additionally require the pull action on the
+// source repository, and start a regular upload without it. The token
+// endpoint grants all requested repository scopes, but requires basic
+// authentication if -username and -password are given. Tokens expire after
+// -token-ttl (5m by default). The realm advertised in challenges is derived
+// from the request's Host header unless -token-realm is given.
+//
+// Failures can be injected to test error handling. A fault matches requests by
+// method and by a regular expression for the path, and makes the registry
+// wait for a delay and then either continue normally (status 0) or answer with
+// the given status. With count, a fault only applies to the next count
+// matching requests. Faults are read from a JSON file given with -faults, and
+// can be listed, replaced and removed at runtime with GET, PUT and DELETE
+// requests to /_mock/faults, which never requires authentication:
+//
+//	[{"method":"PUT","path":"/manifests/","status":500,"count":1},{"method":"PATCH","path":".*","delay":"5s"}]
+//
+// Usage:
+//
+//	registry-mock [-listen ADDRESS] [-storage DIRECTORY] [-username USER -password PASSWORD]
+//	              [-token [-token-ttl DURATION] [-token-realm URL]] [-faults FILE] [-delete]
+//
+// The default address is :5000. The program runs until it receives SIGTERM or
+// SIGINT.
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/ansible-collections/community.docker/tests/images/internal/fixture"
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("registry-mock: ")
+
+	listen := flag.String("listen", ":5000", "address to listen on")
+	storageDir := flag.String("storage", "", "directory to store blobs in (default: a new temporary directory)")
+	username := flag.String("username", "", "require basic authentication with this user name")
+	password := flag.String("password", "", "require basic authentication with this password")
+	useToken := flag.Bool("token", false, "use token authentication")
+	tokenTTL := flag.Duration("token-ttl", 5*time.Minute, "lifetime of tokens")
+	tokenRealm := flag.String("token-realm", "", "URL of the token endpoint advertised to clients")
+	faultsFile := flag.String("faults", "", "JSON file with faults to inject")
+	deleteEnabled := flag.Bool("delete", false, "allow deleting manifests, tags and blobs")
+	flag.Parse()
+
+	if (*username == "") != (*password == "") {
+		log.Fatal("-username and -password must be used together")
+	}
+
+	if *storageDir == "" {
+		dir, err := os.MkdirTemp("", "registry-mock")
+		if err != nil {
+			log.Fatalf("cannot create storage directory: %v", err)
+		}
+		defer os.RemoveAll(dir)
+		*storageDir = dir
+	}
+	store, err := newStorage(*storageDir)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	faults := &faultList{}
+	if *faultsFile != "" {
+		if err := faults.load(*faultsFile); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	reg := &registry{storage: store, deleteEnabled: *deleteEnabled}
+	mux := http.NewServeMux()
+	mux.Handle("/_mock/faults", fixture.Control{Get: faults.list, Set: faults.set, Clear: faults.clear})
+	mux.Handle("/v2/", faults.inject(reg))
+	if *useToken {
+		reg.tokens = newTokenService(*username, *password, *tokenTTL, *tokenRealm)
+		mux.Handle("/token", faults.inject(reg.tokens))
+	} else {
+		reg.username = *username
+		reg.password = *password
+	}
+
+	fixture.Serve(*listen, fixture.LogRequests(mux))
+	fixture.WaitForSignal()
+}
@@ -0,0 +1,467 @@
+// Copyright (c) Ansible Project
+// GNU General Public License v3.0+ (see COPYING or https://www.gnu.org/licenses/gpl-3.0.txt)
+
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// maxManifestSize is the maximal size of manifests accepted by the registry.
+const maxManifestSize = 4 << 20
+
+type registry struct {
+	storage *storage
+	// tokens is the token service if token authentication is used.
+	tokens *tokenService
+	// username and password are the credentials for basic authentication.
+	username string
+	password string
+	// deleteEnabled allows deleting manifests, tags and blobs.
+	deleteEnabled bool
+}
+
+type apiError struct {
+	Code    string      `json:"code"`
+	Message string      `json:"message"`
+	Detail  interface{} `json:"detail,omitempty"`
+}
+
+func writeError(w http.ResponseWriter, status int, code, message string, detail interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string][]apiError{
+		"errors": {{Code: code, Message: message, Detail: detail}},
+	})
+}
+
+func writeStorageError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, errBlobUnknown):
+		writeError(w, http.StatusNotFound, "BLOB_UNKNOWN", err.Error(), nil)
+	case errors.Is(err, errUploadUnknown):
+		writeError(w, http.StatusNotFound, "BLOB_UPLOAD_UNKNOWN", err.Error(), nil)
+	case errors.Is(err, errDigestInvalid):
+		writeError(w, http.StatusBadRequest, "DIGEST_INVALID", err.Error(), nil)
+	case errors.Is(err, errManifestUnknown):
+		writeError(w, http.StatusNotFound, "MANIFEST_UNKNOWN", err.Error(), nil)
+	case errors.Is(err, errNameUnknown):
+		writeError(w, http.StatusNotFound, "NAME_UNKNOWN", err.Error(), nil)
+	default:
+		writeError(w, http.StatusInternalServerError, "UNKNOWN", err.Error(), nil)
+	}
+}
+
+func (reg *registry) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Docker-Distribution-API-Version", "registry/2.0")
+
+	if r.URL.Path == "/v2/" {
+		if !reg.authorized(w, r, "") {
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprint(w, "{}")
+		return
+	}
+	if r.URL.Path == "/v2/_catalog" {
+		if !reg.authorized(w, r, "") {
+			return
+		}
+		reg.serveCatalog(w, r)
+		return
+	}
+
+	path := strings.TrimPrefix(r.URL.Path, "/v2/")
+	var name string
+	var handler func(http.ResponseWriter, *http.Request, string, string)
+	var argument string
+	if i := strings.LastIndex(path, "/blobs/uploads"); i >= 0 {
+		name, argument = path[:i], strings.TrimPrefix(path[i+len("/blobs/uploads"):], "/")
+		handler = reg.serveUpload
+	} else if i := strings.LastIndex(path, "/blobs/"); i >= 0 {
+		name, argument = path[:i], path[i+len("/blobs/"):]
+		handler = reg.serveBlob
+	} else if i := strings.LastIndex(path, "/manifests/"); i >= 0 {
+		name, argument = path[:i], path[i+len("/manifests/"):]
+		handler = reg.serveManifest
+	} else if strings.HasSuffix(path, "/tags/list") {
+		name = strings.TrimSuffix(path, "/tags/list")
+		handler = reg.serveTags
+	} else {
+		writeError(w, http.StatusNotFound, "UNSUPPORTED", "unknown endpoint", r.URL.Path)
+		return
+	}
+	if !reg.authorized(w, r, name) {
+		return
+	}
+	if !nameRegexp.MatchString(name) {
+		writeError(w, http.StatusBadRequest, "NAME_INVALID", "invalid repository name", name)
+		return
+	}
+	handler(w, r, name, argument)
+}
+
+// authorized checks the credentials of a request for the given repository if
+// authentication is required, and answers unauthorized requests.
+func (reg *registry) authorized(w http.ResponseWriter, r *http.Request, name string) bool {
+	if reg.tokens != nil {
+		action := "push"
+		switch {
+		case r.Method == http.MethodGet || r.Method == http.MethodHead:
+			action = "pull"
+		case r.Method == http.MethodDelete && !strings.Contains(r.URL.Path, "/blobs/uploads/"):
+			// Cancelling an upload is part of pushing, deleting content
+			// requires its own action.
+			action = "delete"
+		}
+		return reg.tokens.authorized(w, r, name, action)
+	}
+	if reg.username == "" {
+		return true
+	}
+	username, password, ok := r.BasicAuth()
+	if ok &&
+		subtle.ConstantTimeCompare([]byte(username), []byte(reg.username)) == 1 &&
+		subtle.ConstantTimeCompare([]byte(password), []byte(reg.password)) == 1 {
+		return true
+	}
+	w.Header().Set("WWW-Authenticate", `Basic realm="registry-mock"`)
+	writeError(w, http.StatusUnauthorized, "UNAUTHORIZED", "authentication required", nil)
+	return false
+}
+
+func (reg *registry) serveUpload(w http.ResponseWriter, r *http.Request, name, id string) {
+	if id == "" {
+		if r.Method != http.MethodPost {
+			writeError(w, http.StatusMethodNotAllowed, "UNSUPPORTED", "method not allowed", nil)
+			return
+		}
+		reg.startUpload(w, r, name)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		size, err := reg.storage.uploadSize(name, id)
+		if err != nil {
+			writeStorageError(w, err)
+			return
+		}
+		writeUploadStatus(w, name, id, size, http.StatusNoContent)
+	case http.MethodPatch:
+		size, err := reg.storage.appendUpload(name, id, r.Body)
+		if err != nil {
+			writeStorageError(w, err)
+			return
+		}
+		writeUploadStatus(w, name, id, size, http.StatusAccepted)
+	case http.MethodPut:
+		digest := r.URL.Query().Get("digest")
+		if !digestRegexp.MatchString(digest) {
+			writeError(w, http.StatusBadRequest, "DIGEST_INVALID", "invalid digest", digest)
+			return
+		}
+		if _, err := reg.storage.appendUpload(name, id, r.Body); err != nil {
+			writeStorageError(w, err)
+			return
+		}
+		if err := reg.storage.finishUpload(name, id, digest); err != nil {
+			writeStorageError(w, err)
+			return
+		}
+		writeBlobCreated(w, name, digest)
+	case http.MethodDelete:
+		if err := reg.storage.cancelUpload(name, id); err != nil {
+			writeStorageError(w, err)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "UNSUPPORTED", "method not allowed", nil)
+	}
+}
+
+// startUpload handles POST requests which start an upload, mount a blob from
+// another repository, or upload a blob in a single request.
+func (reg *registry) startUpload(w http.ResponseWriter, r *http.Request, name string) {
+	query := r.URL.Query()
+	if digest, from := query.Get("mount"), query.Get("from"); digest != "" && from != "" {
+		// With token authentication, the token must also grant pulling from
+		// the source repository.
+		allowed := reg.tokens == nil || reg.tokens.grants(r, from, "pull")
+		if allowed && reg.storage.mountBlob(name, from, digest) {
+			writeBlobCreated(w, name, digest)
+			return
+		}
+		// As the real registry, fall back to a regular upload
+	}
+
+	id, err := reg.storage.startUpload(name)
+	if err != nil {
+		writeStorageError(w, err)
+		return
+	}
+	digest := query.Get("digest")
+	if digest == "" {
+		writeUploadStatus(w, name, id, 0, http.StatusAccepted)
+		return
+	}
+	if !digestRegexp.MatchString(digest) {
+		writeError(w, http.StatusBadRequest, "DIGEST_INVALID", "invalid digest", digest)
+		return
+	}
+	if _, err := reg.storage.appendUpload(name, id, r.Body); err != nil {
+		writeStorageError(w, err)
+		return
+	}
+	if err := reg.storage.finishUpload(name, id, digest); err != nil {
+		writeStorageError(w, err)
+		return
+	}
+	writeBlobCreated(w, name, digest)
+}
+
+func writeUploadStatus(w http.ResponseWriter, name, id string, size int64, status int) {
+	w.Header().Set("Location", fmt.Sprintf("/v2/%s/blobs/uploads/%s", name, id))
+	w.Header().Set("Docker-Upload-UUID", id)
+	// The range is inclusive, and 0-0 is used for empty uploads as well.
+	end := size - 1
+	if end < 0 {
+		end = 0
+	}
+	w.Header().Set("Range", fmt.Sprintf("0-%d", end))
+	w.Header().Set("Content-Length", "0")
+	w.WriteHeader(status)
+}
+
+func writeBlobCreated(w http.ResponseWriter, name, digest string) {
+	w.Header().Set("Location", fmt.Sprintf("/v2/%s/blobs/%s", name, digest))
+	w.Header().Set("Docker-Content-Digest", digest)
+	w.Header().Set("Content-Length", "0")
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (reg *registry) serveBlob(w http.ResponseWriter, r *http.Request, name, digest string) {
+	if !digestRegexp.MatchString(digest) {
+		writeError(w, http.StatusBadRequest, "DIGEST_INVALID", "invalid digest", digest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		path, err := reg.storage.blob(name, digest)
+		if err != nil {
+			writeStorageError(w, err)
+			return
+		}
+		serveFile(w, r, path, "application/octet-stream", digest)
+	case http.MethodDelete:
+		if !reg.deleteAllowed(w) {
+			return
+		}
+		if err := reg.storage.deleteBlob(name, digest); err != nil {
+			writeStorageError(w, err)
+			return
+		}
+		writeDeleted(w, digest)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "UNSUPPORTED", "method not allowed", nil)
+	}
+}
+
+func (reg *registry) serveManifest(w http.ResponseWriter, r *http.Request, name, reference string) {
+	if isDigest(reference) {
+		if !digestRegexp.MatchString(reference) {
+			writeError(w, http.StatusBadRequest, "DIGEST_INVALID", "invalid digest", reference)
+			return
+		}
+	} else if !tagRegexp.MatchString(reference) {
+		writeError(w, http.StatusBadRequest, "TAG_INVALID", "invalid tag", reference)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		m, path, err := reg.storage.manifest(name, reference)
+		if err != nil {
+			writeStorageError(w, err)
+			return
+		}
+		serveFile(w, r, path, m.mediaType, m.digest)
+	case http.MethodPut:
+		content, err := io.ReadAll(io.LimitReader(r.Body, maxManifestSize+1))
+		if err != nil {
+			writeStorageError(w, err)
+			return
+		}
+		if len(content) > maxManifestSize {
+			writeError(w, http.StatusRequestEntityTooLarge, "SIZE_INVALID", "manifest too large", nil)
+			return
+		}
+		mediaType := r.Header.Get("Content-Type")
+		references, err := manifestReferences(content)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "MANIFEST_INVALID", "manifest invalid", err.Error())
+			return
+		}
+		digest, err := reg.storage.putManifest(name, reference, mediaType, content, references)
+		if errors.Is(err, errBlobUnknown) {
+			writeError(w, http.StatusBadRequest, "MANIFEST_BLOB_UNKNOWN", "blob unknown to registry", err.Error())
+			return
+		} else if err != nil {
+			writeStorageError(w, err)
+			return
+		}
+		w.Header().Set("Location", fmt.Sprintf("/v2/%s/manifests/%s", name, digest))
+		w.Header().Set("Docker-Content-Digest", digest)
+		w.Header().Set("Content-Length", "0")
+		w.WriteHeader(http.StatusCreated)
+	case http.MethodDelete:
+		if !reg.deleteAllowed(w) {
+			return
+		}
+		if err := reg.storage.deleteManifest(name, reference); err != nil {
+			writeStorageError(w, err)
+			return
+		}
+		writeDeleted(w, reference)
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "UNSUPPORTED", "method not allowed", nil)
+	}
+}
+
+// deleteAllowed answers DELETE requests for content as the real registry does
+// if deletion is disabled.
+func (reg *registry) deleteAllowed(w http.ResponseWriter) bool {
+	if !reg.deleteEnabled {
+		writeError(w, http.StatusMethodNotAllowed, "UNSUPPORTED", "The operation is unsupported.", nil)
+	}
+	return reg.deleteEnabled
+}
+
+func writeDeleted(w http.ResponseWriter, reference string) {
+	if isDigest(reference) {
+		w.Header().Set("Docker-Content-Digest", reference)
+	}
+	w.Header().Set("Content-Length", "0")
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// manifestReferences returns the digests of the blobs (for image manifests)
+// or manifests (for manifest lists and image indexes) a manifest references.
+func manifestReferences(content []byte) ([]string, error) {
+	type descriptor struct {
+		Digest string `json:"digest"`
+	}
+	var parsed struct {
+		Config    *descriptor  `json:"config"`
+		Layers    []descriptor `json:"layers"`
+		Manifests []descriptor `json:"manifests"`
+	}
+	if err := json.Unmarshal(content, &parsed); err != nil {
+		return nil, err
+	}
+	var references []string
+	if parsed.Config != nil {
+		references = append(references, parsed.Config.Digest)
+	}
+	for _, list := range [][]descriptor{parsed.Layers, parsed.Manifests} {
+		for _, d := range list {
+			references = append(references, d.Digest)
+		}
+	}
+	return references, nil
+}
+
+func (reg *registry) serveTags(w http.ResponseWriter, r *http.Request, name, _ string) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "UNSUPPORTED", "method not allowed", nil)
+		return
+	}
+	tags, err := reg.storage.tags(name)
+	if err != nil {
+		writeStorageError(w, err)
+		return
+	}
+	tags, ok := paginate(w, r, tags)
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"name": name,
+		"tags": tags,
+	})
+}
+
+func (reg *registry) serveCatalog(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "UNSUPPORTED", "method not allowed", nil)
+		return
+	}
+	names, ok := paginate(w, r, reg.storage.catalog())
+	if !ok {
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"repositories": names,
+	})
+}
+
+// paginate applies the n and last query parameters to a sorted list. If more
+// entries follow, a Link header for the next page is set. Invalid parameters
+// are answered with an error.
+func paginate(w http.ResponseWriter, r *http.Request, entries []string) ([]string, bool) {
+	query := r.URL.Query()
+	if last := query.Get("last"); last != "" {
+		entries = entries[sort.SearchStrings(entries, last):]
+		if len(entries) > 0 && entries[0] == last {
+			entries = entries[1:]
+		}
+	}
+	if query.Get("n") == "" {
+		return entries, true
+	}
+	n, err := strconv.Atoi(query.Get("n"))
+	if err != nil || n < 0 {
+		writeError(w, http.StatusBadRequest, "PAGINATION_NUMBER_INVALID", "invalid number of results requested", query.Get("n"))
+		return nil, false
+	}
+	if n < len(entries) {
+		entries = entries[:n]
+		if n > 0 {
+			next := url.Values{"last": {entries[n-1]}, "n": {strconv.Itoa(n)}}
+			w.Header().Set("Link", fmt.Sprintf("<%s?%s>; rel=\"next\"", r.URL.Path, next.Encode()))
+		}
+	}
+	return entries, true
+}
+
+func serveFile(w http.ResponseWriter, r *http.Request, path, contentType, digest string) {
+	f, err := os.Open(path)
+	if err != nil {
+		writeStorageError(w, err)
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		writeStorageError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Docker-Content-Digest", digest)
+	w.Header().Set("Etag", strconv.Quote(digest))
+	http.ServeContent(w, r, "", info.ModTime(), f)
+}
@@ -0,0 +1,99 @@
+// Copyright (c) Ansible Project
+// GNU General Public License v3.0+ (see COPYING or https://www.gnu.org/licenses/gpl-3.0.txt)
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestRegistry(t *testing.T, useToken bool) *registry {
+	store, err := newStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	reg := &registry{storage: store}
+	if useToken {
+		reg.tokens = newTokenService("", "", time.Minute, "")
+	}
+	return reg
+}
+
+// issueToken returns a token for the scopes from the token service.
+func issueToken(t *testing.T, ts *tokenService, scopes ...string) string {
+	query := url.Values{"scope": scopes}
+	w := httptest.NewRecorder()
+	ts.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/token?"+query.Encode(), nil))
+	var resp struct {
+		Token string `json:"token"`
+	}
+	if err := json.NewDecoder(w.Body).Decode(&resp); err != nil || resp.Token == "" {
+		t.Fatalf("cannot get token for %v: %v", scopes, err)
+	}
+	return resp.Token
+}
+
+func request(reg *registry, method, target, token, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(method, target, strings.NewReader(body))
+	if token != "" {
+		r.Header.Set("Authorization", "Bearer "+token)
+	}
+	w := httptest.NewRecorder()
+	reg.ServeHTTP(w, r)
+	return w
+}
+
+func TestMountBlob(t *testing.T) {
+	content := "layer"
+	sum := sha256.Sum256([]byte(content))
+	digest := "sha256:" + hex.EncodeToString(sum[:])
+	mount := "/v2/b/blobs/uploads/?mount=" + digest + "&from=a"
+
+	for _, tc := range []struct {
+		name     string
+		useToken bool
+		scopes   []string
+		status   int
+	}{
+		{"without authentication", false, nil, http.StatusCreated},
+		{"pull on source", true, []string{"repository:b:push,pull", "repository:a:pull"}, http.StatusCreated},
+		{"wildcard on source", true, []string{"repository:b:push,pull", "repository:a:*"}, http.StatusCreated},
+		// A token which can only push to b must not copy blobs out of a;
+		// the registry starts a regular upload instead.
+		{"no access to source", true, []string{"repository:b:push,pull"}, http.StatusAccepted},
+		{"push on source", true, []string{"repository:b:push,pull", "repository:a:push"}, http.StatusAccepted},
+	} {
+		reg := newTestRegistry(t, tc.useToken)
+		var sourceToken, token string
+		if tc.useToken {
+			sourceToken = issueToken(t, reg.tokens, "repository:a:push,pull")
+			token = issueToken(t, reg.tokens, tc.scopes...)
+		}
+		if w := request(reg, http.MethodPost, "/v2/a/blobs/uploads/?digest="+digest, sourceToken, content); w.Code != http.StatusCreated {
+			t.Fatalf("%s: uploading to a: got status %d, expected %d", tc.name, w.Code, http.StatusCreated)
+		}
+
+		w := request(reg, http.MethodPost, mount, token, "")
+		if w.Code != tc.status {
+			t.Errorf("%s: mounting got status %d, expected %d", tc.name, w.Code, tc.status)
+		}
+		if tc.status == http.StatusAccepted && !strings.Contains(w.Header().Get("Location"), "/blobs/uploads/") {
+			t.Errorf("%s: got location %q, expected an upload", tc.name, w.Header().Get("Location"))
+		}
+		expected := http.StatusNotFound
+		if tc.status == http.StatusCreated {
+			expected = http.StatusOK
+		}
+		if w := request(reg, http.MethodHead, "/v2/b/blobs/"+digest, token, ""); w.Code != expected {
+			t.Errorf("%s: blob in b has status %d, expected %d", tc.name, w.Code, expected)
+		}
+	}
+}
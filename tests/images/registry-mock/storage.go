@@ -0,0 +1,340 @@
+// Copyright (c) Ansible Project
+// GNU General Public License v3.0+ (see COPYING or https://www.gnu.org/licenses/gpl-3.0.txt)
+
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+var (
+	digestRegexp = regexp.MustCompile(`^sha256:[a-f0-9]{64}$`)
+	tagRegexp    = regexp.MustCompile(`^[a-zA-Z0-9_][a-zA-Z0-9._-]{0,127}$`)
+	nameRegexp   = regexp.MustCompile(`^[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*(?:/[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*)*$`)
+
+	errBlobUnknown     = errors.New("blob unknown to registry")
+	errUploadUnknown   = errors.New("blob upload unknown to registry")
+	errDigestInvalid   = errors.New("provided digest did not match uploaded content")
+	errManifestUnknown = errors.New("manifest unknown")
+	errNameUnknown     = errors.New("repository name not known to registry")
+)
+
+type manifest struct {
+	mediaType string
+	digest    string
+}
+
+type repository struct {
+	// blobs contains the digests of the blobs linked into the repository.
+	blobs map[string]bool
+	// manifests maps manifest digests to manifests.
+	manifests map[string]*manifest
+	// tags maps tags to manifest digests.
+	tags map[string]string
+}
+
+type upload struct {
+	name string
+	size int64
+}
+
+// storage keeps the content of blobs and manifests as files in a directory,
+// and all metadata in memory. As with the real registry, blobs are stored
+// only once, but are only accessible from repositories they were uploaded to
+// or mounted into.
+type storage struct {
+	dir string
+
+	mu           sync.Mutex
+	repositories map[string]*repository
+	uploads      map[string]*upload
+}
+
+func newStorage(dir string) (*storage, error) {
+	for _, sub := range []string{"blobs", "uploads"} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0o755); err != nil {
+			return nil, err
+		}
+	}
+	return &storage{
+		dir:          dir,
+		repositories: make(map[string]*repository),
+		uploads:      make(map[string]*upload),
+	}, nil
+}
+
+func (s *storage) blobFile(digest string) string {
+	return filepath.Join(s.dir, "blobs", strings.Replace(digest, ":", "-", 1))
+}
+
+func (s *storage) uploadFile(id string) string {
+	return filepath.Join(s.dir, "uploads", id)
+}
+
+// repository returns the repository with the given name, creating it if
+// requested. The caller must hold s.mu.
+func (s *storage) repository(name string, create bool) *repository {
+	repo := s.repositories[name]
+	if repo == nil && create {
+		repo = &repository{
+			blobs:     make(map[string]bool),
+			manifests: make(map[string]*manifest),
+			tags:      make(map[string]string),
+		}
+		s.repositories[name] = repo
+	}
+	return repo
+}
+
+func (s *storage) startUpload(name string) (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	id := hex.EncodeToString(b[:])
+	if err := os.WriteFile(s.uploadFile(id), nil, 0o644); err != nil {
+		return "", err
+	}
+	s.mu.Lock()
+	s.uploads[id] = &upload{name: name}
+	s.mu.Unlock()
+	return id, nil
+}
+
+// uploadSize returns the number of bytes received so far for an upload.
+func (s *storage) uploadSize(name, id string) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u := s.uploads[id]
+	if u == nil || u.name != name {
+		return 0, errUploadUnknown
+	}
+	return u.size, nil
+}
+
+func (s *storage) appendUpload(name, id string, r io.Reader) (int64, error) {
+	if _, err := s.uploadSize(name, id); err != nil {
+		return 0, err
+	}
+	f, err := os.OpenFile(s.uploadFile(id), os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+	n, err := io.Copy(f, r)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u := s.uploads[id]
+	if u == nil {
+		return 0, errUploadUnknown
+	}
+	u.size += n
+	return u.size, err
+}
+
+func (s *storage) cancelUpload(name, id string) error {
+	if _, err := s.uploadSize(name, id); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	delete(s.uploads, id)
+	s.mu.Unlock()
+	return os.Remove(s.uploadFile(id))
+}
+
+// finishUpload verifies the digest of an upload and moves it into the blob
+// store.
+func (s *storage) finishUpload(name, id, digest string) error {
+	if _, err := s.uploadSize(name, id); err != nil {
+		return err
+	}
+	actual, err := fileDigest(s.uploadFile(id))
+	if err != nil {
+		return err
+	}
+	if actual != digest {
+		return errDigestInvalid
+	}
+	if err := os.Rename(s.uploadFile(id), s.blobFile(digest)); err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.uploads, id)
+	s.repository(name, true).blobs[digest] = true
+	return nil
+}
+
+// mountBlob links a blob from one repository into another one.
+func (s *storage) mountBlob(name, from, digest string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	source := s.repository(from, false)
+	if source == nil || !source.blobs[digest] {
+		return false
+	}
+	s.repository(name, true).blobs[digest] = true
+	return true
+}
+
+// blob returns the file containing the blob's content.
+func (s *storage) blob(name, digest string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	repo := s.repository(name, false)
+	if repo == nil || !repo.blobs[digest] {
+		return "", errBlobUnknown
+	}
+	return s.blobFile(digest), nil
+}
+
+// putManifest stores a manifest, and tags it if reference is a tag. All
+// blobs in referencedBlobs must be present in the repository.
+func (s *storage) putManifest(name, reference, mediaType string, content []byte, referencedBlobs []string) (string, error) {
+	digest := contentDigest(content)
+	if isDigest(reference) && reference != digest {
+		return "", errDigestInvalid
+	}
+	s.mu.Lock()
+	repo := s.repository(name, false)
+	for _, blobDigest := range referencedBlobs {
+		if repo == nil || (!repo.blobs[blobDigest] && repo.manifests[blobDigest] == nil) {
+			s.mu.Unlock()
+			return "", fmt.Errorf("%w: %s", errBlobUnknown, blobDigest)
+		}
+	}
+	s.mu.Unlock()
+
+	if err := os.WriteFile(s.blobFile(digest), content, 0o644); err != nil {
+		return "", err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	repo = s.repository(name, true)
+	repo.manifests[digest] = &manifest{mediaType: mediaType, digest: digest}
+	if !isDigest(reference) {
+		repo.tags[reference] = digest
+	}
+	return digest, nil
+}
+
+// manifest returns a manifest, referenced by tag or digest, and the file
+// containing its content.
+func (s *storage) manifest(name, reference string) (*manifest, string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	repo := s.repository(name, false)
+	if repo == nil {
+		return nil, "", errManifestUnknown
+	}
+	digest := reference
+	if !isDigest(reference) {
+		digest = repo.tags[reference]
+	}
+	m := repo.manifests[digest]
+	if m == nil {
+		return nil, "", errManifestUnknown
+	}
+	return m, s.blobFile(digest), nil
+}
+
+func (s *storage) tags(name string) ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	repo := s.repository(name, false)
+	if repo == nil {
+		return nil, errNameUnknown
+	}
+	tags := []string{}
+	for tag := range repo.tags {
+		tags = append(tags, tag)
+	}
+	sort.Strings(tags)
+	return tags, nil
+}
+
+// catalog returns the sorted names of all repositories.
+func (s *storage) catalog() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	names := []string{}
+	for name := range s.repositories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// deleteManifest removes a tag, or a manifest referenced by digest together
+// with all tags pointing to it. The content stays in the blob store, as it
+// can be shared with other repositories.
+func (s *storage) deleteManifest(name, reference string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	repo := s.repository(name, false)
+	if repo == nil {
+		return errManifestUnknown
+	}
+	if !isDigest(reference) {
+		if _, ok := repo.tags[reference]; !ok {
+			return errManifestUnknown
+		}
+		delete(repo.tags, reference)
+		return nil
+	}
+	if repo.manifests[reference] == nil {
+		return errManifestUnknown
+	}
+	delete(repo.manifests, reference)
+	for tag, digest := range repo.tags {
+		if digest == reference {
+			delete(repo.tags, tag)
+		}
+	}
+	return nil
+}
+
+// deleteBlob unlinks a blob from a repository.
+func (s *storage) deleteBlob(name, digest string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	repo := s.repository(name, false)
+	if repo == nil || !repo.blobs[digest] {
+		return errBlobUnknown
+	}
+	delete(repo.blobs, digest)
+	return nil
+}
+
+func isDigest(reference string) bool {
+	return strings.Contains(reference, ":")
+}
+
+func contentDigest(content []byte) string {
+	sum := sha256.Sum256(content)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+func fileDigest(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return "sha256:" + hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,167 @@
+// Copyright (c) Ansible Project
+// GNU General Public License v3.0+ (see COPYING or https://www.gnu.org/licenses/gpl-3.0.txt)
+
+package main
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tokenService implements the token endpoint of the Docker registry token
+// authentication flow. Tokens are opaque random strings which are only known
+// to this process, and grant the actions of the scopes they were requested
+// for until they expire.
+type tokenService struct {
+	username string
+	password string
+	ttl      time.Duration
+	realm    string
+
+	mu     sync.Mutex
+	tokens map[string]*token
+}
+
+type token struct {
+	expires time.Time
+	// actions maps repository names to the granted actions.
+	actions map[string]map[string]bool
+}
+
+func newTokenService(username, password string, ttl time.Duration, realm string) *tokenService {
+	return &tokenService{
+		username: username,
+		password: password,
+		ttl:      ttl,
+		realm:    realm,
+		tokens:   make(map[string]*token),
+	}
+}
+
+// ServeHTTP issues tokens. If the registry has credentials configured, they
+// must be provided with basic authentication; otherwise tokens are issued to
+// everyone. All requested scopes of type repository are granted.
+func (ts *tokenService) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if ts.username != "" {
+		username, password, ok := r.BasicAuth()
+		if !ok ||
+			subtle.ConstantTimeCompare([]byte(username), []byte(ts.username)) != 1 ||
+			subtle.ConstantTimeCompare([]byte(password), []byte(ts.password)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Basic realm="registry-mock"`)
+			writeError(w, http.StatusUnauthorized, "UNAUTHORIZED", "invalid credentials", nil)
+			return
+		}
+	}
+
+	t := &token{
+		expires: time.Now().Add(ts.ttl),
+		actions: make(map[string]map[string]bool),
+	}
+	for _, scopes := range r.URL.Query()["scope"] {
+		for _, scope := range strings.Fields(scopes) {
+			parts := strings.Split(scope, ":")
+			if len(parts) != 3 || parts[0] != "repository" {
+				continue
+			}
+			if t.actions[parts[1]] == nil {
+				t.actions[parts[1]] = make(map[string]bool)
+			}
+			for _, action := range strings.Split(parts[2], ",") {
+				t.actions[parts[1]][action] = true
+			}
+		}
+	}
+
+	var b [32]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	value := hex.EncodeToString(b[:])
+	ts.mu.Lock()
+	ts.tokens[value] = t
+	ts.mu.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"token":        value,
+		"access_token": value,
+		"expires_in":   int(ts.ttl.Seconds()),
+		"issued_at":    time.Now().UTC().Format(time.RFC3339),
+	})
+}
+
+// requestToken returns the valid token of a request, or nil. It also tells
+// whether the request has a bearer token at all.
+func (ts *tokenService) requestToken(r *http.Request) (*token, bool) {
+	value, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok {
+		return nil, false
+	}
+	ts.mu.Lock()
+	defer ts.mu.Unlock()
+	t := ts.tokens[value]
+	if t != nil && time.Now().After(t.expires) {
+		delete(ts.tokens, value)
+		t = nil
+	}
+	return t, true
+}
+
+// grants tells whether a token grants an action on a repository. An empty
+// repository name only requires a valid token.
+func (t *token) grants(name, action string) bool {
+	return t != nil && (name == "" || t.actions[name][action] || t.actions[name]["*"])
+}
+
+// grants tells whether the bearer token of a request grants an action on a
+// repository, without answering the request.
+func (ts *tokenService) grants(r *http.Request, name, action string) bool {
+	t, _ := ts.requestToken(r)
+	return t.grants(name, action)
+}
+
+// authorized checks whether the bearer token of a request grants an action
+// on a repository. An empty repository name only requires a valid token. If
+// not, the request is answered with a challenge for the required scope.
+func (ts *tokenService) authorized(w http.ResponseWriter, r *http.Request, name, action string) bool {
+	t, ok := ts.requestToken(r)
+	if t.grants(name, action) {
+		return true
+	}
+
+	realm := ts.realm
+	if realm == "" {
+		realm = "http://" + r.Host + "/token"
+	}
+	challenge := `Bearer realm="` + realm + `",service="registry-mock"`
+	if name != "" {
+		scope := "repository:" + name + ":pull"
+		switch action {
+		case "push":
+			scope += ",push"
+		case "delete":
+			scope = "repository:" + name + ":delete"
+		}
+		challenge += `,scope="` + scope + `"`
+	}
+	w.Header().Set("WWW-Authenticate", challenge)
+	code, message := "UNAUTHORIZED", "authentication required"
+	if ok && t == nil {
+		message = "invalid or expired token"
+	} else if ok {
+		code, message = "DENIED", "requested access to the resource is denied"
+	}
+	writeError(w, http.StatusUnauthorized, code, message, nil)
+	return false
+}
@@ -0,0 +1,127 @@
+// Copyright (c) Ansible Project
+// GNU General Public License v3.0+ (see COPYING or https://www.gnu.org/licenses/gpl-3.0.txt)
+
+// Command secrets-reporter prints the Swarm secrets and configs mounted into
+// its container as a single line of JSON on stdout:
+//
+//	{"secrets":[{"name":"db_password","path":"/run/secrets/db_password","mode":"0444","uid":0,"gid":0,"size":12,"sha256":"5e884898..."}],"configs":[{"name":"app.conf","path":"/app.conf","mode":"0444","uid":0,"gid":0,"size":120,"sha256":"9f86d081..."}]}
+//
+// Secrets are all files in the secrets directory. Configs are the files given
+// as arguments, since they can be mounted anywhere. Files which cannot be
+// read are reported with an "error" field instead of the checksum. A missing
+// secrets directory results in an empty list of secrets.
+//
+// Usage:
+//
+//	secrets-reporter [-keep-running] [-listen ADDRESS] [-secrets DIRECTORY] [CONFIG...]
+//
+// The default secrets directory is /run/secrets. With -keep-running, the
+// program stays alive after printing until it receives SIGTERM or SIGINT.
+// With -listen (for example -listen :8080), it additionally serves the report
+// on every HTTP GET request to ADDRESS until it receives SIGTERM or SIGINT;
+// the files are read anew for every request, so that rotated secrets and
+// configs can be observed.
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"github.com/ansible-collections/community.docker/tests/images/internal/fixture"
+)
+
+type file struct {
+	Name   string `json:"name"`
+	Path   string `json:"path"`
+	Mode   string `json:"mode,omitempty"`
+	UID    uint32 `json:"uid"`
+	GID    uint32 `json:"gid"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+type report struct {
+	Secrets []file `json:"secrets"`
+	Configs []file `json:"configs"`
+}
+
+var (
+	secretsDir string
+	configs    []string
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("secrets-reporter: ")
+
+	keepRunning := flag.Bool("keep-running", false, "keep running after printing until SIGTERM or SIGINT")
+	listen := flag.String("listen", "", "serve the report over HTTP on this address")
+	flag.StringVar(&secretsDir, "secrets", "/run/secrets", "directory containing the secrets")
+	flag.Parse()
+	configs = flag.Args()
+
+	r, err := readReport()
+	if err != nil {
+		log.Fatal(err)
+	}
+	fixture.WriteJSON(r)
+
+	if *listen != "" {
+		fixture.Serve(*listen, fixture.ReportHandler(func() (interface{}, error) {
+			return readReport()
+		}))
+		fixture.WaitForSignal()
+	} else if *keepRunning {
+		fixture.WaitForSignal()
+	}
+}
+
+func readReport() (*report, error) {
+	r := &report{Secrets: []file{}, Configs: []file{}}
+	entries, err := os.ReadDir(secretsDir)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("cannot list secrets: %v", err)
+	}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		r.Secrets = append(r.Secrets, readFile(filepath.Join(secretsDir, entry.Name())))
+	}
+	for _, path := range configs {
+		r.Configs = append(r.Configs, readFile(path))
+	}
+	return r, nil
+}
+
+func readFile(path string) file {
+	f := file{Name: filepath.Base(path), Path: path}
+	info, err := os.Stat(path)
+	if err != nil {
+		f.Error = err.Error()
+		return f
+	}
+	f.Mode = fmt.Sprintf("%04o", info.Mode().Perm())
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+		f.UID = stat.Uid
+		f.GID = stat.Gid
+	}
+	f.Size = info.Size()
+	content, err := os.ReadFile(path)
+	if err != nil {
+		f.Error = err.Error()
+		return f
+	}
+	sum := sha256.Sum256(content)
+	f.SHA256 = hex.EncodeToString(sum[:])
+	return f
+}
@@ -0,0 +1,149 @@
+// Copyright (c) Ansible Project
+// GNU General Public License v3.0+ (see COPYING or https://www.gnu.org/licenses/gpl-3.0.txt)
+
+// Command security-reporter prints the security restrictions of its own
+// process as a single line of JSON on stdout:
+//
+//	{"seccomp":"filter","seccomp_filters":1,"no_new_privs":true,"apparmor_profile":"docker-default","apparmor_mode":"enforce","selinux_context":null,"attr_current":"docker-default (enforce)"}
+//
+// The seccomp mode (disabled, strict or filter), the number of seccomp
+// filters and the no_new_privs flag are taken from /proc/self/status. The
+// AppArmor profile and its mode are taken from /proc/self/attr/apparmor/current,
+// or from /proc/self/attr/current if AppArmor is enabled but the kernel does
+// not provide the former. Unconfined processes have the profile unconfined
+// and no mode. The SELinux context is the content of /proc/self/attr/current
+// if it has the form of one. Values which are not available are null;
+// attr_current is the raw content of /proc/self/attr/current.
+//
+// Usage:
+//
+//	security-reporter [-keep-running]
+//
+// With -keep-running, the program stays alive after printing until it
+// receives SIGTERM or SIGINT.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/ansible-collections/community.docker/tests/images/internal/fixture"
+)
+
+var seccompModes = map[string]string{
+	"0": "disabled",
+	"1": "strict",
+	"2": "filter",
+}
+
+type report struct {
+	Seccomp         *string `json:"seccomp"`
+	SeccompFilters  *int    `json:"seccomp_filters"`
+	NoNewPrivs      *bool   `json:"no_new_privs"`
+	AppArmorProfile *string `json:"apparmor_profile"`
+	AppArmorMode    *string `json:"apparmor_mode"`
+	SELinuxContext  *string `json:"selinux_context"`
+	AttrCurrent     *string `json:"attr_current"`
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("security-reporter: ")
+
+	keepRunning := flag.Bool("keep-running", false, "keep running after printing until SIGTERM or SIGINT")
+	flag.Parse()
+
+	r := &report{}
+	if err := r.readStatus("/proc/self/status"); err != nil {
+		log.Fatal(err)
+	}
+	r.AttrCurrent = readAttr("/proc/self/attr/current")
+	apparmor := readAttr("/proc/self/attr/apparmor/current")
+	if apparmor == nil && apparmorEnabled() {
+		apparmor = r.AttrCurrent
+	}
+	if apparmor != nil {
+		// The label has the form "profile (mode)", or "unconfined".
+		profile, mode, found := strings.Cut(*apparmor, " (")
+		r.AppArmorProfile = &profile
+		if found {
+			mode = strings.TrimSuffix(mode, ")")
+			r.AppArmorMode = &mode
+		}
+	}
+	if r.AttrCurrent != nil && apparmor != r.AttrCurrent && isSELinuxContext(*r.AttrCurrent) {
+		r.SELinuxContext = r.AttrCurrent
+	}
+	fixture.WriteJSON(r)
+
+	if *keepRunning {
+		fixture.WaitForSignal()
+	}
+}
+
+func (r *report) readStatus(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(scanner.Text(), ":")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		switch key {
+		case "Seccomp":
+			mode, ok := seccompModes[value]
+			if !ok {
+				mode = value
+			}
+			r.Seccomp = &mode
+		case "Seccomp_filters":
+			if n, err := strconv.Atoi(value); err == nil {
+				r.SeccompFilters = &n
+			}
+		case "NoNewPrivs":
+			enabled := value == "1"
+			r.NoNewPrivs = &enabled
+		}
+	}
+	return scanner.Err()
+}
+
+// readAttr returns the content of a file in /proc/self/attr, without the
+// trailing newline and NUL byte, or nil if it cannot be read.
+func readAttr(path string) *string {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	value := strings.TrimRight(string(content), "\x00\n")
+	return &value
+}
+
+func apparmorEnabled() bool {
+	content, err := os.ReadFile("/sys/module/apparmor/parameters/enabled")
+	return err == nil && strings.TrimSpace(string(content)) == "Y"
+}
+
+// isSELinuxContext checks whether s has the form user:role:type:level, where
+// the level itself may contain colons.
+func isSELinuxContext(s string) bool {
+	parts := strings.SplitN(s, ":", 4)
+	if len(parts) != 4 {
+		return false
+	}
+	for _, part := range parts {
+		if part == "" || strings.ContainsAny(part, " ()") {
+			return false
+		}
+	}
+	return true
+}
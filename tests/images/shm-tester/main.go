@@ -0,0 +1,116 @@
+// Copyright (c) Ansible Project
+// GNU General Public License v3.0+ (see COPYING or https://www.gnu.org/licenses/gpl-3.0.txt)
+
+// Command shm-tester reports the size of /dev/shm and optionally tries to
+// allocate shared memory in it, printing the result as a single line of JSON
+// on stdout:
+//
+//	{"path":"/dev/shm","size":67108864,"used":0,"free":67108864,"allocation":{"requested":134217728,"ok":false,"error":"no space left on device"}}
+//
+// The sizes are in bytes and are taken from statfs. The allocation creates a
+// file in the directory and reserves the requested amount for it with
+// fallocate, which fails if the file system is too small; this verifies
+// shm_size functionally.
+//
+// Usage:
+//
+//	shm-tester [-allocate SIZE] [-path DIRECTORY] [-keep-running]
+//
+// SIZE is a number of bytes with an optional unit (B, K, M, G, or the
+// equivalent KB/KiB, MB/MiB, GB/GiB; all units are powers of 1024). Without
+// -allocate, nothing is allocated. The default path is /dev/shm. With
+// -keep-running, the program stays alive after printing until it receives
+// SIGTERM or SIGINT, and keeps the allocated memory until then; otherwise,
+// the file is removed right away.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"github.com/ansible-collections/community.docker/tests/images/internal/fixture"
+)
+
+type allocation struct {
+	Requested int64  `json:"requested"`
+	OK        bool   `json:"ok"`
+	Error     string `json:"error,omitempty"`
+}
+
+type report struct {
+	Path       string      `json:"path"`
+	Size       uint64      `json:"size"`
+	Used       uint64      `json:"used"`
+	Free       uint64      `json:"free"`
+	Allocation *allocation `json:"allocation,omitempty"`
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("shm-tester: ")
+
+	allocate := flag.String("allocate", "", "amount of shared memory to allocate")
+	path := flag.String("path", "/dev/shm", "directory to report and allocate in")
+	keepRunning := flag.Bool("keep-running", false, "keep running after printing until SIGTERM or SIGINT")
+	flag.Parse()
+
+	var size int64
+	if *allocate != "" {
+		var err error
+		if size, err = fixture.ParseSize(*allocate); err != nil {
+			fmt.Fprintf(flag.CommandLine.Output(), "invalid -allocate: %v\n", err)
+			os.Exit(2)
+		}
+	}
+
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(*path, &stat); err != nil {
+		log.Fatal(err)
+	}
+	r := report{
+		Path: *path,
+		Size: stat.Blocks * uint64(stat.Bsize),
+		Used: (stat.Blocks - stat.Bfree) * uint64(stat.Bsize),
+		Free: stat.Bavail * uint64(stat.Bsize),
+	}
+
+	var name string
+	if *allocate != "" {
+		r.Allocation = &allocation{Requested: size}
+		name = filepath.Join(*path, fmt.Sprintf("shm-tester-%d", os.Getpid()))
+		if err := allocateFile(name, size); err != nil {
+			r.Allocation.Error = err.Error()
+		} else {
+			r.Allocation.OK = true
+		}
+	}
+	fixture.WriteJSON(r)
+
+	if *keepRunning {
+		fixture.WaitForSignal()
+	}
+	if name != "" {
+		os.Remove(name)
+	}
+}
+
+// allocateFile creates the file name and reserves size bytes for it.
+func allocateFile(name string, size int64) error {
+	f, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if size == 0 {
+		return nil
+	}
+	if err := syscall.Fallocate(int(f.Fd()), 0, 0, size); err != nil {
+		os.Remove(name)
+		return err
+	}
+	return nil
+}
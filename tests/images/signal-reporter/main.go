@@ -0,0 +1,208 @@
+// Copyright (c) Ansible Project
+// GNU General Public License v3.0+ (see COPYING or https://www.gnu.org/licenses/gpl-3.0.txt)
+
+// Command signal-reporter catches every signal it can, and records each
+// delivery. It exits only when it receives the stop signal, which is recorded
+// as well. Every delivery is reported as a JSON line:
+//
+//	{"time":"2021-05-01T12:00:00.123Z","signal":"SIGHUP","number":1,"stop":false}
+//
+// SIGKILL and SIGSTOP cannot be caught. SIGURG is not recorded, since the Go
+// runtime uses it internally.
+//
+// Usage:
+//
+//	signal-reporter [-stop-signal SIGNAL] [-exit-code CODE] [-output FILE] [-listen ADDRESS]
+//
+// The stop signal defaults to SIGTERM. It can be given by name, with or
+// without SIG prefix, by number, or as SIGRTMIN+N. When it is received, the
+// program exits with status CODE, which defaults to 0.
+//
+// Deliveries are printed to stdout. With -output, they are also appended to
+// FILE, which is created if needed; this allows to observe deliveries across
+// container restarts when FILE is on a volume. With -listen (for example
+// -listen :8080), all deliveries since the program started are served as
+// JSON list on every HTTP GET request to ADDRESS.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/ansible-collections/community.docker/tests/images/internal/fixture"
+)
+
+// sigrtmin is the first real-time signal available to programs. The C
+// library reserves the ones before it, and the kernel supports up to 64.
+const (
+	sigrtmin = 34
+	sigrtmax = 64
+)
+
+var signalNames = map[syscall.Signal]string{
+	syscall.SIGHUP:    "SIGHUP",
+	syscall.SIGINT:    "SIGINT",
+	syscall.SIGQUIT:   "SIGQUIT",
+	syscall.SIGILL:    "SIGILL",
+	syscall.SIGTRAP:   "SIGTRAP",
+	syscall.SIGABRT:   "SIGABRT",
+	syscall.SIGBUS:    "SIGBUS",
+	syscall.SIGFPE:    "SIGFPE",
+	syscall.SIGKILL:   "SIGKILL",
+	syscall.SIGUSR1:   "SIGUSR1",
+	syscall.SIGSEGV:   "SIGSEGV",
+	syscall.SIGUSR2:   "SIGUSR2",
+	syscall.SIGPIPE:   "SIGPIPE",
+	syscall.SIGALRM:   "SIGALRM",
+	syscall.SIGTERM:   "SIGTERM",
+	syscall.SIGSTKFLT: "SIGSTKFLT",
+	syscall.SIGCHLD:   "SIGCHLD",
+	syscall.SIGCONT:   "SIGCONT",
+	syscall.SIGSTOP:   "SIGSTOP",
+	syscall.SIGTSTP:   "SIGTSTP",
+	syscall.SIGTTIN:   "SIGTTIN",
+	syscall.SIGTTOU:   "SIGTTOU",
+	syscall.SIGURG:    "SIGURG",
+	syscall.SIGXCPU:   "SIGXCPU",
+	syscall.SIGXFSZ:   "SIGXFSZ",
+	syscall.SIGVTALRM: "SIGVTALRM",
+	syscall.SIGPROF:   "SIGPROF",
+	syscall.SIGWINCH:  "SIGWINCH",
+	syscall.SIGIO:     "SIGIO",
+	syscall.SIGPWR:    "SIGPWR",
+	syscall.SIGSYS:    "SIGSYS",
+}
+
+type delivery struct {
+	Time   time.Time `json:"time"`
+	Signal string    `json:"signal"`
+	Number int       `json:"number"`
+	Stop   bool      `json:"stop"`
+}
+
+type recorder struct {
+	mu         sync.Mutex
+	deliveries []delivery
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("signal-reporter: ")
+
+	stopSignalName := flag.String("stop-signal", "SIGTERM", "signal to exit on")
+	exitCode := flag.Int("exit-code", 0, "exit status when receiving the stop signal")
+	output := flag.String("output", "", "file to append deliveries to")
+	listen := flag.String("listen", "", "serve the deliveries over HTTP on this address")
+	flag.Parse()
+
+	stopSignal, err := parseSignal(*stopSignalName)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if stopSignal == syscall.SIGKILL || stopSignal == syscall.SIGSTOP || stopSignal == syscall.SIGURG {
+		log.Fatalf("%s cannot be used as stop signal", signalName(stopSignal))
+	}
+
+	var file *os.File
+	if *output != "" {
+		if file, err = os.OpenFile(*output, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644); err != nil {
+			log.Fatal(err)
+		}
+		defer file.Close()
+	}
+
+	rec := &recorder{}
+	if *listen != "" {
+		http.Handle("/", rec)
+		fixture.Serve(*listen, nil)
+	}
+
+	ch := make(chan os.Signal, 16)
+	signal.Notify(ch)
+	for sig := range ch {
+		number := sig.(syscall.Signal)
+		if number == syscall.SIGURG {
+			continue
+		}
+		d := delivery{
+			Time:   time.Now().UTC(),
+			Signal: signalName(number),
+			Number: int(number),
+			Stop:   number == stopSignal,
+		}
+		line, err := json.Marshal(d)
+		if err != nil {
+			log.Fatal(err)
+		}
+		line = append(line, '\n')
+		os.Stdout.Write(line)
+		if file != nil {
+			if _, err := file.Write(line); err != nil {
+				log.Fatalf("cannot write to %s: %v", *output, err)
+			}
+		}
+		rec.mu.Lock()
+		rec.deliveries = append(rec.deliveries, d)
+		rec.mu.Unlock()
+		if d.Stop {
+			os.Exit(*exitCode)
+		}
+	}
+}
+
+func signalName(sig syscall.Signal) string {
+	if name, ok := signalNames[sig]; ok {
+		return name
+	}
+	if sig >= sigrtmin && sig <= sigrtmax {
+		return fmt.Sprintf("SIGRTMIN+%d", sig-sigrtmin)
+	}
+	return fmt.Sprintf("SIG%d", int(sig))
+}
+
+// parseSignal parses a signal given by name, with or without SIG prefix, by
+// number, or as SIGRTMIN+N.
+func parseSignal(s string) (syscall.Signal, error) {
+	if number, err := strconv.Atoi(s); err == nil && number > 0 && number <= sigrtmax {
+		return syscall.Signal(number), nil
+	}
+	name := strings.ToUpper(s)
+	if !strings.HasPrefix(name, "SIG") {
+		name = "SIG" + name
+	}
+	if offset, ok := strings.CutPrefix(name, "SIGRTMIN+"); ok {
+		if n, err := strconv.Atoi(offset); err == nil && n >= 0 && sigrtmin+n <= sigrtmax {
+			return syscall.Signal(sigrtmin + n), nil
+		}
+	}
+	if name == "SIGRTMIN" {
+		return sigrtmin, nil
+	}
+	for sig, signalName := range signalNames {
+		if signalName == name {
+			return sig, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown signal %q", s)
+}
+
+func (rec *recorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	rec.mu.Lock()
+	deliveries := append([]delivery{}, rec.deliveries...)
+	rec.mu.Unlock()
+	fixture.ServeJSON(w, deliveries)
+}
@@ -0,0 +1,95 @@
+// Copyright (c) Ansible Project
+// GNU General Public License v3.0+ (see COPYING or https://www.gnu.org/licenses/gpl-3.0.txt)
+
+// Command slow-streamer writes output slowly, in chunks of a fixed size with
+// a delay between them, optionally stalling once in the middle of the stream.
+// It is meant for testing how output is streamed through docker exec, attach
+// and logs, and how read timeouts are handled.
+//
+// Every chunk is written with a single write call. A chunk starts with its
+// zero-based number as six digits followed by a space, is padded with dots,
+// and ends with a newline. Chunk 3 with the default size of 16 bytes is:
+//
+//	000003 ........
+//
+// Chunks shorter than 8 bytes consist of dots and the final newline only.
+//
+// Usage:
+//
+//	slow-streamer [-chunks N] [-chunk-size BYTES] [-delay DURATION] [-stall-after N] [-stall DURATION] [-stream stdout|stderr|both] [-exit-code CODE]
+//
+// The defaults are -chunks 10, -chunk-size 16 and -delay 500ms. With
+// -stall-after N, the program waits for the -stall duration (default 30s)
+// after the N-th chunk before continuing. -stream selects where chunks are
+// written to; with both, chunks alternate between stdout and stderr,
+// starting with stdout. When all chunks are written, the program exits with
+// -exit-code (default 0). SIGTERM and SIGINT end the program immediately
+// with the same exit code.
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/ansible-collections/community.docker/tests/images/internal/fixture"
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("slow-streamer: ")
+
+	chunks := flag.Int("chunks", 10, "number of chunks to write")
+	chunkSize := flag.Int("chunk-size", 16, "size of every chunk in bytes")
+	delay := flag.Duration("delay", 500*time.Millisecond, "delay between two chunks")
+	stallAfter := flag.Int("stall-after", 0, "stall after this many chunks (0 means never)")
+	stall := flag.Duration("stall", 30*time.Second, "duration of the stall")
+	stream := flag.String("stream", "stdout", "stream to write to: stdout, stderr or both")
+	exitCode := flag.Int("exit-code", 0, "exit code")
+	flag.Parse()
+
+	if *chunks < 0 || *chunkSize < 1 || *delay < 0 || *stallAfter < 0 || *stall < 0 {
+		fmt.Fprintln(flag.CommandLine.Output(), "counts must not be negative, and -chunk-size must be at least 1")
+		os.Exit(2)
+	}
+	outputs := map[string][]*os.File{
+		"stdout": {os.Stdout},
+		"stderr": {os.Stderr},
+		"both":   {os.Stdout, os.Stderr},
+	}[*stream]
+	if outputs == nil {
+		fmt.Fprintf(flag.CommandLine.Output(), "invalid -stream %q\n", *stream)
+		os.Exit(2)
+	}
+
+	go func() {
+		fixture.WaitForSignal()
+		os.Exit(*exitCode)
+	}()
+
+	for i := 0; i < *chunks; i++ {
+		if i > 0 {
+			time.Sleep(*delay)
+		}
+		if *stallAfter > 0 && i == *stallAfter {
+			time.Sleep(*stall)
+		}
+		if _, err := outputs[i%len(outputs)].Write(chunk(i, *chunkSize)); err != nil {
+			log.Fatal(err)
+		}
+	}
+	os.Exit(*exitCode)
+}
+
+// chunk returns the content of chunk number i.
+func chunk(i, size int) []byte {
+	content := bytes.Repeat([]byte{'.'}, size)
+	content[size-1] = '\n'
+	if size >= 8 {
+		copy(content, fmt.Sprintf("%06d ", i%1000000))
+	}
+	return content
+}
@@ -0,0 +1,204 @@
+// Copyright (c) Ansible Project
+// GNU General Public License v3.0+ (see COPYING or https://www.gnu.org/licenses/gpl-3.0.txt)
+
+// Command ssh-server is a minimal SSH server for testing access to Docker
+// daemons over ssh:// URLs. It supports public key authentication only, the
+// execution of a fixed set of commands, and local port forwarding.
+//
+// The command docker system dial-stdio, which the Docker CLI and the Docker
+// SDK for Python run on the remote host, connects the session to the daemon
+// given with -docker, usually engine-mock. Further commands can be defined in
+// a JSON file given with -commands, mapping each command line to its output
+// and exit status:
+//
+//	{"docker version --format '{{.Server.Version}}'": {"stdout": "20.10.0\n", "stderr": "", "exit_status": 0}}
+//
+// All other commands fail with exit status 127. Shells and pseudo-terminals
+// are refused.
+//
+// To test failure modes, keys not in the authorized keys file are rejected,
+// and -drop-after closes every channel after the given duration.
+//
+// Usage:
+//
+//	ssh-server -authorized-keys FILE [-listen ADDRESS] [-host-key FILE] [-user NAME] [-docker ADDRESS] [-commands FILE] [-drop-after DURATION]
+//
+// The defaults are -listen :2222 and -docker unix:///var/run/docker.sock.
+// The daemon address is either tcp://HOST:PORT or unix://PATH. With -user,
+// only that user may log in; otherwise any user with an authorized key may.
+// If the host key file given with -host-key does not exist, a new key is
+// generated and written to it, and its public key to FILE.pub. Without
+// -host-key, a new key is generated at every start. The public host key is
+// logged to stderr, as are all connections, authentication attempts and
+// commands. The program runs until it receives SIGTERM or SIGINT.
+package main
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ansible-collections/community.docker/tests/images/internal/fixture"
+	"golang.org/x/crypto/ssh"
+)
+
+type commandResult struct {
+	Stdout     string `json:"stdout"`
+	Stderr     string `json:"stderr"`
+	ExitStatus uint32 `json:"exit_status"`
+}
+
+type server struct {
+	config    *ssh.ServerConfig
+	network   string
+	address   string
+	commands  map[string]commandResult
+	dropAfter time.Duration
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("ssh-server: ")
+
+	listen := flag.String("listen", ":2222", "address to listen on")
+	hostKeyFile := flag.String("host-key", "", "host key file, created if it does not exist")
+	authorizedKeysFile := flag.String("authorized-keys", "", "file with the public keys allowed to log in")
+	user := flag.String("user", "", "only allow this user to log in")
+	docker := flag.String("docker", "unix:///var/run/docker.sock", "daemon address for docker system dial-stdio")
+	commandsFile := flag.String("commands", "", "JSON file defining further commands")
+	dropAfter := flag.Duration("drop-after", 0, "close channels after this duration")
+	flag.Parse()
+
+	if *authorizedKeysFile == "" {
+		log.Fatal("-authorized-keys must be given")
+	}
+	authorized, err := readAuthorizedKeys(*authorizedKeysFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	hostKey, err := loadHostKey(*hostKeyFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	log.Printf("host key: %s", bytes.TrimSpace(ssh.MarshalAuthorizedKey(hostKey.PublicKey())))
+
+	s := &server{commands: make(map[string]commandResult), dropAfter: *dropAfter}
+	var ok bool
+	if s.network, s.address, ok = strings.Cut(*docker, "://"); !ok || (s.network != "tcp" && s.network != "unix") {
+		log.Fatalf("invalid daemon address %q", *docker)
+	}
+	if *commandsFile != "" {
+		content, err := os.ReadFile(*commandsFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		var commands map[string]commandResult
+		if err := json.Unmarshal(content, &commands); err != nil {
+			log.Fatalf("cannot parse %s: %v", *commandsFile, err)
+		}
+		for command, result := range commands {
+			s.commands[normalizeCommand(command)] = result
+		}
+	}
+	s.config = &ssh.ServerConfig{
+		PublicKeyCallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			fingerprint := ssh.FingerprintSHA256(key)
+			if *user != "" && conn.User() != *user {
+				log.Printf("%s: rejected user %s", conn.RemoteAddr(), conn.User())
+				return nil, fmt.Errorf("user %s not allowed", conn.User())
+			}
+			if !authorized[fingerprint] {
+				log.Printf("%s: rejected key %s for user %s", conn.RemoteAddr(), fingerprint, conn.User())
+				return nil, fmt.Errorf("key %s not authorized", fingerprint)
+			}
+			log.Printf("%s: accepted key %s for user %s", conn.RemoteAddr(), fingerprint, conn.User())
+			return nil, nil
+		},
+	}
+	s.config.AddHostKey(hostKey)
+
+	listener, err := net.Listen("tcp", *listen)
+	if err != nil {
+		log.Fatal(err)
+	}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				log.Fatal(err)
+			}
+			go s.handleConn(conn)
+		}
+	}()
+	fixture.WaitForSignal()
+}
+
+// readAuthorizedKeys returns the fingerprints of the keys in an
+// authorized_keys file.
+func readAuthorizedKeys(path string) (map[string]bool, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	keys := make(map[string]bool)
+	for len(bytes.TrimSpace(content)) > 0 {
+		key, _, _, rest, err := ssh.ParseAuthorizedKey(content)
+		if err != nil {
+			return nil, fmt.Errorf("cannot parse %s: %v", path, err)
+		}
+		keys[ssh.FingerprintSHA256(key)] = true
+		content = rest
+	}
+	return keys, nil
+}
+
+// loadHostKey reads the host key from path, or generates a new one. If path
+// is given but does not exist, the new key is written to it.
+func loadHostKey(path string) (ssh.Signer, error) {
+	if path != "" {
+		content, err := os.ReadFile(path)
+		if err == nil {
+			return ssh.ParsePrivateKey(content)
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+	}
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		return nil, err
+	}
+	if path != "" {
+		block, err := ssh.MarshalPrivateKey(key, "ssh-server host key")
+		if err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(path, pem.EncodeToMemory(block), 0o600); err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(path+".pub", ssh.MarshalAuthorizedKey(signer.PublicKey()), 0o644); err != nil {
+			return nil, err
+		}
+	}
+	return signer, nil
+}
+
+// normalizeCommand collapses the whitespace in a command line.
+func normalizeCommand(command string) string {
+	return strings.Join(strings.Fields(command), " ")
+}
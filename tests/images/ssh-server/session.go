@@ -0,0 +1,147 @@
+// Copyright (c) Ansible Project
+// GNU General Public License v3.0+ (see COPYING or https://www.gnu.org/licenses/gpl-3.0.txt)
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"strconv"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// dialStdio is the command the Docker clients run to reach the daemon.
+const dialStdio = "docker system dial-stdio"
+
+func (s *server) handleConn(netConn net.Conn) {
+	conn, channels, requests, err := ssh.NewServerConn(netConn, s.config)
+	if err != nil {
+		log.Printf("%s: %v", netConn.RemoteAddr(), err)
+		return
+	}
+	defer conn.Close()
+	go ssh.DiscardRequests(requests)
+	for newChannel := range channels {
+		switch newChannel.ChannelType() {
+		case "session":
+			go s.handleSession(conn, newChannel)
+		case "direct-tcpip":
+			go s.handleForward(conn, newChannel)
+		default:
+			newChannel.Reject(ssh.UnknownChannelType, "unsupported channel type")
+		}
+	}
+	log.Printf("%s: disconnected", conn.RemoteAddr())
+}
+
+// accept accepts a new channel, and closes it after the configured duration.
+func (s *server) accept(newChannel ssh.NewChannel) (ssh.Channel, <-chan *ssh.Request, error) {
+	channel, requests, err := newChannel.Accept()
+	if err != nil {
+		return nil, nil, err
+	}
+	if s.dropAfter > 0 {
+		time.AfterFunc(s.dropAfter, func() {
+			channel.Close()
+		})
+	}
+	return channel, requests, nil
+}
+
+func (s *server) handleSession(conn *ssh.ServerConn, newChannel ssh.NewChannel) {
+	channel, requests, err := s.accept(newChannel)
+	if err != nil {
+		log.Printf("%s: %v", conn.RemoteAddr(), err)
+		return
+	}
+	defer channel.Close()
+	for req := range requests {
+		if req.Type != "exec" {
+			// Environment variables, pseudo-terminals and shells are not
+			// supported.
+			req.Reply(false, nil)
+			continue
+		}
+		var payload struct{ Command string }
+		if err := ssh.Unmarshal(req.Payload, &payload); err != nil {
+			req.Reply(false, nil)
+			continue
+		}
+		req.Reply(true, nil)
+		command := normalizeCommand(payload.Command)
+		status := s.run(channel, command)
+		log.Printf("%s: %s: exit status %d", conn.RemoteAddr(), command, status)
+		channel.SendRequest("exit-status", false, ssh.Marshal(struct{ Status uint32 }{status}))
+		return
+	}
+}
+
+// run executes a command on a session channel and returns its exit status.
+func (s *server) run(channel ssh.Channel, command string) uint32 {
+	if command == dialStdio {
+		daemon, err := net.Dial(s.network, s.address)
+		if err != nil {
+			fmt.Fprintf(channel.Stderr(), "cannot connect to the Docker daemon: %v\n", err)
+			return 1
+		}
+		defer daemon.Close()
+		go func() {
+			io.Copy(daemon, channel)
+			if closer, ok := daemon.(interface{ CloseWrite() error }); ok {
+				closer.CloseWrite()
+			}
+		}()
+		io.Copy(channel, daemon)
+		return 0
+	}
+	result, ok := s.commands[command]
+	if !ok {
+		fmt.Fprintf(channel.Stderr(), "%s: command not found\n", command)
+		return 127
+	}
+	io.WriteString(channel, result.Stdout)
+	io.WriteString(channel.Stderr(), result.Stderr)
+	return result.ExitStatus
+}
+
+// handleForward connects a direct-tcpip channel, as opened by ssh -L, to its
+// target.
+func (s *server) handleForward(conn *ssh.ServerConn, newChannel ssh.NewChannel) {
+	var payload struct {
+		Host       string
+		Port       uint32
+		OriginHost string
+		OriginPort uint32
+	}
+	if err := ssh.Unmarshal(newChannel.ExtraData(), &payload); err != nil {
+		newChannel.Reject(ssh.ConnectionFailed, "invalid request")
+		return
+	}
+	target := net.JoinHostPort(payload.Host, strconv.Itoa(int(payload.Port)))
+	up, err := net.DialTimeout("tcp", target, 10*time.Second)
+	if err != nil {
+		log.Printf("%s: forwarding to %s: %v", conn.RemoteAddr(), target, err)
+		newChannel.Reject(ssh.ConnectionFailed, err.Error())
+		return
+	}
+	defer up.Close()
+	channel, requests, err := s.accept(newChannel)
+	if err != nil {
+		log.Printf("%s: %v", conn.RemoteAddr(), err)
+		return
+	}
+	defer channel.Close()
+	go ssh.DiscardRequests(requests)
+	log.Printf("%s: forwarding to %s", conn.RemoteAddr(), target)
+	go func() {
+		io.Copy(up, channel)
+		if closer, ok := up.(interface{ CloseWrite() error }); ok {
+			closer.CloseWrite()
+		}
+	}()
+	io.Copy(channel, up)
+}
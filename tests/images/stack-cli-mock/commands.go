@@ -0,0 +1,396 @@
+// Copyright (c) Ansible Project
+// GNU General Public License v3.0+ (see COPYING or https://www.gnu.org/licenses/gpl-3.0.txt)
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"gopkg.in/yaml.v3"
+)
+
+var fieldFormatRegexp = regexp.MustCompile(`^\{\{\s*\.(\w+)\s*\}\}$`)
+
+type cli struct {
+	scenario *scenario
+	state    *state
+	stdout   io.Writer
+	stderr   io.Writer
+}
+
+// printRows prints rows in the requested format. columns determines the
+// order of the columns in table format.
+func (c *cli) printRows(flags map[string][]string, columns []string, rows []map[string]string) int {
+	format := "table"
+	if formats := flags["--format"]; len(formats) > 0 {
+		format = formats[len(formats)-1]
+	}
+	switch {
+	case format == "{{json .}}" || format == "json":
+		for _, row := range rows {
+			// Like the docker CLI, the keys are sorted.
+			line, _ := json.Marshal(row)
+			fmt.Fprintf(c.stdout, "%s\n", line)
+		}
+	case fieldFormatRegexp.MatchString(format):
+		field := fieldFormatRegexp.FindStringSubmatch(format)[1]
+		for _, row := range rows {
+			value, ok := row[field]
+			if !ok {
+				fmt.Fprintf(c.stderr, "template: :1:2: executing \"\" at <.%s>: can't evaluate field %s\n", field, field)
+				return 1
+			}
+			fmt.Fprintln(c.stdout, value)
+		}
+	case format == "table" || format == "":
+		w := tabwriter.NewWriter(c.stdout, 0, 4, 3, ' ', 0)
+		fmt.Fprintln(w, strings.ToUpper(strings.Join(columns, "\t")))
+		for _, row := range rows {
+			values := make([]string, len(columns))
+			for i, column := range columns {
+				values[i] = row[column]
+			}
+			fmt.Fprintln(w, strings.Join(values, "\t"))
+		}
+		w.Flush()
+	default:
+		fmt.Fprintf(c.stderr, "stack-cli-mock: unsupported format %q\n", format)
+		return 1
+	}
+	return 0
+}
+
+func (c *cli) nothingFound(name string) {
+	w := c.stderr
+	if c.scenario.NothingFoundStream == "stdout" {
+		w = c.stdout
+	}
+	fmt.Fprintf(w, "Nothing found in stack: %s\n", name)
+}
+
+func sortedServiceNames(s *stack) []string {
+	names := make([]string, 0, len(s.Services))
+	for name := range s.Services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (c *cli) stackList(flags map[string][]string) int {
+	columns := []string{"Name", "Services"}
+	var extra []string
+	for field := range c.scenario.LsExtraFields {
+		extra = append(extra, field)
+	}
+	sort.Strings(extra)
+	columns = append(columns, extra...)
+
+	names := make([]string, 0, len(c.state.Stacks))
+	for name := range c.state.Stacks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	rows := []map[string]string{}
+	for _, name := range names {
+		row := map[string]string{
+			"Name":     name,
+			"Services": fmt.Sprint(len(c.state.Stacks[name].Services)),
+		}
+		for field, value := range c.scenario.LsExtraFields {
+			row[field] = value
+		}
+		rows = append(rows, row)
+	}
+	return c.printRows(flags, columns, rows)
+}
+
+func (c *cli) stackTasks(flags map[string][]string, positional []string) int {
+	if len(positional) != 1 {
+		fmt.Fprintln(c.stderr, `"docker stack ps" requires exactly 1 argument.`)
+		return 1
+	}
+	s := c.state.Stacks[positional[0]]
+	if s == nil || len(s.Services) == 0 {
+		fmt.Fprintf(c.stderr, "nothing found in stack: %s\n", positional[0])
+		return 1
+	}
+	columns := []string{"ID", "Name", "Image", "Node", "DesiredState", "CurrentState", "Error", "Ports"}
+	rows := []map[string]string{}
+	for _, name := range sortedServiceNames(s) {
+		svc := s.Services[name]
+		for slot := 1; slot <= svc.Replicas; slot++ {
+			taskName := fmt.Sprintf("%s.%d", name, slot)
+			if svc.Global {
+				taskName = name + ".mocknode"
+			}
+			rows = append(rows, map[string]string{
+				"ID":           fmt.Sprintf("t%s%02d", svc.ID[len(svc.ID)-9:], slot),
+				"Name":         taskName,
+				"Image":        svc.Image,
+				"Node":         "mocknode",
+				"DesiredState": "Running",
+				"CurrentState": "Running 10 seconds ago",
+				"Error":        "",
+				"Ports":        "",
+			})
+		}
+	}
+	return c.printRows(flags, columns, rows)
+}
+
+func (c *cli) stackServices(flags map[string][]string, positional []string) int {
+	if len(positional) != 1 {
+		fmt.Fprintln(c.stderr, `"docker stack services" requires exactly 1 argument.`)
+		return 1
+	}
+	s := c.state.Stacks[positional[0]]
+	if s == nil || len(s.Services) == 0 {
+		c.nothingFound(positional[0])
+		return 0
+	}
+	columns := []string{"ID", "Name", "Mode", "Replicas", "Image", "Ports"}
+	rows := []map[string]string{}
+	for _, name := range sortedServiceNames(s) {
+		svc := s.Services[name]
+		mode := "replicated"
+		if svc.Global {
+			mode = "global"
+		}
+		rows = append(rows, map[string]string{
+			"ID":       svc.ID[:12],
+			"Name":     name,
+			"Mode":     mode,
+			"Replicas": fmt.Sprintf("%d/%d", svc.Replicas, svc.Replicas),
+			"Image":    svc.Image,
+			"Ports":    "",
+		})
+	}
+	return c.printRows(flags, columns, rows)
+}
+
+// stackDeploy deploys the services of the compose files. --with-registry-auth
+// and --resolve-image are accepted, but have no effect.
+func (c *cli) stackDeploy(flags map[string][]string, positional []string) int {
+	if len(positional) != 1 {
+		fmt.Fprintln(c.stderr, `"docker stack deploy" requires exactly 1 argument.`)
+		return 1
+	}
+	stackName := positional[0]
+	if len(flags["--compose-file"]) == 0 {
+		fmt.Fprintln(c.stderr, "Please specify a compose file (with --compose-file).")
+		return 1
+	}
+
+	definitions := make(map[string]map[string]interface{})
+	for _, path := range flags["--compose-file"] {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			fmt.Fprintf(c.stderr, "open %s: %v\n", path, err)
+			return 1
+		}
+		var file struct {
+			Services map[string]map[string]interface{} `yaml:"services"`
+		}
+		if err := yaml.Unmarshal(content, &file); err != nil {
+			fmt.Fprintf(c.stderr, "yaml: %v\n", err)
+			return 1
+		}
+		// Later files override the keys of services in earlier ones.
+		for name, definition := range file.Services {
+			if definitions[name] == nil {
+				definitions[name] = make(map[string]interface{})
+			}
+			for key, value := range definition {
+				definitions[name][key] = value
+			}
+		}
+	}
+
+	s := c.state.Stacks[stackName]
+	if s == nil {
+		s = &stack{Services: make(map[string]*service)}
+		c.state.Stacks[stackName] = s
+		fmt.Fprintf(c.stdout, "Creating network %s_default\n", stackName)
+	}
+	s.Removing = nil
+
+	names := make([]string, 0, len(definitions))
+	for name := range definitions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	deployed := make(map[string]bool)
+	for _, name := range names {
+		fullName := stackName + "_" + name
+		svc, err := newService(stackName, fullName, definitions[name])
+		if err != nil {
+			fmt.Fprintf(c.stderr, "service %s: %v\n", name, err)
+			return 1
+		}
+		deployed[fullName] = true
+		if existing := s.Services[fullName]; existing != nil {
+			svc.ID = existing.ID
+			fmt.Fprintf(c.stdout, "Updating service %s (id: %s)\n", fullName, svc.ID)
+		} else {
+			svc.ID = c.state.newID()
+			fmt.Fprintf(c.stdout, "Creating service %s\n", fullName)
+		}
+		s.Services[fullName] = svc
+	}
+	if _, prune := flags["--prune"]; prune {
+		for _, name := range sortedServiceNames(s) {
+			if !deployed[name] {
+				fmt.Fprintf(c.stdout, "Removing service %s\n", name)
+				delete(s.Services, name)
+			}
+		}
+	}
+	return 0
+}
+
+// newService creates a service with a spec resembling the one the docker
+// CLI would create from a compose service definition.
+func newService(stackName, fullName string, definition map[string]interface{}) (*service, error) {
+	image, _ := definition["image"].(string)
+	if image == "" {
+		return nil, fmt.Errorf("no image specified")
+	}
+	svc := &service{Image: image, Replicas: 1}
+	labels := map[string]interface{}{}
+	mode := map[string]interface{}{}
+	if deploy, ok := definition["deploy"].(map[string]interface{}); ok {
+		if deploy["mode"] == "global" {
+			svc.Global = true
+		}
+		if replicas, ok := deploy["replicas"].(int); ok {
+			svc.Replicas = replicas
+		}
+		if deployLabels, ok := deploy["labels"].(map[string]interface{}); ok {
+			for key, value := range deployLabels {
+				labels[key] = fmt.Sprint(value)
+			}
+		}
+	}
+	if svc.Global {
+		svc.Replicas = 1
+		mode["Global"] = map[string]interface{}{}
+	} else {
+		mode["Replicated"] = map[string]interface{}{"Replicas": svc.Replicas}
+	}
+	labels["com.docker.stack.image"] = image
+	labels["com.docker.stack.namespace"] = stackName
+
+	containerSpec := map[string]interface{}{
+		"Image":  image,
+		"Labels": map[string]interface{}{"com.docker.stack.namespace": stackName},
+	}
+	if env := environment(definition["environment"]); len(env) > 0 {
+		containerSpec["Env"] = env
+	}
+	switch command := definition["command"].(type) {
+	case string:
+		containerSpec["Args"] = strings.Fields(command)
+	case []interface{}:
+		args := make([]string, len(command))
+		for i, arg := range command {
+			args[i] = fmt.Sprint(arg)
+		}
+		containerSpec["Args"] = args
+	}
+
+	svc.Spec = map[string]interface{}{
+		"Name":         fullName,
+		"Labels":       labels,
+		"TaskTemplate": map[string]interface{}{"ContainerSpec": containerSpec},
+		"Mode":         mode,
+	}
+	return svc, nil
+}
+
+// environment converts the list or mapping syntax of a compose service's
+// environment to a sorted list of KEY=VALUE strings.
+func environment(value interface{}) []string {
+	var env []string
+	switch value := value.(type) {
+	case []interface{}:
+		for _, entry := range value {
+			env = append(env, fmt.Sprint(entry))
+		}
+	case map[string]interface{}:
+		for key, entry := range value {
+			if entry == nil {
+				env = append(env, key)
+			} else {
+				env = append(env, fmt.Sprintf("%s=%v", key, entry))
+			}
+		}
+	}
+	sort.Strings(env)
+	return env
+}
+
+func (c *cli) stackRemove(positional []string) int {
+	if len(positional) == 0 {
+		fmt.Fprintln(c.stderr, `"docker stack rm" requires at least 1 argument.`)
+		return 1
+	}
+	for _, name := range positional {
+		s := c.state.Stacks[name]
+		if s == nil {
+			c.nothingFound(name)
+			continue
+		}
+		for _, serviceName := range sortedServiceNames(s) {
+			fmt.Fprintf(c.stdout, "Removing service %s\n", serviceName)
+		}
+		fmt.Fprintf(c.stdout, "Removing network %s_default\n", name)
+		if s.Removing == nil {
+			pending := c.scenario.RmPending
+			s.Removing = &pending
+		} else {
+			*s.Removing--
+		}
+		if *s.Removing <= 0 {
+			delete(c.state.Stacks, name)
+		}
+	}
+	return 0
+}
+
+func (c *cli) serviceInspect(positional []string) int {
+	if len(positional) == 0 {
+		fmt.Fprintln(c.stderr, `"docker service inspect" requires at least 1 argument.`)
+		return 1
+	}
+	result := []map[string]interface{}{}
+	rc := 0
+	for _, name := range positional {
+		var found *service
+		for _, s := range c.state.Stacks {
+			if svc := s.Services[name]; svc != nil {
+				found = svc
+			}
+		}
+		if found == nil {
+			fmt.Fprintf(c.stderr, "Error: no such service: %s\n", name)
+			rc = 1
+			continue
+		}
+		result = append(result, map[string]interface{}{
+			"ID":      found.ID,
+			"Version": map[string]interface{}{"Index": 1},
+			"Spec":    found.Spec,
+		})
+	}
+	content, _ := json.MarshalIndent(result, "", "    ")
+	fmt.Fprintf(c.stdout, "%s\n", content)
+	return rc
+}
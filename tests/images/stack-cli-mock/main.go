@@ -0,0 +1,147 @@
+// Copyright (c) Ansible Project
+// GNU General Public License v3.0+ (see COPYING or https://www.gnu.org/licenses/gpl-3.0.txt)
+
+// Command stack-cli-mock emulates the parts of the docker CLI used by the
+// docker_stack, docker_stack_info and docker_stack_task_info modules, so
+// that their handling of the CLI's output can be tested without a Swarm.
+// It is not an image; build it as a binary named docker and put it in front
+// of the real docker CLI in PATH:
+//
+//	go build -o /tmp/stack-cli-mock/docker ./stack-cli-mock
+//
+// The following commands are supported:
+//
+//	docker stack ls [--format FORMAT]
+//	docker stack ps [--format FORMAT] STACK
+//	docker stack services [--format FORMAT] STACK
+//	docker stack deploy [--prune] [--with-registry-auth] [--resolve-image MODE] --compose-file FILE... STACK
+//	docker stack rm STACK...
+//	docker service inspect SERVICE...
+//
+// FORMAT can be {{json .}} or json (one JSON object per line), a single field
+// like {{.Name}}, or table (the default).
+//
+// Since every command runs as a separate process, the deployed stacks are
+// kept in a state file, given by the DOCKER_STACK_MOCK_STATE environment
+// variable (docker-stack-mock-state.json in the temporary directory by
+// default). The state file also records the arguments of every invocation
+// under "invocations".
+//
+// The behavior can be adjusted with a JSON scenario file given by the
+// DOCKER_STACK_MOCK_SCENARIO environment variable, to emulate the output of
+// different docker CLI versions and failures:
+//
+//	{
+//	  "ls_extra_fields": {"Orchestrator": "Swarm", "Namespace": ""},
+//	  "nothing_found_stream": "stdout",
+//	  "rm_pending": 2,
+//	  "failures": {"stack deploy": {"rc": 1, "stderr": "failed to create service\n"}}
+//	}
+//
+// ls_extra_fields are added to every line of docker stack ls, as older CLI
+// versions reported the orchestrator and namespace. nothing_found_stream is
+// the stream (stderr, the default, or stdout) on which "Nothing found in
+// stack" is reported. rm_pending is the number of additional docker stack rm
+// invocations during which a removed stack still exists, as it happens while
+// Swarm removes the services. failures maps commands to the return code and
+// output they fail with, before doing anything else.
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	sc, err := loadScenario()
+	if err != nil {
+		fmt.Fprintf(stderr, "stack-cli-mock: %v\n", err)
+		return 125
+	}
+	st, err := loadState()
+	if err != nil {
+		fmt.Fprintf(stderr, "stack-cli-mock: %v\n", err)
+		return 125
+	}
+	st.Invocations = append(st.Invocations, args)
+	defer func() {
+		if err := st.save(); err != nil {
+			fmt.Fprintf(stderr, "stack-cli-mock: %v\n", err)
+		}
+	}()
+
+	if len(args) < 2 {
+		fmt.Fprintln(stderr, "stack-cli-mock: expected a command like 'stack ls'")
+		return 1
+	}
+	command := args[0] + " " + args[1]
+	if f, ok := sc.Failures[command]; ok {
+		fmt.Fprint(stdout, f.Stdout)
+		fmt.Fprint(stderr, f.Stderr)
+		return f.RC
+	}
+
+	c := &cli{scenario: sc, state: st, stdout: stdout, stderr: stderr}
+	flags, positional, err := parseArgs(args[2:])
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	switch command {
+	case "stack ls":
+		return c.stackList(flags)
+	case "stack ps":
+		return c.stackTasks(flags, positional)
+	case "stack services":
+		return c.stackServices(flags, positional)
+	case "stack deploy":
+		return c.stackDeploy(flags, positional)
+	case "stack rm", "stack remove":
+		return c.stackRemove(positional)
+	case "service inspect":
+		return c.serviceInspect(positional)
+	}
+	fmt.Fprintf(stderr, "stack-cli-mock: unsupported command %q\n", strings.Join(args, " "))
+	return 1
+}
+
+// valueFlags are the flags which take a value.
+var valueFlags = map[string]bool{
+	"--format":        true,
+	"--compose-file":  true,
+	"-c":              true,
+	"--resolve-image": true,
+}
+
+// parseArgs splits arguments into flags and positional arguments. Flags
+// which take a value are collected in order, other flags get an empty value.
+func parseArgs(args []string) (map[string][]string, []string, error) {
+	flags := make(map[string][]string)
+	var positional []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if !strings.HasPrefix(arg, "-") {
+			positional = append(positional, arg)
+			continue
+		}
+		name, value, hasValue := strings.Cut(arg, "=")
+		if valueFlags[name] && !hasValue {
+			if i+1 >= len(args) {
+				return nil, nil, fmt.Errorf("flag needs an argument: %s", name)
+			}
+			i++
+			value = args[i]
+		}
+		if name == "-c" {
+			name = "--compose-file"
+		}
+		flags[name] = append(flags[name], value)
+	}
+	return flags, positional, nil
+}
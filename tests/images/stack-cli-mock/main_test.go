@@ -0,0 +1,231 @@
+// Copyright (c) Ansible Project
+// GNU General Public License v3.0+ (see COPYING or https://www.gnu.org/licenses/gpl-3.0.txt)
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+type step struct {
+	args   []string
+	rc     int
+	stdout string
+	stderr string
+}
+
+// runSteps runs the steps in order against the same state.
+func runSteps(t *testing.T, steps []step) {
+	for _, s := range steps {
+		var stdout, stderr bytes.Buffer
+		rc := run(s.args, &stdout, &stderr)
+		if rc != s.rc {
+			t.Errorf("%q: got rc %d, expected %d (stderr %q)", s.args, rc, s.rc, stderr.String())
+		}
+		if stdout.String() != s.stdout {
+			t.Errorf("%q: got stdout %q, expected %q", s.args, stdout.String(), s.stdout)
+		}
+		if stderr.String() != s.stderr {
+			t.Errorf("%q: got stderr %q, expected %q", s.args, stderr.String(), s.stderr)
+		}
+	}
+}
+
+func writeFile(t *testing.T, dir, name, content string) string {
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+const composeFile = `
+services:
+  web:
+    image: nginx
+    deploy:
+      replicas: 2
+  db:
+    image: postgres
+    deploy:
+      mode: global
+`
+
+func TestRun(t *testing.T) {
+	dir := t.TempDir()
+	statePath := filepath.Join(dir, "state.json")
+	t.Setenv("DOCKER_STACK_MOCK_STATE", statePath)
+	t.Setenv("DOCKER_STACK_MOCK_SCENARIO", "")
+	compose := writeFile(t, dir, "compose.yml", composeFile)
+	override := writeFile(t, dir, "override.yml", "services:\n  web:\n    image: nginx:alpine\n")
+
+	steps := []step{
+		{[]string{"stack", "ls"}, 0, "NAME   SERVICES\n", ""},
+		{[]string{"stack", "ls", "--format", "{{json.}}"}, 1, "", "stack-cli-mock: unsupported format \"{{json.}}\"\n"},
+		{[]string{"stack", "deploy", "app"}, 1, "", "Please specify a compose file (with --compose-file).\n"},
+		{[]string{"stack", "deploy", "--compose-file"}, 1, "", "flag needs an argument: --compose-file\n"},
+		{[]string{"stack", "deploy", "-c", compose}, 1, "", "\"docker stack deploy\" requires exactly 1 argument.\n"},
+		{[]string{"stack", "deploy", "--with-registry-auth", "--resolve-image=never", "-c", compose, "app"}, 0,
+			"Creating network app_default\nCreating service app_db\nCreating service app_web\n", ""},
+		{[]string{"stack", "ls"}, 0, "NAME   SERVICES\napp    2\n", ""},
+		{[]string{"stack", "ls", "--format", "json"}, 0, "{\"Name\":\"app\",\"Services\":\"2\"}\n", ""},
+		{[]string{"stack", "ls", "--format", "{{.Name}}"}, 0, "app\n", ""},
+		{[]string{"stack", "ls", "--format", "{{.Orchestrator}}"}, 1, "",
+			"template: :1:2: executing \"\" at <.Orchestrator>: can't evaluate field Orchestrator\n"},
+		{[]string{"stack", "services", "app"}, 0,
+			"ID             NAME      MODE         REPLICAS   IMAGE      PORTS\n" +
+				"mock00000000   app_db    global       1/1        postgres   \n" +
+				"mock00000000   app_web   replicated   2/2        nginx      \n", ""},
+		{[]string{"stack", "services", "--format", "{{json .}}", "app"}, 0,
+			"{\"ID\":\"mock00000000\",\"Image\":\"postgres\",\"Mode\":\"global\",\"Name\":\"app_db\",\"Ports\":\"\",\"Replicas\":\"1/1\"}\n" +
+				"{\"ID\":\"mock00000000\",\"Image\":\"nginx\",\"Mode\":\"replicated\",\"Name\":\"app_web\",\"Ports\":\"\",\"Replicas\":\"2/2\"}\n", ""},
+		{[]string{"stack", "services", "missing"}, 0, "", "Nothing found in stack: missing\n"},
+		{[]string{"stack", "services"}, 1, "", "\"docker stack services\" requires exactly 1 argument.\n"},
+		{[]string{"stack", "ps", "--format", "{{.Name}}", "app"}, 0, "app_db.mocknode\napp_web.1\napp_web.2\n", ""},
+		{[]string{"stack", "ps", "--format", "{{.ID}}", "app"}, 0, "t00000000101\nt00000000201\nt00000000202\n", ""},
+		{[]string{"stack", "ps", "app"}, 0,
+			"ID             NAME              IMAGE      NODE       DESIREDSTATE   CURRENTSTATE             ERROR   PORTS\n" +
+				"t00000000101   app_db.mocknode   postgres   mocknode   Running        Running 10 seconds ago           \n" +
+				"t00000000201   app_web.1         nginx      mocknode   Running        Running 10 seconds ago           \n" +
+				"t00000000202   app_web.2         nginx      mocknode   Running        Running 10 seconds ago           \n", ""},
+		{[]string{"stack", "ps", "missing"}, 1, "", "nothing found in stack: missing\n"},
+		// Deploying again updates the services, with the later compose file
+		// overriding the image of the first.
+		{[]string{"stack", "deploy", "-c", compose, "--compose-file=" + override, "app"}, 0,
+			"Updating service app_db (id: mock000000000000000000001)\nUpdating service app_web (id: mock000000000000000000002)\n", ""},
+		{[]string{"stack", "services", "--format", "{{.Image}}", "app"}, 0, "postgres\nnginx:alpine\n", ""},
+		{[]string{"stack", "deploy", "--prune", "-c", override, "app"}, 0,
+			"Updating service app_web (id: mock000000000000000000002)\nRemoving service app_db\n", ""},
+		{[]string{"service", "inspect", "app_web", "missing"}, 1,
+			"[\n" +
+				"    {\n" +
+				"        \"ID\": \"mock000000000000000000002\",\n" +
+				"        \"Spec\": {\n" +
+				"            \"Labels\": {\n" +
+				"                \"com.docker.stack.image\": \"nginx:alpine\",\n" +
+				"                \"com.docker.stack.namespace\": \"app\"\n" +
+				"            },\n" +
+				"            \"Mode\": {\n" +
+				"                \"Replicated\": {\n" +
+				"                    \"Replicas\": 1\n" +
+				"                }\n" +
+				"            },\n" +
+				"            \"Name\": \"app_web\",\n" +
+				"            \"TaskTemplate\": {\n" +
+				"                \"ContainerSpec\": {\n" +
+				"                    \"Image\": \"nginx:alpine\",\n" +
+				"                    \"Labels\": {\n" +
+				"                        \"com.docker.stack.namespace\": \"app\"\n" +
+				"                    }\n" +
+				"                }\n" +
+				"            }\n" +
+				"        },\n" +
+				"        \"Version\": {\n" +
+				"            \"Index\": 1\n" +
+				"        }\n" +
+				"    }\n" +
+				"]\n", "Error: no such service: missing\n"},
+		{[]string{"service", "inspect"}, 1, "", "\"docker service inspect\" requires at least 1 argument.\n"},
+		{[]string{"stack", "rm", "app", "missing"}, 0, "Removing service app_web\nRemoving network app_default\n", "Nothing found in stack: missing\n"},
+		{[]string{"stack", "remove"}, 1, "", "\"docker stack rm\" requires at least 1 argument.\n"},
+		{[]string{"stack", "ls", "--format", "{{.Name}}"}, 0, "", ""},
+		{[]string{"stack"}, 1, "", "stack-cli-mock: expected a command like 'stack ls'\n"},
+		{[]string{"stack", "top", "app"}, 1, "", "stack-cli-mock: unsupported command \"stack top app\"\n"},
+	}
+	runSteps(t, steps)
+
+	content, err := os.ReadFile(statePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var st state
+	if err := json.Unmarshal(content, &st); err != nil {
+		t.Fatal(err)
+	}
+	if len(st.Invocations) != len(steps) {
+		t.Fatalf("got %d invocations, expected %d", len(st.Invocations), len(steps))
+	}
+	for i, s := range steps {
+		if !slices.Equal(st.Invocations[i], s.args) {
+			t.Errorf("invocation %d: got %q, expected %q", i, st.Invocations[i], s.args)
+		}
+	}
+}
+
+func TestRunScenario(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("DOCKER_STACK_MOCK_STATE", filepath.Join(dir, "state.json"))
+	t.Setenv("DOCKER_STACK_MOCK_SCENARIO", writeFile(t, dir, "scenario.json", `{
+		"ls_extra_fields": {"Orchestrator": "Swarm", "Namespace": ""},
+		"nothing_found_stream": "stdout",
+		"rm_pending": 1,
+		"failures": {"service inspect": {"rc": 3, "stdout": "out\n", "stderr": "err\n"}}
+	}`))
+	compose := writeFile(t, dir, "compose.yml", "services:\n  web:\n    image: nginx\n")
+
+	runSteps(t, []step{
+		{[]string{"stack", "deploy", "-c", compose, "app"}, 0, "Creating network app_default\nCreating service app_web\n", ""},
+		{[]string{"stack", "ls"}, 0, "NAME   SERVICES   NAMESPACE   ORCHESTRATOR\napp    1                      Swarm\n", ""},
+		{[]string{"stack", "ls", "--format", "json"}, 0, "{\"Name\":\"app\",\"Namespace\":\"\",\"Orchestrator\":\"Swarm\",\"Services\":\"1\"}\n", ""},
+		{[]string{"stack", "ls", "--format", "{{.Orchestrator}}"}, 0, "Swarm\n", ""},
+		{[]string{"service", "inspect", "app_web"}, 3, "out\n", "err\n"},
+		// The stack survives the first removal.
+		{[]string{"stack", "rm", "app"}, 0, "Removing service app_web\nRemoving network app_default\n", ""},
+		{[]string{"stack", "ls", "--format", "{{.Name}}"}, 0, "app\n", ""},
+		{[]string{"stack", "rm", "app"}, 0, "Removing service app_web\nRemoving network app_default\n", ""},
+		{[]string{"stack", "ls", "--format", "{{.Name}}"}, 0, "", ""},
+		{[]string{"stack", "rm", "app"}, 0, "Nothing found in stack: app\n", ""},
+		{[]string{"stack", "services", "app"}, 0, "Nothing found in stack: app\n", ""},
+	})
+}
+
+func TestRunEnvironment(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("TMPDIR", dir)
+	t.Setenv("DOCKER_STACK_MOCK_STATE", "")
+	t.Setenv("DOCKER_STACK_MOCK_SCENARIO", "")
+	compose := writeFile(t, dir, "compose.yml", "services:\n  web:\n    image: nginx\n")
+
+	// Without DOCKER_STACK_MOCK_STATE, the state is kept in the temporary
+	// directory.
+	runSteps(t, []step{
+		{[]string{"stack", "deploy", "-c", compose, "app"}, 0, "Creating network app_default\nCreating service app_web\n", ""},
+		{[]string{"stack", "ls", "--format", "{{.Name}}"}, 0, "app\n", ""},
+	})
+	if _, err := os.Stat(filepath.Join(dir, "docker-stack-mock-state.json")); err != nil {
+		t.Error(err)
+	}
+
+	for _, tc := range []struct {
+		name     string
+		scenario string
+		state    string
+		stderr   string
+	}{
+		{"missing scenario", filepath.Join(dir, "missing.json"), "",
+			"stack-cli-mock: open " + filepath.Join(dir, "missing.json") + ": no such file or directory\n"},
+		{"invalid scenario", writeFile(t, dir, "invalid.json", "["), "",
+			"stack-cli-mock: cannot parse " + filepath.Join(dir, "invalid.json") + ": unexpected end of JSON input\n"},
+		{"invalid stream", writeFile(t, dir, "stream.json", `{"nothing_found_stream": "both"}`), "",
+			"stack-cli-mock: invalid nothing_found_stream \"both\"\n"},
+		{"invalid state", "", writeFile(t, dir, "state.json", "{"),
+			"stack-cli-mock: cannot parse " + filepath.Join(dir, "state.json") + ": unexpected end of JSON input\n"},
+		{"unreadable state", "", dir,
+			"stack-cli-mock: read " + dir + ": is a directory\n"},
+	} {
+		t.Setenv("DOCKER_STACK_MOCK_SCENARIO", tc.scenario)
+		t.Setenv("DOCKER_STACK_MOCK_STATE", tc.state)
+		var stdout, stderr bytes.Buffer
+		if rc := run([]string{"stack", "ls"}, &stdout, &stderr); rc != 125 {
+			t.Errorf("%q: got rc %d, expected 125", tc.name, rc)
+		}
+		if stdout.String() != "" || stderr.String() != tc.stderr {
+			t.Errorf("%s: got stdout %q and stderr %q, expected stderr %q", tc.name, stdout.String(), stderr.String(), tc.stderr)
+		}
+	}
+}
@@ -0,0 +1,106 @@
+// Copyright (c) Ansible Project
+// GNU General Public License v3.0+ (see COPYING or https://www.gnu.org/licenses/gpl-3.0.txt)
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+type failure struct {
+	RC     int    `json:"rc"`
+	Stdout string `json:"stdout"`
+	Stderr string `json:"stderr"`
+}
+
+type scenario struct {
+	LsExtraFields      map[string]string  `json:"ls_extra_fields"`
+	NothingFoundStream string             `json:"nothing_found_stream"`
+	RmPending          int                `json:"rm_pending"`
+	Failures           map[string]failure `json:"failures"`
+}
+
+type service struct {
+	ID       string                 `json:"id"`
+	Image    string                 `json:"image"`
+	Replicas int                    `json:"replicas"`
+	Global   bool                   `json:"global"`
+	Spec     map[string]interface{} `json:"spec"`
+}
+
+type stack struct {
+	Services map[string]*service `json:"services"`
+	// Removing is the number of docker stack rm invocations after which the
+	// stack is gone, if it is being removed.
+	Removing *int `json:"removing,omitempty"`
+}
+
+type state struct {
+	Stacks      map[string]*stack `json:"stacks"`
+	Invocations [][]string        `json:"invocations"`
+	NextID      int               `json:"next_id"`
+
+	path string
+}
+
+func loadScenario() (*scenario, error) {
+	sc := &scenario{}
+	path := os.Getenv("DOCKER_STACK_MOCK_SCENARIO")
+	if path != "" {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(content, sc); err != nil {
+			return nil, fmt.Errorf("cannot parse %s: %v", path, err)
+		}
+	}
+	switch sc.NothingFoundStream {
+	case "":
+		sc.NothingFoundStream = "stderr"
+	case "stderr", "stdout":
+	default:
+		return nil, fmt.Errorf("invalid nothing_found_stream %q", sc.NothingFoundStream)
+	}
+	return sc, nil
+}
+
+func loadState() (*state, error) {
+	path := os.Getenv("DOCKER_STACK_MOCK_STATE")
+	if path == "" {
+		path = filepath.Join(os.TempDir(), "docker-stack-mock-state.json")
+	}
+	st := &state{path: path}
+	content, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	if err == nil {
+		if err := json.Unmarshal(content, st); err != nil {
+			return nil, fmt.Errorf("cannot parse %s: %v", path, err)
+		}
+	}
+	if st.Stacks == nil {
+		st.Stacks = make(map[string]*stack)
+	}
+	return st, nil
+}
+
+func (st *state) save() error {
+	content, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(st.path, append(content, '\n'), 0o644)
+}
+
+// newID returns a new, deterministic ID for services and tasks.
+func (st *state) newID() string {
+	st.NextID++
+	return fmt.Sprintf("mock%021d", st.NextID)
+}
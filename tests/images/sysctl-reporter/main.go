@@ -0,0 +1,80 @@
+// Copyright (c) Ansible Project
+// GNU General Public License v3.0+ (see COPYING or https://www.gnu.org/licenses/gpl-3.0.txt)
+
+// Command sysctl-reporter prints the values of the given kernel parameters,
+// as seen from inside the container, as a single line of JSON on stdout:
+//
+//	{"sysctls":{"net.core.somaxconn":"1024","net.ipv4.ip_forward":"1"},"errors":{}}
+//
+// Keys use the dotted notation of sysctl(8) and Docker's sysctls option and
+// are read from the corresponding files below /proc/sys. Keys that cannot be
+// read are reported in errors with the reason instead.
+//
+// Usage:
+//
+//	sysctl-reporter [-keep-running] KEY...
+//
+// With -keep-running, the program stays alive after printing until it
+// receives SIGTERM or SIGINT.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ansible-collections/community.docker/tests/images/internal/fixture"
+)
+
+type sysctlValues struct {
+	Sysctls map[string]string `json:"sysctls"`
+	Errors  map[string]string `json:"errors"`
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("sysctl-reporter: ")
+
+	keepRunning := flag.Bool("keep-running", false, "keep running after printing until SIGTERM or SIGINT")
+	flag.Usage = func() {
+		fmt.Fprintln(flag.CommandLine.Output(), "usage: sysctl-reporter [-keep-running] KEY...")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	result := sysctlValues{
+		Sysctls: make(map[string]string),
+		Errors:  make(map[string]string),
+	}
+	for _, key := range flag.Args() {
+		value, err := readSysctl(key)
+		if err != nil {
+			result.Errors[key] = err.Error()
+			continue
+		}
+		result.Sysctls[key] = value
+	}
+	fixture.WriteJSON(result)
+
+	if *keepRunning {
+		fixture.WaitForSignal()
+	}
+}
+
+func readSysctl(key string) (string, error) {
+	path := filepath.Join("/proc/sys", strings.ReplaceAll(key, ".", "/"))
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	// Multi-value parameters like net.ipv4.ip_local_port_range are separated
+	// by tabs in /proc/sys, but by spaces when set by Docker.
+	return strings.Join(strings.Fields(string(content)), " "), nil
+}
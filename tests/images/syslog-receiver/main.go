@@ -0,0 +1,171 @@
+// Copyright (c) Ansible Project
+// GNU General Public License v3.0+ (see COPYING or https://www.gnu.org/licenses/gpl-3.0.txt)
+
+// Command syslog-receiver receives syslog messages over UDP and TCP, and
+// serves them as JSON list on GET /messages:
+//
+//	[{"received":"2021-05-01T12:00:00.123Z","transport":"udp","priority":30,"facility":"daemon","severity":"info","timestamp":"2021-05-01T12:00:00Z","hostname":"docker-host","tag":"my-container","pid":"1234","message":"hello","raw":"<30>1 2021-05-01T12:00:00Z docker-host my-container 1234 my-container - hello"}]
+//
+// Messages in the format of RFC 5424 and in the variants of the format of
+// RFC 3164 produced by the Docker syslog log driver are parsed. Fields which
+// cannot be parsed are empty, the priority is -1 if it is missing or out of
+// range. Messages received over TCP are separated by newlines, or use octet
+// counting as described in RFC 6587.
+//
+// GET /messages?tag=TAG returns only the messages with that tag. DELETE
+// /messages removes all messages received so far.
+//
+// Usage:
+//
+//	syslog-receiver [-udp ADDRESS] [-tcp ADDRESS] [-listen ADDRESS]
+//
+// The defaults are -udp :514, -tcp :514 and -listen :8080. Passing an empty
+// address disables the respective listener. The program runs until it
+// receives SIGTERM or SIGINT.
+package main
+
+import (
+	"bufio"
+	"flag"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ansible-collections/community.docker/tests/images/internal/fixture"
+)
+
+// maxMessageSize is the maximum size of a message received over UDP.
+const maxMessageSize = 64 * 1024
+
+type store struct {
+	mu       sync.Mutex
+	messages []message
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("syslog-receiver: ")
+
+	udpAddress := flag.String("udp", ":514", "UDP address to receive messages on")
+	tcpAddress := flag.String("tcp", ":514", "TCP address to receive messages on")
+	listen := flag.String("listen", ":8080", "address to serve the received messages on")
+	flag.Parse()
+
+	s := &store{}
+	if *udpAddress != "" {
+		conn, err := net.ListenPacket("udp", *udpAddress)
+		if err != nil {
+			log.Fatal(err)
+		}
+		go s.receiveUDP(conn)
+	}
+	if *tcpAddress != "" {
+		listener, err := net.Listen("tcp", *tcpAddress)
+		if err != nil {
+			log.Fatal(err)
+		}
+		go s.acceptTCP(listener)
+	}
+	if *listen != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/messages", fixture.Control{Get: s.list, Clear: s.clear})
+		fixture.Serve(*listen, mux)
+	}
+	fixture.WaitForSignal()
+}
+
+func (s *store) add(raw, transport string) {
+	m := parseMessage(raw)
+	m.Received = time.Now().UTC()
+	m.Transport = transport
+	s.mu.Lock()
+	s.messages = append(s.messages, m)
+	s.mu.Unlock()
+}
+
+func (s *store) receiveUDP(conn net.PacketConn) {
+	buf := make([]byte, maxMessageSize)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			log.Fatal(err)
+		}
+		s.add(string(buf[:n]), "udp")
+	}
+}
+
+func (s *store) acceptTCP(listener net.Listener) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			log.Fatal(err)
+		}
+		go s.receiveTCP(conn)
+	}
+}
+
+// receiveTCP reads messages from a TCP connection until it is closed. Each
+// message either starts with its length followed by a space, or ends with a
+// newline.
+func (s *store) receiveTCP(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	for {
+		first, err := r.Peek(1)
+		if err != nil {
+			return
+		}
+		if first[0] >= '0' && first[0] <= '9' {
+			prefix, err := r.ReadString(' ')
+			if err != nil {
+				log.Printf("%s: incomplete message length", conn.RemoteAddr())
+				return
+			}
+			length, err := strconv.Atoi(strings.TrimSuffix(prefix, " "))
+			if err != nil || length > maxMessageSize {
+				log.Printf("%s: invalid message length %q", conn.RemoteAddr(), prefix)
+				return
+			}
+			buf := make([]byte, length)
+			if _, err := io.ReadFull(r, buf); err != nil {
+				log.Printf("%s: incomplete message: %v", conn.RemoteAddr(), err)
+				return
+			}
+			s.add(string(buf), "tcp")
+			continue
+		}
+		line, err := r.ReadString('\n')
+		if strings.TrimSpace(line) != "" {
+			s.add(line, "tcp")
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// list returns the received messages for the /messages endpoint, optionally
+// filtered by the tag query parameter.
+func (s *store) list(r *http.Request) interface{} {
+	tag, filter := r.URL.Query()["tag"]
+	messages := []message{}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, m := range s.messages {
+		if !filter || m.Tag == tag[0] {
+			messages = append(messages, m)
+		}
+	}
+	return messages
+}
+
+func (s *store) clear() {
+	s.mu.Lock()
+	s.messages = nil
+	s.mu.Unlock()
+}
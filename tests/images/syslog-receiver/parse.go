@@ -0,0 +1,125 @@
+// Copyright (c) Ansible Project
+// GNU General Public License v3.0+ (see COPYING or https://www.gnu.org/licenses/gpl-3.0.txt)
+
+package main
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var facilityNames = []string{
+	"kern", "user", "mail", "daemon", "auth", "syslog", "lpr", "news",
+	"uucp", "cron", "authpriv", "ftp", "ntp", "security", "console", "solaris-cron",
+	"local0", "local1", "local2", "local3", "local4", "local5", "local6", "local7",
+}
+
+var severityNames = []string{
+	"emerg", "alert", "crit", "err", "warning", "notice", "info", "debug",
+}
+
+type message struct {
+	Received  time.Time `json:"received"`
+	Transport string    `json:"transport"`
+	Priority  int       `json:"priority"`
+	Facility  string    `json:"facility"`
+	Severity  string    `json:"severity"`
+	Timestamp string    `json:"timestamp"`
+	Hostname  string    `json:"hostname"`
+	Tag       string    `json:"tag"`
+	PID       string    `json:"pid"`
+	Message   string    `json:"message"`
+	Raw       string    `json:"raw"`
+}
+
+var (
+	priorityRegexp = regexp.MustCompile(`^<([0-9]{1,3})>`)
+	// stampRegexp matches the timestamp formats of RFC 3164 and RFC 3339.
+	stampRegexp = regexp.MustCompile(`^([A-Z][a-z]{2} [ 0-9][0-9] [0-9]{2}:[0-9]{2}:[0-9]{2}|[0-9]{4}-[0-9]{2}-[0-9]{2}T\S+) `)
+	tagRegexp   = regexp.MustCompile(`^([^\s\[:]+)(?:\[([^\]]*)\])?:(?: |$)`)
+)
+
+// parseMessage parses a syslog message in the format of RFC 5424 or one of the
+// variants of the format of RFC 3164 the Docker syslog log driver produces.
+// Parts which cannot be parsed are left empty, and the remainder is reported
+// as message.
+func parseMessage(raw string) message {
+	raw = strings.TrimRight(raw, "\r\n")
+	m := message{Raw: raw, Priority: -1}
+	rest := raw
+	if match := priorityRegexp.FindStringSubmatch(rest); match != nil {
+		if priority, _ := strconv.Atoi(match[1]); priority < len(facilityNames)*8 {
+			m.Priority = priority
+			m.Facility = facilityNames[priority/8]
+			m.Severity = severityNames[priority%8]
+			rest = rest[len(match[0]):]
+		}
+	}
+
+	if strings.HasPrefix(rest, "1 ") {
+		// RFC 5424: VERSION TIMESTAMP HOSTNAME APP-NAME PROCID MSGID SD MSG
+		fields := strings.SplitN(rest, " ", 7)
+		if len(fields) == 7 {
+			m.Timestamp = nilValue(fields[1])
+			m.Hostname = nilValue(fields[2])
+			m.Tag = nilValue(fields[3])
+			m.PID = nilValue(fields[4])
+			m.Message = strings.TrimPrefix(skipStructuredData(fields[6]), "\ufeff")
+			return m
+		}
+	}
+
+	rest = strings.TrimPrefix(rest, " ")
+	if match := stampRegexp.FindStringSubmatch(rest); match != nil {
+		m.Timestamp = match[1]
+		rest = rest[len(match[0]):]
+	}
+	// The hostname is optional, but the tag is always followed by a colon.
+	if !tagRegexp.MatchString(rest) {
+		if i := strings.IndexByte(rest, ' '); i >= 0 && tagRegexp.MatchString(rest[i+1:]) {
+			m.Hostname = rest[:i]
+			rest = rest[i+1:]
+		}
+	}
+	if match := tagRegexp.FindStringSubmatch(rest); match != nil {
+		m.Tag = match[1]
+		m.PID = match[2]
+		rest = rest[len(match[0]):]
+	}
+	m.Message = rest
+	return m
+}
+
+func nilValue(s string) string {
+	if s == "-" {
+		return ""
+	}
+	return s
+}
+
+// skipStructuredData removes the structured data from the start of the
+// remainder of a RFC 5424 message. It is either "-" or a sequence of elements
+// in square brackets, in which closing brackets are escaped with backslashes.
+func skipStructuredData(s string) string {
+	if strings.HasPrefix(s, "-") {
+		return strings.TrimPrefix(s[1:], " ")
+	}
+	for strings.HasPrefix(s, "[") {
+		end := -1
+		for i := 1; i < len(s) && end < 0; i++ {
+			switch s[i] {
+			case '\\':
+				i++
+			case ']':
+				end = i
+			}
+		}
+		if end < 0 {
+			return s
+		}
+		s = s[end+1:]
+	}
+	return strings.TrimPrefix(s, " ")
+}
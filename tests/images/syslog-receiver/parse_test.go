@@ -0,0 +1,91 @@
+// Copyright (c) Ansible Project
+// GNU General Public License v3.0+ (see COPYING or https://www.gnu.org/licenses/gpl-3.0.txt)
+
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseMessage(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		raw      string
+		expected message
+	}{
+		// RFC 3164 variants of the Docker syslog log driver.
+		{"rfc3164", "<30>May  1 12:00:00 docker-host my-container[1234]: hello\n", message{
+			Priority: 30, Facility: "daemon", Severity: "info", Timestamp: "May  1 12:00:00",
+			Hostname: "docker-host", Tag: "my-container", PID: "1234", Message: "hello",
+		}},
+		{"unix", "<14>May 11 12:00:00 my-container[1234]: hello", message{
+			Priority: 14, Facility: "user", Severity: "info", Timestamp: "May 11 12:00:00",
+			Tag: "my-container", PID: "1234", Message: "hello",
+		}},
+		{"rfc3164 without pid", "<13>May 11 12:00:00 host app: hello: world", message{
+			Priority: 13, Facility: "user", Severity: "notice", Timestamp: "May 11 12:00:00",
+			Hostname: "host", Tag: "app", Message: "hello: world",
+		}},
+		{"rfc3164 empty message", "<13>May 11 12:00:00 host app:", message{
+			Priority: 13, Facility: "user", Severity: "notice", Timestamp: "May 11 12:00:00",
+			Hostname: "host", Tag: "app",
+		}},
+		{"rfc3164 without timestamp", "<0>app[1]: panic", message{
+			Priority: 0, Facility: "kern", Severity: "emerg", Tag: "app", PID: "1", Message: "panic",
+		}},
+		{"rfc3339 timestamp", "<191>2021-05-01T12:00:00.123456+02:00 host app: hello", message{
+			Priority: 191, Facility: "local7", Severity: "debug", Timestamp: "2021-05-01T12:00:00.123456+02:00",
+			Hostname: "host", Tag: "app", Message: "hello",
+		}},
+		{"no tag", "<13>May 11 12:00:00 just some text", message{
+			Priority: 13, Facility: "user", Severity: "notice", Timestamp: "May 11 12:00:00",
+			Message: "just some text",
+		}},
+
+		// RFC 5424.
+		{"rfc5424", "<30>1 2021-05-01T12:00:00Z docker-host my-container 1234 my-container - hello\r\n", message{
+			Priority: 30, Facility: "daemon", Severity: "info", Timestamp: "2021-05-01T12:00:00Z",
+			Hostname: "docker-host", Tag: "my-container", PID: "1234", Message: "hello",
+		}},
+		{"rfc5424 nil values", "<165>1 - - - - - -", message{
+			Priority: 165, Facility: "local4", Severity: "notice",
+		}},
+		{"rfc5424 nil values with message", "<165>1 - - - - - - hello", message{
+			Priority: 165, Facility: "local4", Severity: "notice", Message: "hello",
+		}},
+		{"rfc5424 structured data", `<165>1 2003-10-11T22:14:15.003Z host evntslog - ID47 [exampleSDID@32473 iut="3" eventSource="Application"] hello`, message{
+			Priority: 165, Facility: "local4", Severity: "notice", Timestamp: "2003-10-11T22:14:15.003Z",
+			Hostname: "host", Tag: "evntslog", Message: "hello",
+		}},
+		{"rfc5424 several structured data elements", `<165>1 - host app - - [a@1 x="1"][b@1 y="2"] hello`, message{
+			Priority: 165, Facility: "local4", Severity: "notice", Hostname: "host", Tag: "app", Message: "hello",
+		}},
+		{"rfc5424 escaped bracket", `<165>1 - host app - - [a@1 x="[\]\"\\"] hello`, message{
+			Priority: 165, Facility: "local4", Severity: "notice", Hostname: "host", Tag: "app", Message: "hello",
+		}},
+		{"rfc5424 structured data without message", `<165>1 - host app - - [a@1]`, message{
+			Priority: 165, Facility: "local4", Severity: "notice", Hostname: "host", Tag: "app",
+		}},
+		{"rfc5424 unterminated structured data", `<165>1 - host app - - [a@1 x="1" hello`, message{
+			Priority: 165, Facility: "local4", Severity: "notice", Hostname: "host", Tag: "app",
+			Message: `[a@1 x="1" hello`,
+		}},
+		{"rfc5424 byte order mark", "<165>1 - host app - - - \ufeffhello", message{
+			Priority: 165, Facility: "local4", Severity: "notice", Hostname: "host", Tag: "app", Message: "hello",
+		}},
+
+		// Malformed priorities are kept in the message.
+		{"missing priority", "app: hello", message{Priority: -1, Tag: "app", Message: "hello"}},
+		{"priority out of range", "<192>hello", message{Priority: -1, Message: "<192>hello"}},
+		{"priority too long", "<0013>hello", message{Priority: -1, Message: "<0013>hello"}},
+		{"non-numeric priority", "<abc>hello", message{Priority: -1, Message: "<abc>hello"}},
+		{"unterminated priority", "<13 hello", message{Priority: -1, Message: "<13 hello"}},
+		{"empty", "", message{Priority: -1}},
+	} {
+		tc.expected.Raw = strings.TrimRight(tc.raw, "\r\n")
+		if got := parseMessage(tc.raw); got != tc.expected {
+			t.Errorf("%s: got %+v, expected %+v", tc.name, got, tc.expected)
+		}
+	}
+}
@@ -0,0 +1,184 @@
+// Copyright (c) Ansible Project
+// GNU General Public License v3.0+ (see COPYING or https://www.gnu.org/licenses/gpl-3.0.txt)
+
+package main
+
+import (
+	"archive/tar"
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+func createCommand(args []string) {
+	flags := flag.NewFlagSet("create", flag.ExitOnError)
+	mtime := flags.Int64("mtime", -1, "modification time of all entries as Unix time (default: the entries' own)")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		usage()
+	}
+
+	out := bufio.NewWriter(os.Stdout)
+	if err := writeArchive(out, flags.Arg(0), *mtime); err != nil {
+		log.Fatal(err)
+	}
+	if err := out.Flush(); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// inode identifies a file, to detect hard links.
+type inode struct {
+	dev uint64
+	ino uint64
+}
+
+// writeArchive writes the content of dir as a deterministic tar archive to w.
+func writeArchive(w io.Writer, dir string, mtime int64) error {
+	tw := tar.NewWriter(w)
+	links := make(map[inode]string)
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == dir {
+			return nil
+		}
+		name, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		name = filepath.ToSlash(name)
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		hdr, err := newHeader(path, name, info)
+		if err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+		if mtime >= 0 {
+			hdr.ModTime = time.Unix(mtime, 0)
+		}
+
+		stat := info.Sys().(*syscall.Stat_t)
+		if hdr.Typeflag == tar.TypeReg && stat.Nlink > 1 {
+			key := inode{uint64(stat.Dev), stat.Ino}
+			if target, ok := links[key]; ok {
+				hdr.Typeflag, hdr.Linkname, hdr.Size = tar.TypeLink, target, 0
+			} else {
+				links[key] = name
+			}
+		}
+
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			return nil
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+// newHeader returns the header for a file, without user and group names and
+// with the modification time truncated to seconds.
+func newHeader(path, name string, info fs.FileInfo) (*tar.Header, error) {
+	stat := info.Sys().(*syscall.Stat_t)
+	hdr := &tar.Header{
+		Name:    name,
+		Mode:    int64(stat.Mode & 0o7777),
+		Uid:     int(stat.Uid),
+		Gid:     int(stat.Gid),
+		ModTime: info.ModTime().Truncate(time.Second),
+		Format:  tar.FormatPAX,
+	}
+	switch mode := info.Mode(); {
+	case mode.IsRegular():
+		hdr.Typeflag = tar.TypeReg
+		hdr.Size = info.Size()
+	case mode.IsDir():
+		hdr.Typeflag = tar.TypeDir
+		hdr.Name += "/"
+	case mode&fs.ModeSymlink != 0:
+		hdr.Typeflag = tar.TypeSymlink
+		target, err := os.Readlink(path)
+		if err != nil {
+			return nil, err
+		}
+		hdr.Linkname = target
+	case mode&fs.ModeNamedPipe != 0:
+		hdr.Typeflag = tar.TypeFifo
+	case mode&fs.ModeDevice != 0:
+		hdr.Typeflag = tar.TypeBlock
+		if mode&fs.ModeCharDevice != 0 {
+			hdr.Typeflag = tar.TypeChar
+		}
+		hdr.Devmajor = int64(unix.Major(uint64(stat.Rdev)))
+		hdr.Devminor = int64(unix.Minor(uint64(stat.Rdev)))
+	default:
+		return nil, fmt.Errorf("unsupported file type %v", mode.Type())
+	}
+
+	xattrs, err := readXattrs(path)
+	if err != nil {
+		return nil, err
+	}
+	for name, value := range xattrs {
+		if hdr.PAXRecords == nil {
+			hdr.PAXRecords = make(map[string]string)
+		}
+		hdr.PAXRecords[xattrPrefix+name] = value
+	}
+	return hdr, nil
+}
+
+// readXattrs returns the extended attributes of a file, without following
+// symbolic links. File systems without support for them have none.
+func readXattrs(path string) (map[string]string, error) {
+	size, err := unix.Llistxattr(path, nil)
+	if errors.Is(err, unix.ENOTSUP) {
+		return nil, nil
+	} else if err != nil || size == 0 {
+		return nil, err
+	}
+	buf := make([]byte, size)
+	if size, err = unix.Llistxattr(path, buf); err != nil {
+		return nil, err
+	}
+	names := strings.Split(strings.TrimSuffix(string(buf[:size]), "\x00"), "\x00")
+	xattrs := make(map[string]string)
+	for _, name := range names {
+		size, err := unix.Lgetxattr(path, name, nil)
+		if err != nil {
+			return nil, err
+		}
+		value := make([]byte, size)
+		if size, err = unix.Lgetxattr(path, name, value); err != nil {
+			return nil, err
+		}
+		xattrs[name] = string(value[:size])
+	}
+	return xattrs, nil
+}
@@ -0,0 +1,267 @@
+// Copyright (c) Ansible Project
+// GNU General Public License v3.0+ (see COPYING or https://www.gnu.org/licenses/gpl-3.0.txt)
+
+package main
+
+import (
+	"archive/tar"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ansible-collections/community.docker/tests/images/internal/fixture"
+	"golang.org/x/sys/unix"
+)
+
+const xattrPrefix = "SCHILY.xattr."
+
+var typeNames = map[byte]string{
+	tar.TypeReg:     "file",
+	tar.TypeDir:     "directory",
+	tar.TypeSymlink: "symlink",
+	tar.TypeLink:    "hardlink",
+	tar.TypeChar:    "char",
+	tar.TypeBlock:   "block",
+	tar.TypeFifo:    "fifo",
+}
+
+type entry struct {
+	Name       string            `json:"name"`
+	Type       string            `json:"type"`
+	Mode       string            `json:"mode"`
+	UID        int               `json:"uid"`
+	GID        int               `json:"gid"`
+	Uname      string            `json:"uname,omitempty"`
+	Gname      string            `json:"gname,omitempty"`
+	Size       int64             `json:"size"`
+	Mtime      string            `json:"mtime"`
+	LinkTarget string            `json:"link_target,omitempty"`
+	Major      *int64            `json:"major,omitempty"`
+	Minor      *int64            `json:"minor,omitempty"`
+	SHA256     string            `json:"sha256,omitempty"`
+	Xattrs     map[string]string `json:"xattrs,omitempty"`
+}
+
+type manifest struct {
+	Entries []entry `json:"entries"`
+}
+
+func extractCommand(args []string) {
+	flags := flag.NewFlagSet("extract", flag.ExitOnError)
+	noChown := flags.Bool("no-chown", false, "do not change the owner of extracted entries")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		usage()
+	}
+
+	m, err := extract(os.Stdin, flags.Arg(0), !*noChown)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fixture.WriteJSON(m)
+}
+
+// extract extracts the archive read from r below dir and returns its
+// entries.
+func extract(r io.Reader, dir string, chown bool) (manifest, error) {
+	m := manifest{Entries: []entry{}}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return m, err
+	}
+	// Directory times are set at the end, since creating entries in a
+	// directory changes its modification time.
+	type dirTime struct {
+		path  string
+		mtime time.Time
+	}
+	var dirTimes []dirTime
+
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return m, err
+		}
+		if hdr.Typeflag == tar.TypeXGlobalHeader {
+			continue
+		}
+		e := newEntry(hdr)
+		path := targetPath(dir, hdr.Name)
+		if e.Type == "" {
+			return m, fmt.Errorf("%s: unsupported entry type %q", hdr.Name, hdr.Typeflag)
+		}
+		if e.SHA256, err = createEntry(path, dir, hdr, tr); err != nil {
+			return m, fmt.Errorf("%s: %v", hdr.Name, err)
+		}
+		if err := setMetadata(path, hdr, chown); err != nil {
+			return m, fmt.Errorf("%s: %v", hdr.Name, err)
+		}
+		if hdr.Typeflag == tar.TypeDir {
+			dirTimes = append(dirTimes, dirTime{path, hdr.ModTime})
+		} else if hdr.Typeflag != tar.TypeLink {
+			if err := setMtime(path, hdr.ModTime); err != nil {
+				return m, fmt.Errorf("%s: %v", hdr.Name, err)
+			}
+		}
+		m.Entries = append(m.Entries, e)
+	}
+	for i := len(dirTimes) - 1; i >= 0; i-- {
+		if err := setMtime(dirTimes[i].path, dirTimes[i].mtime); err != nil {
+			return m, err
+		}
+	}
+	return m, nil
+}
+
+func newEntry(hdr *tar.Header) entry {
+	e := entry{
+		Name:  hdr.Name,
+		Type:  typeNames[hdr.Typeflag],
+		Mode:  fmt.Sprintf("%04o", hdr.Mode&0o7777),
+		UID:   hdr.Uid,
+		GID:   hdr.Gid,
+		Uname: hdr.Uname,
+		Gname: hdr.Gname,
+		Size:  hdr.Size,
+		Mtime: hdr.ModTime.UTC().Format(time.RFC3339Nano),
+	}
+	switch hdr.Typeflag {
+	case tar.TypeSymlink, tar.TypeLink:
+		e.LinkTarget = hdr.Linkname
+	case tar.TypeChar, tar.TypeBlock:
+		e.Major, e.Minor = &hdr.Devmajor, &hdr.Devminor
+	}
+	for key, value := range hdr.PAXRecords {
+		if name, ok := strings.CutPrefix(key, xattrPrefix); ok {
+			if e.Xattrs == nil {
+				e.Xattrs = make(map[string]string)
+			}
+			e.Xattrs[name] = value
+		}
+	}
+	return e
+}
+
+// targetPath returns the path of an entry below dir. Leading slashes and
+// .. components cannot leave dir.
+func targetPath(dir, name string) string {
+	return filepath.Join(dir, filepath.Clean("/"+name))
+}
+
+// checkParents returns an error if one of the parent directories of path
+// below dir is a symbolic link, through which the entry could end up outside
+// of dir. Parents which do not exist yet are created as directories.
+func checkParents(dir, path string) error {
+	rel, err := filepath.Rel(dir, filepath.Dir(path))
+	if err != nil || rel == "." {
+		return err
+	}
+	parent := dir
+	for _, name := range strings.Split(rel, string(filepath.Separator)) {
+		parent = filepath.Join(parent, name)
+		info, err := os.Lstat(parent)
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+		if info.Mode()&fs.ModeSymlink != 0 {
+			return fmt.Errorf("parent directory %s is a symbolic link", parent)
+		}
+	}
+	return nil
+}
+
+// createEntry creates the file system object for an entry, replacing an
+// existing one unless both are directories. For regular files, it returns the
+// SHA-256 digest of the content.
+func createEntry(path, dir string, hdr *tar.Header, content io.Reader) (string, error) {
+	if hdr.Typeflag == tar.TypeDir {
+		if info, err := os.Lstat(path); err == nil && info.IsDir() {
+			return "", nil
+		}
+	}
+	if err := checkParents(dir, path); err != nil {
+		return "", err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return "", err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+
+	mode := uint32(hdr.Mode & 0o7777)
+	switch hdr.Typeflag {
+	case tar.TypeReg:
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+		if err != nil {
+			return "", err
+		}
+		h := sha256.New()
+		_, err = io.Copy(io.MultiWriter(f, h), content)
+		if closeErr := f.Close(); err == nil {
+			err = closeErr
+		}
+		return "sha256:" + hex.EncodeToString(h.Sum(nil)), err
+	case tar.TypeDir:
+		return "", os.Mkdir(path, 0o700)
+	case tar.TypeSymlink:
+		return "", os.Symlink(hdr.Linkname, path)
+	case tar.TypeLink:
+		target := targetPath(dir, hdr.Linkname)
+		if err := checkParents(dir, target); err != nil {
+			return "", err
+		}
+		return "", os.Link(target, path)
+	case tar.TypeChar:
+		return "", unix.Mknod(path, unix.S_IFCHR|mode, int(unix.Mkdev(uint32(hdr.Devmajor), uint32(hdr.Devminor))))
+	case tar.TypeBlock:
+		return "", unix.Mknod(path, unix.S_IFBLK|mode, int(unix.Mkdev(uint32(hdr.Devmajor), uint32(hdr.Devminor))))
+	default:
+		return "", unix.Mkfifo(path, mode)
+	}
+}
+
+// setMetadata sets the owner, mode and extended attributes of an entry.
+// Hard links share them with their target, so they are left alone.
+func setMetadata(path string, hdr *tar.Header, chown bool) error {
+	if hdr.Typeflag == tar.TypeLink {
+		return nil
+	}
+	if chown {
+		if err := os.Lchown(path, hdr.Uid, hdr.Gid); err != nil {
+			return err
+		}
+	}
+	for key, value := range hdr.PAXRecords {
+		if name, ok := strings.CutPrefix(key, xattrPrefix); ok {
+			if err := unix.Lsetxattr(path, name, []byte(value), 0); err != nil {
+				return fmt.Errorf("cannot set extended attribute %s: %v", name, err)
+			}
+		}
+	}
+	if hdr.Typeflag == tar.TypeSymlink {
+		return nil
+	}
+	// Changing the owner clears the setuid and setgid bits, so the mode is
+	// set afterwards.
+	mode := hdr.FileInfo().Mode() & (fs.ModePerm | fs.ModeSetuid | fs.ModeSetgid | fs.ModeSticky)
+	return os.Chmod(path, mode)
+}
+
+func setMtime(path string, mtime time.Time) error {
+	ts := unix.NsecToTimespec(mtime.UnixNano())
+	return unix.UtimesNanoAt(unix.AT_FDCWD, path, []unix.Timespec{ts, ts}, unix.AT_SYMLINK_NOFOLLOW)
+}
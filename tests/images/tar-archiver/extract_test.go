@@ -0,0 +1,156 @@
+// Copyright (c) Ansible Project
+// GNU General Public License v3.0+ (see COPYING or https://www.gnu.org/licenses/gpl-3.0.txt)
+
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestTargetPath(t *testing.T) {
+	for name, expected := range map[string]string{
+		"etc/motd":        "/x/etc/motd",
+		"/etc/motd":       "/x/etc/motd",
+		"../../etc/motd":  "/x/etc/motd",
+		"etc/../../motd":  "/x/motd",
+		"./etc//motd":     "/x/etc/motd",
+		"etc/":            "/x/etc",
+		"..":              "/x",
+		"a/b/../../../..": "/x",
+	} {
+		if got := targetPath("/x", name); got != expected {
+			t.Errorf("targetPath(%q) = %q, expected %q", name, got, expected)
+		}
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	src := t.TempDir()
+	for _, err := range []error{
+		os.Mkdir(filepath.Join(src, "etc"), 0o750),
+		os.WriteFile(filepath.Join(src, "etc", "motd"), []byte("hello\n"), 0o644),
+		os.WriteFile(filepath.Join(src, "run.sh"), []byte("#!/bin/sh\n"), 0o755),
+		os.WriteFile(filepath.Join(src, "empty"), nil, 0o600),
+		os.Link(filepath.Join(src, "etc", "motd"), filepath.Join(src, "motd-link")),
+		os.Symlink("etc/motd", filepath.Join(src, "symlink")),
+		os.Symlink("/does/not/exist", filepath.Join(src, "dangling")),
+		unix.Mkfifo(filepath.Join(src, "fifo"), 0o640),
+		os.Chmod(filepath.Join(src, "run.sh"), 0o755|fs.ModeSetuid),
+	} {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	mtime := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	if err := os.Chtimes(filepath.Join(src, "etc"), mtime, mtime); err != nil {
+		t.Fatal(err)
+	}
+
+	var archive bytes.Buffer
+	if err := writeArchive(&archive, src, -1); err != nil {
+		t.Fatal(err)
+	}
+	dst := filepath.Join(t.TempDir(), "dst")
+	m, err := extract(bytes.NewReader(archive.Bytes()), dst, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, e := range m.Entries {
+		names = append(names, e.Name+" "+e.Type+" "+e.Mode+" "+e.LinkTarget+e.SHA256)
+	}
+	expected := []string{
+		"dangling symlink 0777 /does/not/exist",
+		"empty file 0600 sha256:e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855",
+		"etc/ directory 0750 ",
+		"etc/motd file 0644 sha256:5891b5b522d5df086d0ff0b110fbd9d21bb4fc7163af34d08286a2e846f6be03",
+		"fifo fifo 0640 ",
+		"motd-link hardlink 0644 etc/motd",
+		"run.sh file 4755 sha256:a8076d3d28d21e02012b20eaf7dbf75409a6277134439025f282e368e3305abf",
+		"symlink symlink 0777 etc/motd",
+	}
+	if strings.Join(names, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("got entries\n%s\nexpected\n%s", strings.Join(names, "\n"), strings.Join(expected, "\n"))
+	}
+	if info, err := os.Stat(filepath.Join(dst, "etc")); err != nil || !info.ModTime().Equal(mtime) {
+		t.Errorf("etc has modification time %v (%v), expected %v", info.ModTime(), err, mtime)
+	}
+
+	// Archiving the extracted directory gives the same archive again.
+	var again bytes.Buffer
+	if err := writeArchive(&again, dst, -1); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(archive.Bytes(), again.Bytes()) {
+		t.Error("archive of the extracted directory differs from the original archive")
+	}
+}
+
+func TestExtractSymlinkEscape(t *testing.T) {
+	outside := t.TempDir()
+	if err := os.WriteFile(filepath.Join(outside, "secret"), []byte("secret"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name    string
+		headers []*tar.Header
+	}{
+		{"absolute symlink", []*tar.Header{
+			{Typeflag: tar.TypeSymlink, Name: "a", Linkname: outside},
+			{Typeflag: tar.TypeReg, Name: "a/owned", Mode: 0o644},
+		}},
+		{"relative symlink", []*tar.Header{
+			{Typeflag: tar.TypeSymlink, Name: "a", Linkname: "../../../../../../../../.." + outside},
+			{Typeflag: tar.TypeReg, Name: "a/owned", Mode: 0o644},
+		}},
+		{"nested symlink", []*tar.Header{
+			{Typeflag: tar.TypeDir, Name: "d/", Mode: 0o755},
+			{Typeflag: tar.TypeSymlink, Name: "d/a", Linkname: outside},
+			{Typeflag: tar.TypeDir, Name: "d/a/sub/", Mode: 0o755},
+		}},
+		{"hard link through symlink", []*tar.Header{
+			{Typeflag: tar.TypeSymlink, Name: "a", Linkname: outside},
+			{Typeflag: tar.TypeLink, Name: "stolen", Linkname: "a/secret"},
+		}},
+	} {
+		var archive bytes.Buffer
+		tw := tar.NewWriter(&archive)
+		for _, hdr := range tc.headers {
+			if err := tw.WriteHeader(hdr); err != nil {
+				t.Fatal(err)
+			}
+		}
+		tw.Close()
+
+		dir := t.TempDir()
+		if _, err := extract(&archive, dir, false); err == nil || !strings.Contains(err.Error(), "is a symbolic link") {
+			t.Errorf("%s: got error %v, expected a symbolic link error", tc.name, err)
+		}
+		entries, err := os.ReadDir(outside)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != 1 {
+			t.Errorf("%s: extraction created %d entries outside of the directory", tc.name, len(entries)-1)
+			for _, e := range entries {
+				if e.Name() != "secret" {
+					os.RemoveAll(filepath.Join(outside, e.Name()))
+				}
+			}
+		}
+		if _, err := os.Lstat(filepath.Join(dir, "stolen")); err == nil {
+			t.Errorf("%s: hard link to a file outside of the directory was created", tc.name)
+		}
+	}
+}
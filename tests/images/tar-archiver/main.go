@@ -0,0 +1,63 @@
+// Copyright (c) Ansible Project
+// GNU General Public License v3.0+ (see COPYING or https://www.gnu.org/licenses/gpl-3.0.txt)
+
+// Command tar-archiver converts between tar archives and directories, to
+// compare what is copied into or out of containers with known-good archives.
+//
+// Usage:
+//
+//	tar-archiver extract [-no-chown] DIRECTORY < ARCHIVE
+//	tar-archiver create [-mtime SECONDS] DIRECTORY > ARCHIVE
+//
+// extract reads a tar archive from stdin and extracts it below DIRECTORY,
+// which is created if needed. Regular files, directories, symbolic links,
+// hard links, device nodes and FIFOs are created with the mode, owner,
+// modification time and extended attributes (from SCHILY.xattr. PAX records)
+// of their entry; with -no-chown, the owner is not changed, which allows
+// extracting as an unprivileged user. Entry names and hard link targets cannot
+// point outside of DIRECTORY: leading slashes and .. components are confined
+// to it, and entries below a symbolic link extracted before fail the
+// extraction. Afterwards, the entries of the archive are printed as a single
+// line of JSON on stdout:
+//
+//	{"entries":[{"name":"etc/","type":"directory","mode":"0755","uid":0,"gid":0,"size":0,"mtime":"2024-01-01T12:00:00Z"},{"name":"etc/motd","type":"file","mode":"0644","uid":0,"gid":0,"uname":"root","gname":"root","size":6,"mtime":"2024-01-01T12:00:00Z","sha256":"sha256:5891...","xattrs":{"user.comment":"hello"}}]}
+//
+// Link targets are given as link_target, and device numbers of device nodes
+// as major and minor. Extended attribute values are given as strings.
+//
+// create writes the content of DIRECTORY as a tar archive to stdout. The
+// archive is deterministic: entries are named relative to DIRECTORY and
+// written in lexical order, directories end in a slash, user and group names
+// are left empty, modification times are truncated to seconds (or all set to
+// SECONDS with -mtime), and extended attributes are sorted. Files sharing an
+// inode are written as hard links to the first of them.
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("tar-archiver: ")
+
+	if len(os.Args) < 2 {
+		usage()
+	}
+	switch os.Args[1] {
+	case "extract":
+		extractCommand(os.Args[2:])
+	case "create":
+		createCommand(os.Args[2:])
+	default:
+		usage()
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: tar-archiver extract [-no-chown] DIRECTORY < ARCHIVE")
+	fmt.Fprintln(os.Stderr, "       tar-archiver create [-mtime SECONDS] DIRECTORY > ARCHIVE")
+	os.Exit(2)
+}
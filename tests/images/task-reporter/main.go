@@ -0,0 +1,116 @@
+// Copyright (c) Ansible Project
+// GNU General Public License v3.0+ (see COPYING or https://www.gnu.org/licenses/gpl-3.0.txt)
+
+// Command task-reporter prints the metadata of the Swarm task it runs as, as
+// a single line of JSON on stdout:
+//
+//	{"hostname":"node-1","started":"2021-05-01T12:00:00.123Z","env":{"TASK_SLOT":"2","SERVICE_NAME":"web"}}
+//
+// Swarm passes task metadata to containers by templates in the hostname and
+// in environment variables, for example with
+//
+//	docker service create --hostname '{{.Node.Hostname}}' --env 'TASK_SLOT={{.Task.Slot}}' --env 'SERVICE_NAME={{.Service.Name}}' task-reporter
+//
+// The arguments name the environment variables to report; variables which
+// are not set are reported with an empty value. Without arguments, all
+// environment variables are reported. The start time allows to verify the
+// order in which tasks were replaced by rolling updates.
+//
+// Usage:
+//
+//	task-reporter [-keep-running] [-listen ADDRESS] [NAME...]
+//	task-reporter -check ADDRESS
+//
+// With -keep-running, the program stays alive after printing until it
+// receives SIGTERM or SIGINT. With -listen (for example -listen :8080), it
+// additionally serves the report on every HTTP GET request to ADDRESS until it
+// receives SIGTERM or SIGINT. The image's entrypoint always passes
+// -listen :8080, so that the health check works whatever arguments a service
+// gives; further flags and names can follow.
+//
+// With -check, the program requests the report from a running instance
+// listening on ADDRESS and exits with status 0 if it succeeds and 1
+// otherwise. The image uses this as health check, since it contains no
+// other tools.
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ansible-collections/community.docker/tests/images/internal/fixture"
+)
+
+type report struct {
+	Hostname string            `json:"hostname"`
+	Started  time.Time         `json:"started"`
+	Env      map[string]string `json:"env"`
+}
+
+var started = time.Now().UTC()
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("task-reporter: ")
+
+	keepRunning := flag.Bool("keep-running", false, "keep running after printing until SIGTERM or SIGINT")
+	listen := flag.String("listen", "", "serve the report over HTTP on this address")
+	check := flag.String("check", "", "check the health of an instance listening on this address")
+	flag.Parse()
+
+	if *check != "" {
+		os.Exit(checkHealth(*check))
+	}
+
+	r, err := readReport(flag.Args())
+	if err != nil {
+		log.Fatal(err)
+	}
+	fixture.WriteJSON(r)
+
+	if *listen != "" {
+		fixture.Serve(*listen, fixture.ReportHandler(func() (interface{}, error) {
+			return readReport(flag.Args())
+		}))
+		fixture.WaitForSignal()
+	} else if *keepRunning {
+		fixture.WaitForSignal()
+	}
+}
+
+func checkHealth(address string) int {
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Get("http://" + address + "/")
+	if err != nil {
+		log.Print(err)
+		return 1
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		log.Printf("unexpected status %s", resp.Status)
+		return 1
+	}
+	return 0
+}
+
+func readReport(names []string) (*report, error) {
+	hostname, err := os.Hostname()
+	if err != nil {
+		return nil, err
+	}
+	r := &report{Hostname: hostname, Started: started, Env: make(map[string]string)}
+	if len(names) == 0 {
+		for _, variable := range os.Environ() {
+			name, value, _ := strings.Cut(variable, "=")
+			r.Env[name] = value
+		}
+	}
+	for _, name := range names {
+		r.Env[name] = os.Getenv(name)
+	}
+	return r, nil
+}
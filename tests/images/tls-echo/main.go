@@ -0,0 +1,172 @@
+// Copyright (c) Ansible Project
+// GNU General Public License v3.0+ (see COPYING or https://www.gnu.org/licenses/gpl-3.0.txt)
+
+// Command tls-echo accepts TLS connections and reports the negotiated
+// parameters as JSON:
+//
+//	{"version":"TLS 1.3","cipher_suite":"TLS_AES_128_GCM_SHA256","server_name":"echo.example.com","alpn":"http/1.1","client_certificates":[{"subject":"CN=client","issuer":"CN=Test CA","serial":"1","not_after":"2031-05-01T12:00:00Z"}]}
+//
+// The client has to send first. If it sends an HTTP request, the report is
+// returned as response body, and the connection is closed. Otherwise the
+// report is sent as a single line, followed by an echo of everything the
+// client sends.
+//
+// With -client-ca, clients must present a certificate signed by one of the
+// certificate authorities in FILE. Failed handshakes are logged to stderr
+// with their reason.
+//
+// Usage:
+//
+//	tls-echo [-listen ADDRESS] [-cert FILE] [-key FILE] [-client-ca FILE]
+//
+// The defaults are -listen :8443, -cert /certs/cert.pem and -key
+// /certs/key.pem. The files are in PEM format. The program runs until it
+// receives SIGTERM or SIGINT.
+package main
+
+import (
+	"bufio"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/ansible-collections/community.docker/tests/images/internal/fixture"
+)
+
+type certificate struct {
+	Subject  string    `json:"subject"`
+	Issuer   string    `json:"issuer"`
+	Serial   string    `json:"serial"`
+	NotAfter time.Time `json:"not_after"`
+}
+
+type report struct {
+	Version            string        `json:"version"`
+	CipherSuite        string        `json:"cipher_suite"`
+	ServerName         string        `json:"server_name"`
+	ALPN               string        `json:"alpn"`
+	ClientCertificates []certificate `json:"client_certificates"`
+}
+
+// httpMethods are the beginnings of HTTP requests.
+var httpMethods = []string{"GET ", "HEAD ", "POST ", "PUT ", "DELETE ", "OPTIONS ", "PATCH "}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("tls-echo: ")
+
+	listen := flag.String("listen", ":8443", "address to listen on")
+	certFile := flag.String("cert", "/certs/cert.pem", "server certificate")
+	keyFile := flag.String("key", "/certs/key.pem", "private key of the server certificate")
+	clientCA := flag.String("client-ca", "", "require client certificates signed by the certificate authorities in this file")
+	flag.Parse()
+
+	cert, err := tls.LoadX509KeyPair(*certFile, *keyFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	config := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		NextProtos:   []string{"http/1.1"},
+	}
+	if *clientCA != "" {
+		content, err := os.ReadFile(*clientCA)
+		if err != nil {
+			log.Fatal(err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(content) {
+			log.Fatalf("no certificates found in %s", *clientCA)
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	listener, err := tls.Listen("tcp", *listen, config)
+	if err != nil {
+		log.Fatal(err)
+	}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				log.Fatal(err)
+			}
+			go handle(conn.(*tls.Conn))
+		}
+	}()
+	fixture.WaitForSignal()
+}
+
+func handle(conn *tls.Conn) {
+	defer conn.Close()
+	if err := conn.Handshake(); err != nil {
+		log.Printf("%s: handshake failed: %v", conn.RemoteAddr(), err)
+		return
+	}
+	state := conn.ConnectionState()
+	r := report{
+		Version:            tls.VersionName(state.Version),
+		CipherSuite:        tls.CipherSuiteName(state.CipherSuite),
+		ServerName:         state.ServerName,
+		ALPN:               state.NegotiatedProtocol,
+		ClientCertificates: []certificate{},
+	}
+	for _, cert := range state.PeerCertificates {
+		r.ClientCertificates = append(r.ClientCertificates, certificate{
+			Subject:  cert.Subject.String(),
+			Issuer:   cert.Issuer.String(),
+			Serial:   cert.SerialNumber.String(),
+			NotAfter: cert.NotAfter.UTC(),
+		})
+	}
+	content, err := json.Marshal(r)
+	if err != nil {
+		log.Print(err)
+		return
+	}
+	content = append(content, '\n')
+	log.Printf("%s: %s", conn.RemoteAddr(), content[:len(content)-1])
+
+	reader := bufio.NewReader(conn)
+	if isHTTP(reader) {
+		req, err := http.ReadRequest(reader)
+		if err != nil {
+			log.Printf("%s: %v", conn.RemoteAddr(), err)
+			return
+		}
+		req.Body.Close()
+		fmt.Fprintf(conn, "HTTP/1.1 200 OK\r\nContent-Type: application/json\r\nContent-Length: %d\r\nConnection: close\r\n\r\n", len(content))
+		if req.Method != http.MethodHead {
+			conn.Write(content)
+		}
+		return
+	}
+	if _, err := conn.Write(content); err != nil {
+		return
+	}
+	io.Copy(conn, reader)
+}
+
+// isHTTP waits for the first data from the client, and checks whether it is
+// the start of an HTTP request, without consuming it.
+func isHTTP(reader *bufio.Reader) bool {
+	if _, err := reader.Peek(1); err != nil {
+		return false
+	}
+	data, _ := reader.Peek(reader.Buffered())
+	for _, method := range httpMethods {
+		if strings.HasPrefix(string(data), method) {
+			return true
+		}
+	}
+	return false
+}
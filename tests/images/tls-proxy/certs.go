@@ -0,0 +1,169 @@
+// Copyright (c) Ansible Project
+// GNU General Public License v3.0+ (see COPYING or https://www.gnu.org/licenses/gpl-3.0.txt)
+
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+type keyPair struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	der  []byte
+}
+
+// certificateOptions control the generated server certificate.
+type certificateOptions struct {
+	hostnames []string
+	expired   bool
+}
+
+// generateCertificates creates a certificate authority, a server certificate
+// and a client certificate signed by it, and a client certificate signed by
+// another, untrusted certificate authority. All are written to dir, using the
+// file names the Docker CLI expects for the client. The returned certificate
+// is the server's.
+func generateCertificates(dir string, opts certificateOptions) (tls.Certificate, error) {
+	now := time.Now()
+	ca, err := newKeyPair(&x509.Certificate{
+		Subject:               pkix.Name{CommonName: "tls-proxy CA"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}, nil)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	serverTemplate := &x509.Certificate{
+		Subject:     pkix.Name{CommonName: opts.hostnames[0]},
+		NotBefore:   now.Add(-time.Hour),
+		NotAfter:    now.Add(24 * time.Hour),
+		KeyUsage:    x509.KeyUsageDigitalSignature,
+		ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	if opts.expired {
+		serverTemplate.NotBefore = now.Add(-48 * time.Hour)
+		serverTemplate.NotAfter = now.Add(-24 * time.Hour)
+	}
+	for _, hostname := range opts.hostnames {
+		if ip := net.ParseIP(hostname); ip != nil {
+			serverTemplate.IPAddresses = append(serverTemplate.IPAddresses, ip)
+		} else {
+			serverTemplate.DNSNames = append(serverTemplate.DNSNames, hostname)
+		}
+	}
+	server, err := newKeyPair(serverTemplate, ca)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	clientTemplate := func() *x509.Certificate {
+		return &x509.Certificate{
+			Subject:     pkix.Name{CommonName: "client"},
+			NotBefore:   now.Add(-time.Hour),
+			NotAfter:    now.Add(24 * time.Hour),
+			KeyUsage:    x509.KeyUsageDigitalSignature,
+			ExtKeyUsage: []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		}
+	}
+	client, err := newKeyPair(clientTemplate(), ca)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	untrustedCA, err := newKeyPair(&x509.Certificate{
+		Subject:               pkix.Name{CommonName: "tls-proxy untrusted CA"},
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.Add(24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+	}, nil)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	untrusted, err := newKeyPair(clientTemplate(), untrustedCA)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return tls.Certificate{}, err
+	}
+	for name, pair := range map[string]*keyPair{
+		"ca":        ca,
+		"server":    server,
+		"client":    client,
+		"untrusted": untrusted,
+	} {
+		certFile, keyFile := name+"-cert.pem", name+"-key.pem"
+		switch name {
+		case "ca":
+			certFile = "ca.pem"
+		case "client":
+			certFile, keyFile = "cert.pem", "key.pem"
+		}
+		if err := pair.write(filepath.Join(dir, certFile), filepath.Join(dir, keyFile)); err != nil {
+			return tls.Certificate{}, err
+		}
+	}
+	return tls.Certificate{
+		Certificate: [][]byte{server.der},
+		PrivateKey:  server.key,
+		Leaf:        server.cert,
+	}, nil
+}
+
+// newKeyPair creates a certificate from template, signed by parent, or
+// self-signed if parent is nil.
+func newKeyPair(template *x509.Certificate, parent *keyPair) (*keyPair, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	template.SerialNumber, err = rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 64))
+	if err != nil {
+		return nil, err
+	}
+	signerCert, signerKey := template, key
+	if parent != nil {
+		signerCert, signerKey = parent.cert, parent.key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, signerCert, &key.PublicKey, signerKey)
+	if err != nil {
+		return nil, fmt.Errorf("cannot create certificate for %s: %v", template.Subject.CommonName, err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil, err
+	}
+	return &keyPair{cert: cert, key: key, der: der}, nil
+}
+
+// write stores certificate and key in PEM format. The key is readable by
+// everyone, so that tests running as any user can use it.
+func (pair *keyPair) write(certFile, keyFile string) error {
+	keyDER, err := x509.MarshalECPrivateKey(pair.key)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: pair.der}), 0o644); err != nil {
+		return err
+	}
+	return os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o644)
+}
@@ -0,0 +1,132 @@
+// Copyright (c) Ansible Project
+// GNU General Public License v3.0+ (see COPYING or https://www.gnu.org/licenses/gpl-3.0.txt)
+
+// Command tls-proxy terminates TLS in front of a Docker daemon, usually
+// engine-mock, to test the TLS options of the modules and plugins. It passes
+// the decrypted connections on to the upstream address unchanged, so that
+// hijacked and streaming API calls work.
+//
+// At every start, it generates new certificates in the certificates
+// directory:
+//
+//	ca.pem, ca-key.pem                certificate authority
+//	server-cert.pem, server-key.pem   server certificate, signed by ca.pem
+//	cert.pem, key.pem                 client certificate, signed by ca.pem
+//	untrusted-cert.pem, untrusted-key.pem
+//	                                  client certificate, signed by a
+//	                                  certificate authority not in ca.pem
+//
+// The server certificate is valid for the names given with -hostname, which
+// can be used to provoke hostname mismatches. With -expired, the server
+// certificate expired a day ago.
+//
+// Usage:
+//
+//	tls-proxy [-listen ADDRESS] [-upstream ADDRESS] [-certs DIRECTORY] [-hostname NAMES] [-expired] [-client-auth MODE]
+//
+// The defaults are -listen :2376, -upstream tcp://127.0.0.1:2375, -certs
+// /certs and -hostname localhost,127.0.0.1. The upstream address is either
+// tcp://HOST:PORT or unix://PATH. NAMES is a comma-separated list of DNS
+// names and IP addresses. MODE is one of none (client certificates are not
+// requested), request (they are verified if given) and require (the
+// default). Failed handshakes are logged to stderr with their reason. The
+// program runs until it receives SIGTERM or SIGINT.
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"flag"
+	"io"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/ansible-collections/community.docker/tests/images/internal/fixture"
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("tls-proxy: ")
+
+	listen := flag.String("listen", ":2376", "address to listen on")
+	upstream := flag.String("upstream", "tcp://127.0.0.1:2375", "address to pass connections on to")
+	certsDir := flag.String("certs", "/certs", "directory to write the certificates to")
+	hostnames := flag.String("hostname", "localhost,127.0.0.1", "comma-separated names of the server certificate")
+	expired := flag.Bool("expired", false, "use an expired server certificate")
+	clientAuth := flag.String("client-auth", "require", "client certificate mode: none, request or require")
+	flag.Parse()
+
+	network, address, ok := strings.Cut(*upstream, "://")
+	if !ok || (network != "tcp" && network != "unix") {
+		log.Fatalf("invalid upstream address %q", *upstream)
+	}
+	config := &tls.Config{}
+	switch *clientAuth {
+	case "none":
+		config.ClientAuth = tls.NoClientCert
+	case "request":
+		config.ClientAuth = tls.VerifyClientCertIfGiven
+	case "require":
+		config.ClientAuth = tls.RequireAndVerifyClientCert
+	default:
+		log.Fatalf("invalid client certificate mode %q", *clientAuth)
+	}
+
+	cert, err := generateCertificates(*certsDir, certificateOptions{
+		hostnames: strings.Split(*hostnames, ","),
+		expired:   *expired,
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+	config.Certificates = []tls.Certificate{cert}
+	caContent, err := os.ReadFile(filepath.Join(*certsDir, "ca.pem"))
+	if err != nil {
+		log.Fatal(err)
+	}
+	config.ClientCAs = x509.NewCertPool()
+	config.ClientCAs.AppendCertsFromPEM(caContent)
+
+	listener, err := tls.Listen("tcp", *listen, config)
+	if err != nil {
+		log.Fatal(err)
+	}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				log.Fatal(err)
+			}
+			go proxy(conn.(*tls.Conn), network, address)
+		}
+	}()
+	fixture.WaitForSignal()
+}
+
+func proxy(conn *tls.Conn, network, address string) {
+	defer conn.Close()
+	if err := conn.Handshake(); err != nil {
+		log.Printf("%s: handshake failed: %v", conn.RemoteAddr(), err)
+		return
+	}
+	up, err := net.Dial(network, address)
+	if err != nil {
+		log.Printf("%s: %v", conn.RemoteAddr(), err)
+		return
+	}
+	defer up.Close()
+	log.Printf("%s: connected", conn.RemoteAddr())
+
+	go func() {
+		io.Copy(up, conn)
+		// Pass on the end of the request, so that the upstream can finish
+		// its response.
+		if closer, ok := up.(interface{ CloseWrite() error }); ok {
+			closer.CloseWrite()
+		}
+	}()
+	io.Copy(conn, up)
+}
@@ -0,0 +1,91 @@
+// Copyright (c) Ansible Project
+// GNU General Public License v3.0+ (see COPYING or https://www.gnu.org/licenses/gpl-3.0.txt)
+
+// Command ulimit-reporter prints the resource limits of its own process as a
+// single line of JSON on stdout:
+//
+//	{"ulimits":{"core":{"soft":0,"hard":-1},"nofile":{"soft":1024,"hard":4096},...}}
+//
+// Limits are named as in Docker's ulimits option. Unlimited values are
+// reported as -1, which is also how Docker represents them.
+//
+// Usage:
+//
+//	ulimit-reporter [-keep-running]
+//
+// With -keep-running, the program stays alive after printing until it
+// receives SIGTERM or SIGINT.
+package main
+
+import (
+	"flag"
+	"log"
+	"syscall"
+
+	"github.com/ansible-collections/community.docker/tests/images/internal/fixture"
+)
+
+// resources maps Docker's ulimit names to the Linux resource numbers, see
+// include/uapi/asm-generic/resource.h in the kernel sources. The syscall
+// package does not define constants for all of them.
+var resources = map[string]int{
+	"cpu":        0,
+	"fsize":      1,
+	"data":       2,
+	"stack":      3,
+	"core":       4,
+	"rss":        5,
+	"nproc":      6,
+	"nofile":     7,
+	"memlock":    8,
+	"as":         9,
+	"locks":      10,
+	"sigpending": 11,
+	"msgqueue":   12,
+	"nice":       13,
+	"rtprio":     14,
+	"rttime":     15,
+}
+
+const rlimInfinity = ^uint64(0)
+
+type limit struct {
+	Soft int64 `json:"soft"`
+	Hard int64 `json:"hard"`
+}
+
+type limits struct {
+	Ulimits map[string]limit `json:"ulimits"`
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("ulimit-reporter: ")
+
+	keepRunning := flag.Bool("keep-running", false, "keep running after printing until SIGTERM or SIGINT")
+	flag.Parse()
+
+	result := limits{Ulimits: make(map[string]limit)}
+	for name, resource := range resources {
+		var rlimit syscall.Rlimit
+		if err := syscall.Getrlimit(resource, &rlimit); err != nil {
+			log.Fatalf("cannot get %s limit: %v", name, err)
+		}
+		result.Ulimits[name] = limit{
+			Soft: limitValue(rlimit.Cur),
+			Hard: limitValue(rlimit.Max),
+		}
+	}
+	fixture.WriteJSON(result)
+
+	if *keepRunning {
+		fixture.WaitForSignal()
+	}
+}
+
+func limitValue(value uint64) int64 {
+	if value == rlimInfinity {
+		return -1
+	}
+	return int64(value)
+}
@@ -0,0 +1,143 @@
+// Copyright (c) Ansible Project
+// GNU General Public License v3.0+ (see COPYING or https://www.gnu.org/licenses/gpl-3.0.txt)
+
+// Command unix-socket serves a line-based request/response protocol on a unix
+// socket, so that tests can verify that sockets are shared between containers
+// through volumes or bind mounts. Every line received is answered with a line
+// of JSON:
+//
+//	{"count":3,"hostname":"6f0c1a2b3c4d","pid":1,"request":"ping"}
+//
+// count is the number of requests the server has answered so far, across all
+// connections. In client mode, each MESSAGE (default ping) is sent to the
+// socket and the answers are written to stdout.
+//
+// Usage:
+//
+//	unix-socket [-socket PATH] [-mode MODE]
+//	unix-socket -client [-socket PATH] [MESSAGE...]
+//
+// The default socket is /sockets/unix-socket.sock; its directory is meant to be
+// a volume; it is created if missing. A stale socket file at PATH is removed on
+// startup, and the socket is removed again when the server receives SIGTERM or
+// SIGINT. -mode sets the permissions of the socket file (default 0666). The
+// client exits with status 1 if it cannot connect or does not receive an
+// answer.
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+
+	"github.com/ansible-collections/community.docker/tests/images/internal/fixture"
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("unix-socket: ")
+
+	path := flag.String("socket", "/sockets/unix-socket.sock", "path of the unix socket")
+	mode := flag.String("mode", "0666", "permissions of the socket file")
+	client := flag.Bool("client", false, "send messages to the socket instead of serving it")
+	flag.Parse()
+
+	if *client {
+		messages := flag.Args()
+		if len(messages) == 0 {
+			messages = []string{"ping"}
+		}
+		runClient(*path, messages)
+		return
+	}
+
+	perm, err := strconv.ParseUint(*mode, 8, 32)
+	if err != nil {
+		log.Fatalf("invalid -mode: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(*path), 0o755); err != nil {
+		log.Fatal(err)
+	}
+	if err := os.Remove(*path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		log.Fatal(err)
+	}
+	listener, err := net.Listen("unix", *path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.Chmod(*path, fs.FileMode(perm)); err != nil {
+		log.Fatal(err)
+	}
+	go serve(listener)
+	fixture.WaitForSignal()
+	listener.Close()
+}
+
+type server struct {
+	hostname string
+	mu       sync.Mutex
+	count    int
+}
+
+func serve(listener net.Listener) {
+	hostname, _ := os.Hostname()
+	s := &server{hostname: hostname}
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			log.Fatal(err)
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *server) handle(conn net.Conn) {
+	defer conn.Close()
+	scanner := bufio.NewScanner(conn)
+	for scanner.Scan() {
+		s.mu.Lock()
+		s.count++
+		count := s.count
+		s.mu.Unlock()
+		content, _ := json.Marshal(map[string]interface{}{
+			"hostname": s.hostname,
+			"pid":      os.Getpid(),
+			"count":    count,
+			"request":  scanner.Text(),
+		})
+		if _, err := conn.Write(append(content, '\n')); err != nil {
+			return
+		}
+	}
+}
+
+func runClient(path string, messages []string) {
+	conn, err := net.Dial("unix", path)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	for _, message := range messages {
+		if _, err := fmt.Fprintln(conn, message); err != nil {
+			log.Fatal(err)
+		}
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			log.Fatalf("no answer to %q: %v", message, err)
+		}
+		os.Stdout.WriteString(line)
+	}
+}
@@ -0,0 +1,93 @@
+// Copyright (c) Ansible Project
+// GNU General Public License v3.0+ (see COPYING or https://www.gnu.org/licenses/gpl-3.0.txt)
+
+// Command user-reporter prints the identity its process runs with as a single
+// line of JSON on stdout:
+//
+//	{"uid":1000,"euid":1000,"gid":1000,"egid":1000,"groups":[27,1000]}
+//
+// This reflects what the kernel actually applied, as opposed to the User and
+// GroupAdd values in the container configuration.
+//
+// With -userns, the ID mappings of the user namespace are reported instead,
+// as found in /proc/self/uid_map and /proc/self/gid_map:
+//
+//	{"uid_map":[{"inside":0,"outside":100000,"count":65536}],"gid_map":[{"inside":0,"outside":100000,"count":65536}],"setgroups":"allow","remapped":true,"touched":{"path":"/data/touched","uid":0,"gid":0}}
+//
+// remapped is false if both maps are the identity mapping of the initial
+// user namespace. With -touch, the file FILE is created if it does not
+// exist, and its owner as seen inside the container is reported; the file is
+// kept, so that its owner on the host can be compared, for example on a bind
+// mount with userns-remap.
+//
+// Usage:
+//
+//	user-reporter [-keep-running]
+//	user-reporter -userns [-touch FILE] [-keep-running]
+//
+// With -keep-running, the program stays alive after printing until it
+// receives SIGTERM or SIGINT.
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+	"sort"
+
+	"github.com/ansible-collections/community.docker/tests/images/internal/fixture"
+)
+
+type identity struct {
+	UID    int   `json:"uid"`
+	EUID   int   `json:"euid"`
+	GID    int   `json:"gid"`
+	EGID   int   `json:"egid"`
+	Groups []int `json:"groups"`
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("user-reporter: ")
+
+	keepRunning := flag.Bool("keep-running", false, "keep running after printing until SIGTERM or SIGINT")
+	userns := flag.Bool("userns", false, "report the user namespace's ID mappings instead of the identity")
+	touch := flag.String("touch", "", "with -userns, create FILE and report its owner")
+	flag.Parse()
+
+	if *touch != "" && !*userns {
+		log.Fatal("-touch requires -userns")
+	}
+	if *userns {
+		ns, err := readUserNamespace(*touch)
+		if err != nil {
+			log.Fatal(err)
+		}
+		fixture.WriteJSON(ns)
+		if *keepRunning {
+			fixture.WaitForSignal()
+		}
+		return
+	}
+
+	groups, err := os.Getgroups()
+	if err != nil {
+		log.Fatalf("cannot get supplementary groups: %v", err)
+	}
+	if groups == nil {
+		groups = []int{}
+	}
+	sort.Ints(groups)
+
+	fixture.WriteJSON(identity{
+		UID:    os.Getuid(),
+		EUID:   os.Geteuid(),
+		GID:    os.Getgid(),
+		EGID:   os.Getegid(),
+		Groups: groups,
+	})
+
+	if *keepRunning {
+		fixture.WaitForSignal()
+	}
+}
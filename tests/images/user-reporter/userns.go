@@ -0,0 +1,110 @@
+// Copyright (c) Ansible Project
+// GNU General Public License v3.0+ (see COPYING or https://www.gnu.org/licenses/gpl-3.0.txt)
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+type idMapping struct {
+	Inside  uint32 `json:"inside"`
+	Outside uint32 `json:"outside"`
+	Count   uint32 `json:"count"`
+}
+
+type touchedFile struct {
+	Path  string `json:"path"`
+	UID   uint32 `json:"uid"`
+	GID   uint32 `json:"gid"`
+	Error string `json:"error,omitempty"`
+}
+
+type userNamespace struct {
+	UIDMap    []idMapping  `json:"uid_map"`
+	GIDMap    []idMapping  `json:"gid_map"`
+	Setgroups string       `json:"setgroups"`
+	Remapped  bool         `json:"remapped"`
+	Touched   *touchedFile `json:"touched,omitempty"`
+}
+
+// readUserNamespace reports the ID mappings of the process's user namespace,
+// and the ownership of the file touch (if not empty) after touching it.
+func readUserNamespace(touch string) (*userNamespace, error) {
+	var ns userNamespace
+	var err error
+	if ns.UIDMap, err = readIDMap("/proc/self/uid_map"); err != nil {
+		return nil, err
+	}
+	if ns.GIDMap, err = readIDMap("/proc/self/gid_map"); err != nil {
+		return nil, err
+	}
+	// setgroups does not exist on kernels before 3.19
+	if content, err := os.ReadFile("/proc/self/setgroups"); err == nil {
+		ns.Setgroups = strings.TrimSpace(string(content))
+	}
+	ns.Remapped = !isIdentity(ns.UIDMap) || !isIdentity(ns.GIDMap)
+	if touch != "" {
+		ns.Touched = touchFile(touch)
+	}
+	return &ns, nil
+}
+
+func readIDMap(path string) ([]idMapping, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	// The map is empty as long as the namespace is not set up.
+	mappings := []idMapping{}
+	for _, line := range strings.Split(string(content), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("%s: invalid line %q", path, line)
+		}
+		var values [3]uint32
+		for i, field := range fields {
+			value, err := strconv.ParseUint(field, 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("%s: invalid line %q", path, line)
+			}
+			values[i] = uint32(value)
+		}
+		mappings = append(mappings, idMapping{Inside: values[0], Outside: values[1], Count: values[2]})
+	}
+	return mappings, nil
+}
+
+// isIdentity reports whether mappings map every ID to itself, as in the
+// initial user namespace.
+func isIdentity(mappings []idMapping) bool {
+	return len(mappings) == 1 && mappings[0].Inside == 0 && mappings[0].Outside == 0 && mappings[0].Count == 4294967295
+}
+
+// touchFile creates the file if needed, without changing its content, and
+// reports its ownership as seen from inside the container. The file is
+// kept, so that its ownership can be inspected on the host as well.
+func touchFile(path string) *touchedFile {
+	t := &touchedFile{Path: path}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE, 0o644)
+	if err != nil {
+		t.Error = err.Error()
+		return t
+	}
+	f.Close()
+	var stat syscall.Stat_t
+	if err := syscall.Stat(path, &stat); err != nil {
+		t.Error = err.Error()
+		return t
+	}
+	t.UID = stat.Uid
+	t.GID = stat.Gid
+	return t
+}
@@ -0,0 +1,48 @@
+// Copyright (c) Ansible Project
+// GNU General Public License v3.0+ (see COPYING or https://www.gnu.org/licenses/gpl-3.0.txt)
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadIDMap(t *testing.T) {
+	for _, tc := range []struct {
+		name     string
+		content  string
+		expected []idMapping
+		err      string
+	}{
+		{"empty", "", []idMapping{}, ""},
+		{"blank line", "\n", []idMapping{}, ""},
+		{"identity", "         0          0 4294967295\n", []idMapping{{0, 0, 4294967295}}, ""},
+		{"several lines", "0 1000 1\n1 100000 65536\n", []idMapping{{0, 1000, 1}, {1, 100000, 65536}}, ""},
+		{"missing field", "0 1000\n", nil, `invalid line "0 1000"`},
+		{"extra field", "0 1000 1 1\n", nil, `invalid line "0 1000 1 1"`},
+		{"negative", "0 -1 1\n", nil, `invalid line "0 -1 1"`},
+		{"too large", "0 0 4294967296\n", nil, `invalid line "0 0 4294967296"`},
+		{"not a number", "0 1000 many\n", nil, `invalid line "0 1000 many"`},
+	} {
+		path := filepath.Join(t.TempDir(), "uid_map")
+		if err := os.WriteFile(path, []byte(tc.content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		got, err := readIDMap(path)
+		if tc.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tc.err) {
+				t.Errorf("%s: got %v, %v, expected the error %q", tc.name, got, err, tc.err)
+			}
+		} else if err != nil || !reflect.DeepEqual(got, tc.expected) {
+			t.Errorf("%s: got %#v, %v, expected %#v", tc.name, got, err, tc.expected)
+		}
+	}
+
+	if _, err := readIDMap(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("missing file: expected an error")
+	}
+}
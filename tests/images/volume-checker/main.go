@@ -0,0 +1,278 @@
+// Copyright (c) Ansible Project
+// GNU General Public License v3.0+ (see COPYING or https://www.gnu.org/licenses/gpl-3.0.txt)
+
+// Command volume-checker populates a directory with a deterministic set of
+// files, directories and symbolic links, and verifies later on (possibly from
+// another container) that the directory still has exactly this content.
+//
+// Usage:
+//
+//	volume-checker write [-uid UID] [-gid GID] DIRECTORY
+//	volume-checker verify [-uid UID] [-gid GID] DIRECTORY
+//
+// write creates the entries listed in entries below DIRECTORY, replacing
+// entries with the same name, and owned by UID and GID (1000 by default;
+// -1 keeps the ownership of the creating process). verify compares the
+// entries below DIRECTORY with the expected ones, reports any other entries
+// below DIRECTORY as unexpected, and prints the result as a single line of
+// JSON on stdout:
+//
+//	{"ok":false,"differences":[{"path":"data/medium.bin","field":"sha256","expected":"4f1c...","actual":"e3b0..."},{"path":"data/extra.txt","field":"exists","expected":"absent","actual":"present"}]}
+//
+// verify exits with 0 in both cases, so that the result can always be
+// retrieved from the container's output.
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"log"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"github.com/ansible-collections/community.docker/tests/images/internal/fixture"
+)
+
+type entryType int
+
+const (
+	regularFile entryType = iota
+	directory
+	symlink
+)
+
+type entry struct {
+	Path   string
+	Type   entryType
+	Mode   fs.FileMode
+	Size   int
+	Target string
+}
+
+// entries are created in this order, so directories have to be listed before
+// their content.
+var entries = []entry{
+	{Path: "empty", Type: regularFile, Mode: 0o644, Size: 0},
+	{Path: "small.txt", Type: regularFile, Mode: 0o644, Size: 16},
+	{Path: "script.sh", Type: regularFile, Mode: 0o755, Size: 100},
+	{Path: "data", Type: directory, Mode: 0o750},
+	{Path: "data/medium.bin", Type: regularFile, Mode: 0o600, Size: 64 * 1024},
+	{Path: "data/large.bin", Type: regularFile, Mode: 0o640, Size: 3*1024*1024 + 17},
+	{Path: "data/nested", Type: directory, Mode: 0o700},
+	{Path: "data/nested/deep.txt", Type: regularFile, Mode: 0o400, Size: 1000},
+	{Path: "link-to-file", Type: symlink, Target: "small.txt"},
+	{Path: "link-to-dir", Type: symlink, Target: "data/nested"},
+	{Path: "dangling-link", Type: symlink, Target: "does-not-exist"},
+}
+
+type difference struct {
+	Path     string `json:"path"`
+	Field    string `json:"field"`
+	Expected string `json:"expected"`
+	Actual   string `json:"actual"`
+}
+
+type verifyResult struct {
+	OK          bool         `json:"ok"`
+	Differences []difference `json:"differences"`
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("volume-checker: ")
+
+	if len(os.Args) < 2 {
+		usage()
+	}
+	command := os.Args[1]
+	flags := flag.NewFlagSet(command, flag.ExitOnError)
+	uid := flags.Int("uid", 1000, "owner of the entries (-1 for the current user)")
+	gid := flags.Int("gid", 1000, "group of the entries (-1 for the current group)")
+	flags.Parse(os.Args[2:])
+	if flags.NArg() != 1 {
+		usage()
+	}
+	dir := flags.Arg(0)
+
+	switch command {
+	case "write":
+		if err := write(dir, *uid, *gid); err != nil {
+			log.Fatal(err)
+		}
+	case "verify":
+		fixture.WriteJSON(verify(dir, *uid, *gid))
+	default:
+		usage()
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: volume-checker write|verify [-uid UID] [-gid GID] DIRECTORY")
+	os.Exit(2)
+}
+
+func write(dir string, uid, gid int) error {
+	// Make sure the modes are not changed by the umask.
+	syscall.Umask(0)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	for _, e := range entries {
+		path := filepath.Join(dir, e.Path)
+		if err := os.RemoveAll(path); err != nil {
+			return err
+		}
+		var err error
+		switch e.Type {
+		case regularFile:
+			err = os.WriteFile(path, content(e), e.Mode)
+		case directory:
+			err = os.Mkdir(path, e.Mode)
+		case symlink:
+			err = os.Symlink(e.Target, path)
+		}
+		if err != nil {
+			return err
+		}
+		if err := os.Lchown(path, uid, gid); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func verify(dir string, uid, gid int) *verifyResult {
+	res := &verifyResult{Differences: []difference{}}
+	report := func(path, field string, expected, actual interface{}) {
+		res.Differences = append(res.Differences, difference{
+			Path:     path,
+			Field:    field,
+			Expected: fmt.Sprint(expected),
+			Actual:   fmt.Sprint(actual),
+		})
+	}
+
+	for _, e := range entries {
+		path := filepath.Join(dir, e.Path)
+		info, err := os.Lstat(path)
+		if err != nil {
+			actual := "missing"
+			if !errors.Is(err, fs.ErrNotExist) {
+				actual = err.Error()
+			}
+			report(e.Path, "exists", "present", actual)
+			continue
+		}
+		if actual := typeOf(info); actual != e.Type {
+			report(e.Path, "type", e.Type, actual)
+			continue
+		}
+		if stat, ok := info.Sys().(*syscall.Stat_t); ok {
+			if uid >= 0 && int(stat.Uid) != uid {
+				report(e.Path, "uid", uid, stat.Uid)
+			}
+			if gid >= 0 && int(stat.Gid) != gid {
+				report(e.Path, "gid", gid, stat.Gid)
+			}
+		}
+		switch e.Type {
+		case regularFile, directory:
+			if info.Mode().Perm() != e.Mode {
+				report(e.Path, "mode", fmt.Sprintf("%04o", e.Mode), fmt.Sprintf("%04o", info.Mode().Perm()))
+			}
+		case symlink:
+			target, err := os.Readlink(path)
+			if err != nil {
+				report(e.Path, "target", e.Target, err)
+			} else if target != e.Target {
+				report(e.Path, "target", e.Target, target)
+			}
+		}
+		if e.Type == regularFile {
+			if info.Size() != int64(e.Size) {
+				report(e.Path, "size", e.Size, info.Size())
+			}
+			actual, err := os.ReadFile(path)
+			if err != nil {
+				report(e.Path, "sha256", checksum(content(e)), err)
+			} else if checksum(actual) != checksum(content(e)) {
+				report(e.Path, "sha256", checksum(content(e)), checksum(actual))
+			}
+		}
+	}
+
+	// Report everything else below the directory. The content of unexpected
+	// directories is not reported separately.
+	expected := make(map[string]bool)
+	for _, e := range entries {
+		expected[e.Path] = true
+	}
+	filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		rel, _ := filepath.Rel(dir, path)
+		if err != nil {
+			if !errors.Is(err, fs.ErrNotExist) {
+				report(filepath.ToSlash(rel), "readable", true, err)
+			}
+			return nil
+		}
+		if rel == "." || expected[filepath.ToSlash(rel)] {
+			return nil
+		}
+		report(filepath.ToSlash(rel), "exists", "absent", "present")
+		if d.IsDir() {
+			return filepath.SkipDir
+		}
+		return nil
+	})
+
+	res.OK = len(res.Differences) == 0
+	return res
+}
+
+func (t entryType) String() string {
+	switch t {
+	case regularFile:
+		return "file"
+	case directory:
+		return "directory"
+	case symlink:
+		return "symlink"
+	}
+	return "other"
+}
+
+func typeOf(info fs.FileInfo) entryType {
+	switch {
+	case info.Mode().IsRegular():
+		return regularFile
+	case info.IsDir():
+		return directory
+	case info.Mode()&fs.ModeSymlink != 0:
+		return symlink
+	}
+	return -1
+}
+
+// content returns the deterministic content of a regular file entry, which is
+// derived from its path so that swapped files are detected as well.
+func content(e entry) []byte {
+	data := make([]byte, 0, e.Size+sha256.Size)
+	var counter [8]byte
+	for i := uint64(0); len(data) < e.Size; i++ {
+		binary.BigEndian.PutUint64(counter[:], i)
+		block := sha256.Sum256(append([]byte(e.Path), counter[:]...))
+		data = append(data, block[:]...)
+	}
+	return data[:e.Size]
+}
+
+func checksum(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
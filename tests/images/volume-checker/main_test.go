@@ -0,0 +1,45 @@
+// Copyright (c) Ansible Project
+// GNU General Public License v3.0+ (see COPYING or https://www.gnu.org/licenses/gpl-3.0.txt)
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestVerify(t *testing.T) {
+	dir := t.TempDir()
+	if err := write(dir, -1, -1); err != nil {
+		t.Fatal(err)
+	}
+	if res := verify(dir, -1, -1); !res.OK || len(res.Differences) != 0 {
+		t.Fatalf("freshly written directory: got %+v", res)
+	}
+
+	for _, err := range []error{
+		os.WriteFile(filepath.Join(dir, "data", "extra.txt"), nil, 0o644),
+		os.MkdirAll(filepath.Join(dir, "junk", "sub"), 0o755),
+		os.WriteFile(filepath.Join(dir, "junk", "sub", "file"), nil, 0o644),
+		os.Remove(filepath.Join(dir, "empty")),
+		os.WriteFile(filepath.Join(dir, "small.txt"), []byte("changed"), 0o644),
+	} {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	expected := []difference{
+		{Path: "empty", Field: "exists", Expected: "present", Actual: "missing"},
+		{Path: "small.txt", Field: "size", Expected: "16", Actual: "7"},
+		{Path: "small.txt", Field: "sha256", Expected: checksum(content(entries[1])), Actual: checksum([]byte("changed"))},
+		{Path: "data/extra.txt", Field: "exists", Expected: "absent", Actual: "present"},
+		// The content of unexpected directories is not reported separately.
+		{Path: "junk", Field: "exists", Expected: "absent", Actual: "present"},
+	}
+	res := verify(dir, -1, -1)
+	if res.OK || !reflect.DeepEqual(res.Differences, expected) {
+		t.Errorf("got %+v, expected %+v", res.Differences, expected)
+	}
+}
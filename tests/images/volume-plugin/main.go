@@ -0,0 +1,266 @@
+// Copyright (c) Ansible Project
+// GNU General Public License v3.0+ (see COPYING or https://www.gnu.org/licenses/gpl-3.0.txt)
+
+// Command volume-plugin is a Docker volume plugin which stores every volume as
+// a directory below /data/volumes. It records the driver options a volume was
+// created with and reports them as the volume's status, so that tests can
+// verify which options were passed to the driver:
+//
+//	docker volume inspect --format '{{json .Status}}' myvolume
+//	{"mounts":0,"options":{"size":"10M"}}
+//
+// Errors can be injected with driver options: a volume created with the
+// option fail_create=MESSAGE is not created, and the creation fails with
+// MESSAGE. Similarly, fail_mount=MESSAGE and fail_remove=MESSAGE make mounting
+// and removing the volume fail.
+//
+// The volumes and their options are stored in /data/state.json, so that they
+// survive restarts of the plugin.
+//
+// Usage:
+//
+//	volume-plugin [-socket PATH] [-root DIRECTORY]
+//
+// The defaults are the ones used when running as managed plugin, namely
+// -socket /run/docker/plugins/volume-plugin.sock and -root /data. To install
+// the plugin, create its root filesystem from the image and combine it with
+// config.json from this directory:
+//
+//	docker build -t volume-plugin-rootfs -f tests/images/volume-plugin/Dockerfile tests/images
+//	mkdir -p /tmp/volume-plugin/rootfs
+//	docker export "$(docker create volume-plugin-rootfs)" | tar -x -C /tmp/volume-plugin/rootfs
+//	mkdir -p /tmp/volume-plugin/rootfs/data /tmp/volume-plugin/rootfs/run/docker/plugins
+//	cp tests/images/volume-plugin/config.json /tmp/volume-plugin/
+//	docker plugin create volume-plugin /tmp/volume-plugin
+//	docker plugin enable volume-plugin
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/ansible-collections/community.docker/tests/images/internal/fixture"
+)
+
+type volume struct {
+	Options map[string]string `json:"options"`
+	// Mounts are the IDs of the containers which currently mount the volume.
+	Mounts map[string]bool `json:"mounts"`
+}
+
+type driver struct {
+	root string
+
+	mu      sync.Mutex
+	volumes map[string]*volume
+}
+
+type request struct {
+	Name string            `json:"Name"`
+	ID   string            `json:"ID"`
+	Opts map[string]string `json:"Opts"`
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("volume-plugin: ")
+
+	socket := flag.String("socket", "/run/docker/plugins/volume-plugin.sock", "path of the plugin socket")
+	root := flag.String("root", "/data", "directory to store the volumes and the state in")
+	flag.Parse()
+
+	d := &driver{root: *root, volumes: make(map[string]*volume)}
+	if err := d.load(); err != nil {
+		log.Fatal(err)
+	}
+
+	mux := fixture.NewPluginMux("VolumeDriver")
+	mux.HandleFunc("/VolumeDriver.Capabilities", func(w http.ResponseWriter, r *http.Request) {
+		fixture.ServePluginJSON(w, map[string]interface{}{"Capabilities": map[string]string{"Scope": "local"}})
+	})
+	mux.HandleFunc("/VolumeDriver.Create", d.handle(d.create))
+	mux.HandleFunc("/VolumeDriver.Remove", d.handle(d.remove))
+	mux.HandleFunc("/VolumeDriver.Mount", d.handle(d.mount))
+	mux.HandleFunc("/VolumeDriver.Unmount", d.handle(d.unmount))
+	mux.HandleFunc("/VolumeDriver.Path", d.handle(d.path))
+	mux.HandleFunc("/VolumeDriver.Get", d.handle(d.get))
+	mux.HandleFunc("/VolumeDriver.List", d.handle(d.list))
+
+	fixture.ServePlugin(*socket, mux)
+}
+
+// handle decodes the request for a volume driver endpoint, and encodes the
+// response. Errors are reported in the Err field, as the protocol requires.
+func (d *driver) handle(f func(req *request) (map[string]interface{}, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req request
+		if r.ContentLength != 0 {
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				fixture.ServePluginJSON(w, map[string]string{"Err": fmt.Sprintf("invalid request: %v", err)})
+				return
+			}
+		}
+		d.mu.Lock()
+		response, err := f(&req)
+		d.mu.Unlock()
+		if response == nil {
+			response = make(map[string]interface{})
+		}
+		response["Err"] = ""
+		result := "ok"
+		if err != nil {
+			response["Err"] = err.Error()
+			result = err.Error()
+		}
+		log.Printf("%s %s: %s", r.URL.Path, req.Name, result)
+		fixture.ServePluginJSON(w, response)
+	}
+}
+
+func (d *driver) stateFile() string {
+	return filepath.Join(d.root, "state.json")
+}
+
+func (d *driver) mountpoint(name string) string {
+	return filepath.Join(d.root, "volumes", name)
+}
+
+func (d *driver) load() error {
+	content, err := os.ReadFile(d.stateFile())
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(content, &d.volumes); err != nil {
+		return fmt.Errorf("cannot parse %s: %v", d.stateFile(), err)
+	}
+	// After a restart of the plugin, no container has the volumes mounted.
+	for _, v := range d.volumes {
+		v.Mounts = make(map[string]bool)
+	}
+	return nil
+}
+
+// save writes the state. The caller must hold d.mu.
+func (d *driver) save() error {
+	content, err := json.Marshal(d.volumes)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(d.stateFile(), content, 0o644)
+}
+
+func (d *driver) lookup(name string) (*volume, error) {
+	v := d.volumes[name]
+	if v == nil {
+		return nil, fmt.Errorf("volume %s does not exist", name)
+	}
+	return v, nil
+}
+
+func (d *driver) describe(name string, v *volume) map[string]interface{} {
+	return map[string]interface{}{
+		"Name":       name,
+		"Mountpoint": d.mountpoint(name),
+		"Status": map[string]interface{}{
+			"options": v.Options,
+			"mounts":  len(v.Mounts),
+		},
+	}
+}
+
+func (d *driver) create(req *request) (map[string]interface{}, error) {
+	if req.Name == "" || req.Name != filepath.Base(req.Name) {
+		return nil, fmt.Errorf("invalid volume name %q", req.Name)
+	}
+	if message, ok := req.Opts["fail_create"]; ok {
+		return nil, fmt.Errorf("%s", message)
+	}
+	if _, ok := d.volumes[req.Name]; ok {
+		return nil, nil
+	}
+	if err := os.MkdirAll(d.mountpoint(req.Name), 0o755); err != nil {
+		return nil, err
+	}
+	options := req.Opts
+	if options == nil {
+		options = make(map[string]string)
+	}
+	d.volumes[req.Name] = &volume{Options: options, Mounts: make(map[string]bool)}
+	return nil, d.save()
+}
+
+func (d *driver) remove(req *request) (map[string]interface{}, error) {
+	v, err := d.lookup(req.Name)
+	if err != nil {
+		return nil, err
+	}
+	if message, ok := v.Options["fail_remove"]; ok {
+		return nil, fmt.Errorf("%s", message)
+	}
+	if len(v.Mounts) > 0 {
+		return nil, fmt.Errorf("volume %s is in use", req.Name)
+	}
+	if err := os.RemoveAll(d.mountpoint(req.Name)); err != nil {
+		return nil, err
+	}
+	delete(d.volumes, req.Name)
+	return nil, d.save()
+}
+
+func (d *driver) mount(req *request) (map[string]interface{}, error) {
+	v, err := d.lookup(req.Name)
+	if err != nil {
+		return nil, err
+	}
+	if message, ok := v.Options["fail_mount"]; ok {
+		return nil, fmt.Errorf("%s", message)
+	}
+	v.Mounts[req.ID] = true
+	return map[string]interface{}{"Mountpoint": d.mountpoint(req.Name)}, nil
+}
+
+func (d *driver) unmount(req *request) (map[string]interface{}, error) {
+	v, err := d.lookup(req.Name)
+	if err != nil {
+		return nil, err
+	}
+	delete(v.Mounts, req.ID)
+	return nil, nil
+}
+
+func (d *driver) path(req *request) (map[string]interface{}, error) {
+	if _, err := d.lookup(req.Name); err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"Mountpoint": d.mountpoint(req.Name)}, nil
+}
+
+func (d *driver) get(req *request) (map[string]interface{}, error) {
+	v, err := d.lookup(req.Name)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"Volume": d.describe(req.Name, v)}, nil
+}
+
+func (d *driver) list(req *request) (map[string]interface{}, error) {
+	names := make([]string, 0, len(d.volumes))
+	for name := range d.volumes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	volumes := []map[string]interface{}{}
+	for _, name := range names {
+		volumes = append(volumes, d.describe(name, d.volumes[name]))
+	}
+	return map[string]interface{}{"Volumes": volumes}, nil
+}
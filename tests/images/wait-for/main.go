@@ -0,0 +1,230 @@
+// Copyright (c) Ansible Project
+// GNU General Public License v3.0+ (see COPYING or https://www.gnu.org/licenses/gpl-3.0.txt)
+
+// Command wait-for waits until all targets are ready, retrying until a global
+// timeout expires, and prints the result as a single line of JSON on stdout:
+//
+//	{"ok":false,"elapsed_seconds":30.01,"targets":[{"target":"tcp://db:5432","ok":true,"attempts":3},{"target":"container://web","ok":false,"attempts":30,"error":"health status is starting"}]}
+//
+// The targets are checked concurrently. The program exits with status 0 if
+// all targets became ready, and with status 1 otherwise. The following
+// targets are supported:
+//
+//	tcp://HOST:PORT     a TCP connection can be established
+//	http://..., https://...
+//	                    a GET request returns the expected status
+//	file:///PATH        the file exists
+//	container://NAME    the container's health status is healthy, or, for
+//	                    containers without health check, it is running
+//
+// Containers are inspected through the Docker daemon given by DOCKER_HOST,
+// which defaults to unix:///var/run/docker.sock. Only unix:// and tcp://
+// addresses without TLS are supported.
+//
+// Usage:
+//
+//	wait-for [-timeout DURATION] [-interval DURATION] [-status CODE] [-insecure] [-verbose] TARGET...
+//
+// The defaults are -timeout 60s, -interval 1s and -status 200. With
+// -insecure, certificates of HTTPS servers are not verified. With -verbose,
+// every failed attempt is logged to stderr.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"math"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ansible-collections/community.docker/tests/images/internal/fixture"
+)
+
+type result struct {
+	Target   string `json:"target"`
+	OK       bool   `json:"ok"`
+	Attempts int    `json:"attempts"`
+	Error    string `json:"error,omitempty"`
+}
+
+type report struct {
+	OK             bool     `json:"ok"`
+	ElapsedSeconds float64  `json:"elapsed_seconds"`
+	Targets        []result `json:"targets"`
+}
+
+// checker checks a target once. Every check must return before ctx is done.
+type checker func(ctx context.Context) error
+
+var (
+	expectedStatus *int
+	insecure       *bool
+)
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("wait-for: ")
+
+	timeout := flag.Duration("timeout", 60*time.Second, "time to wait for all targets")
+	interval := flag.Duration("interval", time.Second, "time between attempts")
+	expectedStatus = flag.Int("status", http.StatusOK, "expected status of HTTP targets")
+	insecure = flag.Bool("insecure", false, "do not verify certificates of HTTPS targets")
+	verbose := flag.Bool("verbose", false, "log every failed attempt")
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		fmt.Fprintln(flag.CommandLine.Output(), "usage: wait-for [-timeout DURATION] [-interval DURATION] [-status CODE] [-insecure] [-verbose] TARGET...")
+		os.Exit(2)
+	}
+	checkers := make([]checker, flag.NArg())
+	for i, target := range flag.Args() {
+		var err error
+		if checkers[i], err = newChecker(target); err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+	r := report{OK: true, Targets: make([]result, flag.NArg())}
+	var wg sync.WaitGroup
+	for i, target := range flag.Args() {
+		r.Targets[i].Target = target
+		wg.Add(1)
+		go func(res *result, check checker) {
+			defer wg.Done()
+			for {
+				res.Attempts++
+				err := check(ctx)
+				if err == nil {
+					res.OK = true
+					res.Error = ""
+					return
+				}
+				res.Error = err.Error()
+				if *verbose {
+					log.Printf("%s: attempt %d: %v", res.Target, res.Attempts, err)
+				}
+				select {
+				case <-ctx.Done():
+					return
+				case <-time.After(*interval):
+				}
+			}
+		}(&r.Targets[i], checkers[i])
+	}
+	wg.Wait()
+	for _, res := range r.Targets {
+		r.OK = r.OK && res.OK
+	}
+	r.ElapsedSeconds = math.Round(time.Since(start).Seconds()*100) / 100
+	fixture.WriteJSON(r)
+	if !r.OK {
+		os.Exit(1)
+	}
+}
+
+func newChecker(target string) (checker, error) {
+	u, err := url.Parse(target)
+	if err != nil {
+		return nil, fmt.Errorf("invalid target %q: %v", target, err)
+	}
+	switch u.Scheme {
+	case "tcp":
+		return func(ctx context.Context) error {
+			return fixture.CheckTCP(ctx, u.Host)
+		}, nil
+	case "http", "https":
+		client := fixture.NewHTTPClient(*insecure)
+		return func(ctx context.Context) error {
+			status, err := fixture.CheckHTTP(ctx, client, target)
+			if err != nil {
+				return err
+			}
+			if status != *expectedStatus {
+				return fmt.Errorf("status is %d instead of %d", status, *expectedStatus)
+			}
+			return nil
+		}, nil
+	case "file":
+		return func(ctx context.Context) error {
+			_, err := os.Stat(u.Path)
+			return err
+		}, nil
+	case "container":
+		client, err := newDockerClient()
+		if err != nil {
+			return nil, err
+		}
+		return func(ctx context.Context) error {
+			return checkContainer(ctx, client, u.Host)
+		}, nil
+	}
+	return nil, fmt.Errorf("invalid target %q: unsupported scheme", target)
+}
+
+// newDockerClient returns an HTTP client connecting to the daemon given by
+// DOCKER_HOST.
+func newDockerClient() (*http.Client, error) {
+	host := os.Getenv("DOCKER_HOST")
+	if host == "" {
+		host = "unix:///var/run/docker.sock"
+	}
+	network, address, ok := strings.Cut(host, "://")
+	if !ok || (network != "unix" && network != "tcp") {
+		return nil, fmt.Errorf("unsupported DOCKER_HOST %q", host)
+	}
+	return &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			var dialer net.Dialer
+			return dialer.DialContext(ctx, network, address)
+		},
+	}}, nil
+}
+
+func checkContainer(ctx context.Context, client *http.Client, name string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://docker/containers/"+url.PathEscape(name)+"/json", nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	var inspect struct {
+		Message string `json:"message"`
+		State   struct {
+			Status string `json:"Status"`
+			Health *struct {
+				Status string `json:"Status"`
+			} `json:"Health"`
+		} `json:"State"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&inspect); err != nil {
+		return fmt.Errorf("cannot decode response: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return errors.New(inspect.Message)
+	}
+	if inspect.State.Health != nil {
+		if inspect.State.Health.Status != "healthy" {
+			return fmt.Errorf("health status is %s", inspect.State.Health.Status)
+		}
+		return nil
+	}
+	if inspect.State.Status != "running" {
+		return fmt.Errorf("status is %s", inspect.State.Status)
+	}
+	return nil
+}
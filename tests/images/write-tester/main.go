@@ -0,0 +1,137 @@
+// Copyright (c) Ansible Project
+// GNU General Public License v3.0+ (see COPYING or https://www.gnu.org/licenses/gpl-3.0.txt)
+
+// Command write-tester tries to write to a list of paths and reports the
+// results as a single line of JSON on stdout:
+//
+//	{"paths":[{"path":"/","type":"directory","writable":false,"error":"read-only file system","errno":"EROFS"},{"path":"/tmp","type":"directory","writable":true}]}
+//
+// For a directory, a new file is created in it, written to and removed
+// again. An existing file is opened for appending, without changing its
+// content. This verifies read_only, tmpfs mounts and read-only volumes
+// functionally, instead of relying on the mount options. Paths which do not
+// exist are reported with type missing and are not written to.
+//
+// Usage:
+//
+//	write-tester [-keep-running] [-listen ADDRESS] [PATH...]
+//
+// The default paths are /, /tmp and /run. With -keep-running, the program
+// stays alive after printing until it receives SIGTERM or SIGINT. With
+// -listen (for example -listen :8080), it additionally serves the report on
+// every HTTP GET request to ADDRESS until it receives SIGTERM or SIGINT; the
+// paths are tested anew for every request.
+package main
+
+import (
+	"errors"
+	"flag"
+	"io/fs"
+	"log"
+	"os"
+	"syscall"
+
+	"github.com/ansible-collections/community.docker/tests/images/internal/fixture"
+)
+
+type result struct {
+	Path     string `json:"path"`
+	Type     string `json:"type"`
+	Writable bool   `json:"writable"`
+	Error    string `json:"error,omitempty"`
+	Errno    string `json:"errno,omitempty"`
+}
+
+type report struct {
+	Paths []result `json:"paths"`
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("write-tester: ")
+
+	keepRunning := flag.Bool("keep-running", false, "keep running after printing until SIGTERM or SIGINT")
+	listen := flag.String("listen", "", "serve the report over HTTP on this address")
+	flag.Parse()
+
+	paths := flag.Args()
+	if len(paths) == 0 {
+		paths = []string{"/", "/tmp", "/run"}
+	}
+	fixture.WriteJSON(testPaths(paths))
+
+	if *listen != "" {
+		fixture.Serve(*listen, fixture.ReportHandler(func() (interface{}, error) {
+			return testPaths(paths), nil
+		}))
+		fixture.WaitForSignal()
+	} else if *keepRunning {
+		fixture.WaitForSignal()
+	}
+}
+
+func testPaths(paths []string) report {
+	r := report{Paths: []result{}}
+	for _, path := range paths {
+		r.Paths = append(r.Paths, testPath(path))
+	}
+	return r
+}
+
+func testPath(path string) result {
+	res := result{Path: path}
+	info, err := os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		res.Type = "missing"
+		return res
+	} else if err != nil {
+		res.setError(err)
+		return res
+	}
+	if info.IsDir() {
+		res.Type = "directory"
+		err = writeNewFile(path)
+	} else {
+		res.Type = "file"
+		err = appendNothing(path)
+	}
+	if err != nil {
+		res.setError(err)
+	} else {
+		res.Writable = true
+	}
+	return res
+}
+
+// writeNewFile creates a file in dir, writes to it and removes it.
+func writeNewFile(dir string) error {
+	f, err := os.CreateTemp(dir, ".write-tester-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write([]byte("write-tester\n")); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// appendNothing opens the file for appending and closes it right away.
+func appendNothing(path string) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+func (res *result) setError(err error) {
+	var errno syscall.Errno
+	if errors.As(err, &errno) {
+		res.Error = errno.Error()
+		res.Errno = fixture.ErrnoName(errno)
+	} else {
+		res.Error = err.Error()
+	}
+}
@@ -0,0 +1,130 @@
+// Copyright (c) Ansible Project
+// GNU General Public License v3.0+ (see COPYING or https://www.gnu.org/licenses/gpl-3.0.txt)
+
+// Command zombie-creator creates orphaned processes that exit shortly after
+// and counts the zombie processes that are left over.
+//
+// For every requested orphan, the program starts an intermediate child which
+// starts a short-lived grandchild and exits immediately. The grandchild is
+// thereby re-parented to PID 1 of the container. If this program itself is
+// PID 1, nobody reaps the grandchildren when they exit and they stay around
+// as zombies. If the container runs with an init process (init: true), the
+// init process reaps them and no zombies remain.
+//
+// After the given delay, the result is printed as a single line of JSON on
+// stdout:
+//
+//	{"pid":1,"orphans":5,"zombies":5}
+//
+// Usage:
+//
+//	zombie-creator [-count N] [-delay DURATION] [-output FILE] [-listen ADDRESS] [-keep-running]
+//
+// The default count is 5 and the default delay 2s. With -output, the result
+// is also written to FILE. With -listen (for example -listen :8080), the
+// zombies are counted anew on every HTTP GET request to ADDRESS, and the
+// program runs until it receives SIGTERM or SIGINT. The same happens with
+// -keep-running, just without HTTP server.
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/ansible-collections/community.docker/tests/images/internal/fixture"
+)
+
+type result struct {
+	PID     int `json:"pid"`
+	Orphans int `json:"orphans"`
+	Zombies int `json:"zombies"`
+}
+
+func main() {
+	log.SetFlags(0)
+	log.SetPrefix("zombie-creator: ")
+
+	mode := flag.String("mode", "", "internal: run as intermediate or orphan process")
+	count := flag.Int("count", 5, "number of orphans to create")
+	delay := flag.Duration("delay", 2*time.Second, "time to wait before counting zombies")
+	output := flag.String("output", "", "also write the result to this file")
+	listen := flag.String("listen", "", "serve the result over HTTP on this address")
+	keepRunning := flag.Bool("keep-running", false, "keep running after printing until SIGTERM or SIGINT")
+	flag.Parse()
+
+	switch *mode {
+	case "intermediate":
+		// Start the orphan and exit without waiting for it.
+		if err := exec.Command("/proc/self/exe", "-mode", "orphan").Start(); err != nil {
+			log.Fatalf("cannot start orphan: %v", err)
+		}
+		return
+	case "orphan":
+		time.Sleep(100 * time.Millisecond)
+		return
+	case "":
+	default:
+		log.Fatalf("invalid mode %q", *mode)
+	}
+
+	if *count < 0 {
+		log.Fatalf("invalid count %d", *count)
+	}
+	for i := 0; i < *count; i++ {
+		if err := exec.Command("/proc/self/exe", "-mode", "intermediate").Run(); err != nil {
+			log.Fatalf("cannot run intermediate process: %v", err)
+		}
+	}
+	time.Sleep(*delay)
+
+	res, err := countZombies(*count)
+	if err != nil {
+		log.Fatal(err)
+	}
+	fixture.WriteJSON(res)
+	if *output != "" {
+		content, _ := json.Marshal(res)
+		if err := os.WriteFile(*output, append(content, '\n'), 0o644); err != nil {
+			log.Fatalf("cannot write %s: %v", *output, err)
+		}
+	}
+
+	if *listen != "" {
+		fixture.Serve(*listen, fixture.ReportHandler(func() (interface{}, error) {
+			return countZombies(*count)
+		}))
+		fixture.WaitForSignal()
+	} else if *keepRunning {
+		fixture.WaitForSignal()
+	}
+}
+
+// countZombies counts the processes in state Z visible in /proc.
+func countZombies(orphans int) (*result, error) {
+	stats, err := filepath.Glob("/proc/[0-9]*/stat")
+	if err != nil {
+		return nil, err
+	}
+	res := &result{PID: os.Getpid(), Orphans: orphans}
+	for _, path := range stats {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			// The process exited in the meantime
+			continue
+		}
+		// The state follows the command name, which is enclosed in
+		// parentheses and can contain spaces and parentheses itself.
+		stat := string(content)
+		i := strings.LastIndex(stat, ")")
+		if i >= 0 && strings.HasPrefix(stat[i+1:], " Z") {
+			res.Zombies++
+		}
+	}
+	return res, nil
+}